@@ -0,0 +1,116 @@
+// Command migrate shows and changes the schema version of the application database or of the central
+// SQL Server. The backend applies pending migrations on start, so this is mostly needed to roll back.
+//
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate up
+//	go run ./cmd/migrate -db mssql down 0
+//
+// The connection settings are read like the backend reads them, from CONFIG_FILE and the environment.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"go-backend/internals/config"
+	"go-backend/internals/database"
+	"go-backend/internals/migrate"
+	"go-backend/internals/secrets"
+
+	"github.com/rs/zerolog"
+)
+
+const usage = "usage: migrate [-db postgres|mssql] status | up | down <version>"
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "migrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	target := flags.String("db", "postgres", "database to migrate, postgres or mssql")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return fmt.Errorf(usage)
+	}
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	cfg, err := config.Load("")
+	if err != nil {
+		return err
+	}
+	var db *sql.DB
+	var migrator *migrate.Migrator
+	switch *target {
+	case "postgres":
+		if db, err = database.ConnectPostgres(cfg.Postgres); err == nil {
+			migrator, err = database.PostgresMigrator(db)
+		}
+	case "mssql":
+		if db, err = database.ConnectMSSQL(cfg.MSSQL, secrets.NewResolver(cfg.Secrets)); err == nil {
+			migrator, err = database.MSSQLMigrator(db)
+		}
+	default:
+		return fmt.Errorf("unknown database %q, %s", *target, usage)
+	}
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := ""
+			if !status.AppliedAt.IsZero() {
+				appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, status.State, appliedAt)
+		}
+		return w.Flush()
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		for _, migration := range applied {
+			fmt.Printf("applied %d_%s\n", migration.Version, migration.Name)
+		}
+		return nil
+	case "down":
+		if len(args) != 2 {
+			return fmt.Errorf(usage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("version %q is not a number", args[1])
+		}
+		undone, err := migrator.Down(ctx, version)
+		if err != nil {
+			return err
+		}
+		for _, migration := range undone {
+			fmt.Printf("rolled back %d_%s\n", migration.Version, migration.Name)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, %s", args[0], usage)
+}
@@ -0,0 +1,134 @@
+// Command procedures verifies and deploys the stored procedures the backend runs on the SQL Servers of
+// the inventory, like GET /servers/{server}/procedures and POST /servers/{server}/procedures/deploy.
+//
+//	go run ./cmd/procedures verify sales-db-01
+//	go run ./cmd/procedures deploy -all
+//
+// -all takes every enabled SQL Server of the inventory. verify exits with status 2 when a server drifted.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"text/tabwriter"
+
+	"go-backend/internals/config"
+	"go-backend/internals/database"
+	"go-backend/internals/engine"
+	"go-backend/internals/pkg"
+	"go-backend/internals/procedures"
+	"go-backend/internals/secrets"
+	"go-backend/models"
+
+	"github.com/rs/zerolog"
+)
+
+const usage = "usage: procedures verify|deploy <server> | -all"
+
+var errDrift = errors.New("stored procedures drifted")
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "procedures:", err)
+		if errors.Is(err, errDrift) {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) != 2 || (args[0] != "verify" && args[0] != "deploy") {
+		return fmt.Errorf(usage)
+	}
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+
+	cfg, err := config.Load("")
+	if err != nil {
+		return err
+	}
+	connector := engine.NewConnector(cfg, secrets.NewResolver(cfg.Secrets))
+
+	db, err := database.ConnectPostgres(cfg.Postgres)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	// deployments are recorded in a table of the migrations
+	if err := database.MigratePostgres(context.Background(), db); err != nil {
+		return err
+	}
+
+	servers, err := targets(db, args[1])
+	if err != nil {
+		return err
+	}
+
+	deployedBy := "cli"
+	if u, err := user.Current(); err == nil {
+		deployedBy = "cli:" + u.Username
+	}
+
+	ctx := context.Background()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tPROCEDURE\tSTATUS\tDEPLOYED BY\tDEPLOYED AT")
+	drift, failed := false, false
+	for _, server := range servers {
+		var report models.ProcedureReport
+		if args[0] == "deploy" {
+			report, err = procedures.Deploy(ctx, db, connector, server, deployedBy)
+		} else {
+			report, err = procedures.Verify(ctx, db, connector, server)
+		}
+		if err != nil {
+			fmt.Fprintf(w, "%s\t\terror: %v\t\t\n", server.Name, err)
+			failed = true
+			continue
+		}
+		for _, procedure := range report.Procedures {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", report.Server, procedure.Name, procedure.Status, procedure.DeployedBy, procedure.DeployedAt)
+		}
+		drift = drift || report.Drift
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	switch {
+	case failed:
+		return fmt.Errorf("%s failed on some servers", args[0])
+	case drift:
+		return errDrift
+	}
+	return nil
+}
+
+// targets returns the server named on the command line, or every enabled SQL Server for -all.
+func targets(db *sql.DB, name string) ([]engine.Server, error) {
+	if name != "-all" {
+		entry, err := pkg.FindServer(db, name)
+		if err != nil {
+			return nil, err
+		}
+		return []engine.Server{engine.FromInventory(entry)}, nil
+	}
+
+	entries, err := pkg.GetAllServers(db)
+	if err != nil {
+		return nil, err
+	}
+	var servers []engine.Server
+	for _, entry := range entries {
+		if entry.Enabled && entry.Engine == engine.MSSQL {
+			servers = append(servers, engine.FromInventory(entry))
+		}
+	}
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("the inventory has no enabled SQL Server")
+	}
+	return servers, nil
+}
@@ -0,0 +1,64 @@
+// Command secrets seals and opens the encrypted credentials file read by the file secrets provider.
+//
+//	SECRETS_FILE_KEY=$(openssl rand -base64 32) go run ./cmd/secrets seal credentials.json secrets.enc
+//	go run ./cmd/secrets open secrets.enc
+//
+// credentials.json maps names to {"username": "...", "password": "..."}.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"go-backend/internals/secrets"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "secrets:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: secrets seal <credentials.json> <secrets.enc> | secrets open <secrets.enc>")
+	}
+	key, err := secrets.DecodeKey(os.Getenv("SECRETS_FILE_KEY"))
+	if err != nil {
+		return fmt.Errorf("SECRETS_FILE_KEY: %w", err)
+	}
+
+	switch args[0] {
+	case "seal":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: secrets seal <credentials.json> <secrets.enc>")
+		}
+		plaintext, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		var entries map[string]secrets.Credentials
+		if err := json.Unmarshal(plaintext, &entries); err != nil {
+			return fmt.Errorf("%s must hold a JSON object of credentials: %w", args[1], err)
+		}
+		sealed, err := secrets.SealFile(key, plaintext)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(args[2], sealed, 0600)
+	case "open":
+		sealed, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		plaintext, err := secrets.OpenFile(key, sealed)
+		if err != nil {
+			return err
+		}
+		_, err = os.Stdout.Write(plaintext)
+		return err
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
@@ -0,0 +1,240 @@
+// Package config holds the settings of the backend. They are read once at startup from an optional YAML
+// file, overridden by environment variables (and a .env file) with the names used so far, and validated
+// before anything connects. The result is handed to the app and the constructors that need it, no package
+// keeps settings of its own.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is read when CONFIG_FILE is not set and the file exists.
+const DefaultFile = "config.yaml"
+
+type Config struct {
+	Server       ServerConfig       `yaml:"server"`
+	Postgres     PostgresConfig     `yaml:"postgres"`
+	MSSQL        MSSQLConfig        `yaml:"mssql"`
+	Engines      EnginesConfig      `yaml:"engines"`
+	Secrets      SecretsConfig      `yaml:"secrets"`
+	SMTP         SMTPConfig         `yaml:"smtp"`
+	Session      SessionConfig      `yaml:"session"`
+	Admin        AdminConfig        `yaml:"admin"`
+	Jobs         JobsConfig         `yaml:"jobs"`
+	PasswordSync PasswordSyncConfig `yaml:"password_sync"`
+	Grants       GrantsConfig       `yaml:"grants"`
+	Idempotency  IdempotencyConfig  `yaml:"idempotency"`
+	Topology     TopologyConfig     `yaml:"topology"`
+}
+
+type ServerConfig struct {
+	Addr        string   `yaml:"addr"`
+	CORSOrigins []string `yaml:"cors_origins"`
+	// TrustProxyHeaders takes the client address from X-Forwarded-For, only for deployments behind a proxy
+	TrustProxyHeaders bool          `yaml:"trust_proxy_headers"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout"`
+}
+
+// PostgresConfig is the application database.
+type PostgresConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+	SSLMode  string `yaml:"sslmode"`
+}
+
+// MSSQLConfig is the central SQL Server catalog. Its user and password are also the default admin
+// credentials for SQL Server instances of the inventory without a credential reference.
+type MSSQLConfig struct {
+	Server        string `yaml:"server"`
+	Port          int    `yaml:"port"`
+	User          string `yaml:"user"`
+	Password      string `yaml:"password"`
+	Name          string `yaml:"name"`
+	CredentialRef string `yaml:"credential_ref"`
+	// CheckPolicy and CheckExpiration are applied to SQL Server logins together with a new password, both
+	// are off by default like the resets made before they were configurable
+	CheckPolicy     bool `yaml:"check_policy"`
+	CheckExpiration bool `yaml:"check_expiration"`
+}
+
+// EnginesConfig holds the default admin connections for PostgreSQL and MySQL servers of the inventory.
+type EnginesConfig struct {
+	Postgres PostgresAdminConfig `yaml:"postgres"`
+	MySQL    MySQLAdminConfig    `yaml:"mysql"`
+}
+
+type PostgresAdminConfig struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Database string `yaml:"database"`
+	SSLMode  string `yaml:"sslmode"`
+}
+
+type MySQLAdminConfig struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	TLS      string `yaml:"tls"`
+}
+
+type SecretsConfig struct {
+	// Provider is used for credential references without a provider prefix
+	Provider string      `yaml:"provider"`
+	File     string      `yaml:"file"`
+	FileKey  string      `yaml:"file_key"`
+	Vault    VaultConfig `yaml:"vault"`
+}
+
+type VaultConfig struct {
+	Addr      string `yaml:"addr"`
+	Token     string `yaml:"token"`
+	Mount     string `yaml:"mount"`
+	Namespace string `yaml:"namespace"`
+}
+
+type SMTPConfig struct {
+	Server   string `yaml:"server"`
+	Port     int    `yaml:"port"`
+	From     string `yaml:"from"`
+	Password string `yaml:"password"`
+}
+
+type SessionConfig struct {
+	// Secret signs session cookies, every instance needs the same one
+	Secret       string        `yaml:"secret"`
+	TTL          time.Duration `yaml:"ttl"`
+	MaxAge       time.Duration `yaml:"max_age"`
+	CookieSecure bool          `yaml:"cookie_secure"`
+}
+
+type AdminConfig struct {
+	// MFARequired makes admins enroll a TOTP authenticator before they can do anything else
+	MFARequired bool `yaml:"mfa_required"`
+	// BootstrapUsername and BootstrapPasswordHash create the first superadmin of an empty installation
+	BootstrapUsername     string `yaml:"bootstrap_username"`
+	BootstrapPasswordHash string `yaml:"bootstrap_password_hash"`
+}
+
+type JobsConfig struct {
+	Workers      int           `yaml:"workers"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	// EncryptionKey holds 32 base64 encoded bytes. It is required: every instance must use the same key to
+	// read the jobs the others queued, also after a restart
+	EncryptionKey string `yaml:"encryption_key"`
+}
+
+// PasswordSyncConfig controls how a password update is applied to a primary and its replicas.
+type PasswordSyncConfig struct {
+	Attempts int `yaml:"attempts"`
+	// RetryDelay is the first delay between attempts on a server, it doubles with every retry
+	RetryDelay    time.Duration `yaml:"retry_delay"`
+	Concurrency   int           `yaml:"concurrency"`
+	ServerTimeout time.Duration `yaml:"server_timeout"`
+}
+
+type GrantsConfig struct {
+	RevokeInterval   time.Duration `yaml:"revoke_interval"`
+	MaxDurationHours int           `yaml:"max_duration_hours"`
+	ServerTimeout    time.Duration `yaml:"server_timeout"`
+}
+
+type IdempotencyConfig struct {
+	KeyTTL time.Duration `yaml:"key_ttl"`
+	// InProgressTTL is the lease of a request that is still running, a key whose request died with its
+	// instance can be used again after it
+	InProgressTTL time.Duration `yaml:"in_progress_ttl"`
+}
+
+type TopologyConfig struct {
+	// CacheTTL is how long discovered replicas are kept, zero asks the server every time
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// Default returns the settings used for everything that is neither in the file nor in the environment.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			CORSOrigins:     []string{"http://localhost:5173"},
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Postgres: PostgresConfig{Port: 5432, SSLMode: "disable"},
+		MSSQL:    MSSQLConfig{Port: 1433},
+		Engines: EnginesConfig{
+			Postgres: PostgresAdminConfig{Database: "postgres", SSLMode: "require"},
+			MySQL:    MySQLAdminConfig{TLS: "preferred"},
+		},
+		Secrets: SecretsConfig{
+			Provider: "env",
+			Vault:    VaultConfig{Mount: "secret"},
+		},
+		Session: SessionConfig{TTL: 30 * time.Minute, MaxAge: 12 * time.Hour},
+		Admin:   AdminConfig{MFARequired: true},
+		Jobs:    JobsConfig{Workers: 2, PollInterval: time.Second},
+		PasswordSync: PasswordSyncConfig{
+			Attempts:      3,
+			RetryDelay:    2 * time.Second,
+			Concurrency:   4,
+			ServerTimeout: 30 * time.Second,
+		},
+		Grants:      GrantsConfig{RevokeInterval: time.Minute, MaxDurationHours: 8, ServerTimeout: 30 * time.Second},
+		Idempotency: IdempotencyConfig{KeyTTL: 24 * time.Hour, InProgressTTL: 2 * time.Minute},
+		Topology:    TopologyConfig{CacheTTL: 5 * time.Minute},
+	}
+}
+
+// Load builds the configuration from the defaults, the YAML file at path and the environment, in that
+// order, and validates the result. An empty path falls back to CONFIG_FILE and then to config.yaml if it
+// exists. A .env file in the working directory is loaded first, without replacing variables already set.
+func Load(path string) (*Config, error) {
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("loading .env: %w", err)
+	}
+
+	cfg := Default()
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if path == "" {
+		if _, err := os.Stat(DefaultFile); err == nil {
+			path = DefaultFile
+		}
+	}
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// readFile decodes the YAML file over the current settings, unknown keys are reported so typos do not go unnoticed.
+func (c *Config) readFile(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
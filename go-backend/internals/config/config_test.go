@@ -0,0 +1,129 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func validConfig() *Config {
+	c := Default()
+	c.Postgres.Host, c.Postgres.User, c.Postgres.Name = "localhost", "dba", "dba"
+	c.MSSQL.Server, c.MSSQL.User, c.MSSQL.Password, c.MSSQL.Name = "mssql", "sa", "pw", "master"
+	c.Jobs.EncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	c.Session.Secret = "0123456789abcdef0123456789abcdef"
+	return c
+}
+
+func TestReadFileThenEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+server:
+  addr: ":9090"
+  cors_origins: ["https://dba.example.com"]
+postgres:
+  host: pg.internal
+  port: 6432
+password_sync:
+  retry_delay: 500ms
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c := Default()
+	if err := c.readFile(path); err != nil {
+		t.Fatalf("readFile = %v", err)
+	}
+	env := map[string]string{
+		"DB_PORT":              "5433",
+		"CORS_ALLOWED_ORIGINS": "https://a.example.com, https://b.example.com,",
+		"ADMIN_MFA_REQUIRED":   "false",
+	}
+	if err := c.applyEnv(func(name string) string { return env[name] }); err != nil {
+		t.Fatalf("applyEnv = %v", err)
+	}
+
+	if c.Server.Addr != ":9090" || c.Postgres.Host != "pg.internal" || c.PasswordSync.RetryDelay != 500*time.Millisecond {
+		t.Errorf("file settings not applied: %+v", c)
+	}
+	if c.Postgres.Port != 5433 || c.Admin.MFARequired {
+		t.Errorf("environment must override the file, got port %d and mfa %v", c.Postgres.Port, c.Admin.MFARequired)
+	}
+	if got := strings.Join(c.Server.CORSOrigins, " "); got != "https://a.example.com https://b.example.com" {
+		t.Errorf("CORS origins = %q", got)
+	}
+	if c.PasswordSync.Attempts != 3 {
+		t.Errorf("unset settings must keep their default, attempts = %d", c.PasswordSync.Attempts)
+	}
+}
+
+func TestReadFileRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("postgres:\n  hostname: pg\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Default().readFile(path); err == nil || !strings.Contains(err.Error(), "hostname") {
+		t.Fatalf("readFile with a misspelled key = %v, want an error naming it", err)
+	}
+}
+
+func TestApplyEnvReportsEveryBadValue(t *testing.T) {
+	env := map[string]string{"DB_PORT": "fivefourthreetwo", "SESSION_TTL": "30", "SESSION_COOKIE_SECURE": "yes please"}
+	err := Default().applyEnv(func(name string) string { return env[name] })
+	for name := range env {
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("applyEnv error %v does not mention %s", err, name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("Validate of a valid config = %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		change  func(*Config)
+		problem string
+	}{
+		{"missing postgres host", func(c *Config) { c.Postgres.Host = "" }, "postgres.host (DB_HOST)"},
+		{"bad listen address", func(c *Config) { c.Server.Addr = "8080" }, "server.addr"},
+		{"wildcard origin", func(c *Config) { c.Server.CORSOrigins = []string{"*"} }, "server.cors_origins"},
+		{"origin with path", func(c *Config) { c.Server.CORSOrigins = []string{"https://a.example.com/app"} }, "server.cors_origins"},
+		{"no mssql credentials", func(c *Config) { c.MSSQL.Password = "" }, "MS_DB_CREDENTIAL_REF"},
+		{"expiration without policy", func(c *Config) { c.MSSQL.CheckExpiration = true }, "mssql.check_expiration"},
+		{"port out of range", func(c *Config) { c.MSSQL.Port = 70000 }, "mssql.port"},
+		{"no job key", func(c *Config) { c.Jobs.EncryptionKey = "" }, "jobs.encryption_key (JOB_ENCRYPTION_KEY) is required"},
+		{"short job key", func(c *Config) { c.Jobs.EncryptionKey = "c2hvcnQ=" }, "jobs.encryption_key"},
+		{"vault without token", func(c *Config) { c.Secrets.Provider, c.Secrets.Vault.Addr = "vault", "https://vault:8200" }, "secrets.vault.token"},
+		{"unknown provider", func(c *Config) { c.Secrets.Provider = "aws" }, "secrets.provider"},
+		{"no session secret", func(c *Config) { c.Session.Secret = "" }, "session.secret (SESSION_SECRET)"},
+		{"short session secret", func(c *Config) { c.Session.Secret = "hunter2" }, "session.secret"},
+		{"session max age below ttl", func(c *Config) { c.Session.MaxAge = time.Minute }, "session.max_age"},
+		{"in progress lease above key ttl", func(c *Config) { c.Idempotency.InProgressTTL = 48 * time.Hour }, "idempotency.in_progress_ttl"},
+		{"no sync attempts", func(c *Config) { c.PasswordSync.Attempts = 0 }, "password_sync.attempts"},
+		{"bootstrap hash alone", func(c *Config) { c.Admin.BootstrapPasswordHash = "$2a$06$x" }, "admin.bootstrap_username"},
+		{"smtp without port", func(c *Config) { c.SMTP.Server, c.SMTP.From = "smtp.example.com", "dba@example.com" }, "smtp.port"},
+	}
+	for _, tc := range cases {
+		c := validConfig()
+		tc.change(c)
+		err := c.Validate()
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || !strings.Contains(err.Error(), tc.problem) {
+			t.Errorf("%s: Validate = %v, want a problem with %s", tc.name, err, tc.problem)
+		}
+	}
+}
+
+func TestValidateListsAllProblems(t *testing.T) {
+	err := Default().Validate()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Problems) < 4 {
+		t.Fatalf("Validate of the defaults = %v, want every missing connection setting listed", err)
+	}
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// binding maps an environment variable onto a setting. Empty variables are treated as unset.
+type binding struct {
+	name   string
+	target any
+}
+
+func (c *Config) envBindings() []binding {
+	return []binding{
+		{"LISTEN_ADDR", &c.Server.Addr},
+		{"CORS_ALLOWED_ORIGINS", &c.Server.CORSOrigins},
+		{"TRUST_PROXY_HEADERS", &c.Server.TrustProxyHeaders},
+		{"SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout},
+
+		{"DB_HOST", &c.Postgres.Host},
+		{"DB_PORT", &c.Postgres.Port},
+		{"DB_USER", &c.Postgres.User},
+		{"DB_PASSWORD", &c.Postgres.Password},
+		{"DB_NAME", &c.Postgres.Name},
+		{"DB_SSLMODE", &c.Postgres.SSLMode},
+
+		{"MS_DB_SERVER", &c.MSSQL.Server},
+		{"MS_DB_PORT", &c.MSSQL.Port},
+		{"MS_DB_USER", &c.MSSQL.User},
+		{"MS_DB_PASSWORD", &c.MSSQL.Password},
+		{"MS_DB_NAME", &c.MSSQL.Name},
+		{"MS_DB_CREDENTIAL_REF", &c.MSSQL.CredentialRef},
+		{"MS_CHECK_POLICY", &c.MSSQL.CheckPolicy},
+		{"MS_CHECK_EXPIRATION", &c.MSSQL.CheckExpiration},
+
+		{"PG_ADMIN_USER", &c.Engines.Postgres.User},
+		{"PG_ADMIN_PASSWORD", &c.Engines.Postgres.Password},
+		{"PG_ADMIN_DATABASE", &c.Engines.Postgres.Database},
+		{"PG_ADMIN_SSLMODE", &c.Engines.Postgres.SSLMode},
+		{"MYSQL_ADMIN_USER", &c.Engines.MySQL.User},
+		{"MYSQL_ADMIN_PASSWORD", &c.Engines.MySQL.Password},
+		{"MYSQL_ADMIN_TLS", &c.Engines.MySQL.TLS},
+
+		{"SECRETS_PROVIDER", &c.Secrets.Provider},
+		{"SECRETS_FILE", &c.Secrets.File},
+		{"SECRETS_FILE_KEY", &c.Secrets.FileKey},
+		{"VAULT_ADDR", &c.Secrets.Vault.Addr},
+		{"VAULT_TOKEN", &c.Secrets.Vault.Token},
+		{"VAULT_KV_MOUNT", &c.Secrets.Vault.Mount},
+		{"VAULT_NAMESPACE", &c.Secrets.Vault.Namespace},
+
+		{"SMTP_SERVER", &c.SMTP.Server},
+		{"SMTP_PORT", &c.SMTP.Port},
+		{"SMTP_FROM", &c.SMTP.From},
+		{"SMTP_PASSWORD", &c.SMTP.Password},
+
+		{"SESSION_SECRET", &c.Session.Secret},
+		{"SESSION_TTL", &c.Session.TTL},
+		{"SESSION_MAX_AGE", &c.Session.MaxAge},
+		{"SESSION_COOKIE_SECURE", &c.Session.CookieSecure},
+
+		{"ADMIN_MFA_REQUIRED", &c.Admin.MFARequired},
+		{"ADMIN_BOOTSTRAP_USERNAME", &c.Admin.BootstrapUsername},
+		{"ADMIN_BOOTSTRAP_PASSWORD_HASH", &c.Admin.BootstrapPasswordHash},
+
+		{"PASSWORD_JOB_WORKERS", &c.Jobs.Workers},
+		{"PASSWORD_JOB_POLL_INTERVAL", &c.Jobs.PollInterval},
+		{"JOB_ENCRYPTION_KEY", &c.Jobs.EncryptionKey},
+
+		{"PASSWORD_SYNC_ATTEMPTS", &c.PasswordSync.Attempts},
+		{"PASSWORD_SYNC_RETRY_DELAY", &c.PasswordSync.RetryDelay},
+		{"PASSWORD_SYNC_CONCURRENCY", &c.PasswordSync.Concurrency},
+		{"PASSWORD_SYNC_SERVER_TIMEOUT", &c.PasswordSync.ServerTimeout},
+
+		{"JIT_REVOKE_INTERVAL", &c.Grants.RevokeInterval},
+		{"JIT_MAX_DURATION_HOURS", &c.Grants.MaxDurationHours},
+		{"JIT_SERVER_TIMEOUT", &c.Grants.ServerTimeout},
+
+		{"IDEMPOTENCY_KEY_TTL", &c.Idempotency.KeyTTL},
+		{"IDEMPOTENCY_IN_PROGRESS_TTL", &c.Idempotency.InProgressTTL},
+		{"TOPOLOGY_CACHE_TTL", &c.Topology.CacheTTL},
+	}
+}
+
+// applyEnv overrides the settings with the variables returned by getenv. Every variable that cannot be
+// parsed is reported, not just the first one.
+func (c *Config) applyEnv(getenv func(string) string) error {
+	var errs []error
+	for _, b := range c.envBindings() {
+		if value := getenv(b.name); value != "" {
+			if err := b.set(value); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b binding) set(value string) error {
+	switch target := b.target.(type) {
+	case *string:
+		*target = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a whole number", b.name, value)
+		}
+		*target = n
+	case *bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not true or false", b.name, value)
+		}
+		*target = v
+	case *time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration such as 30s or 5m", b.name, value)
+		}
+		*target = d
+	case *[]string:
+		// comma separated, empty entries are dropped
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*target = list
+	default:
+		return fmt.Errorf("%s: unsupported setting type %T", b.name, b.target)
+	}
+	return nil
+}
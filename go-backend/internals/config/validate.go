@@ -0,0 +1,148 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.problems = append(v.problems, fmt.Sprintf(format, args...))
+	}
+}
+
+func (v *validator) port(port int, setting string) {
+	v.check(port > 0 && port <= 65535, "%s must be between 1 and 65535, got %d", setting, port)
+}
+
+func (v *validator) oneOf(value, setting string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.problems = append(v.problems, fmt.Sprintf("%s must be one of %s, got %q", setting, strings.Join(allowed, ", "), value))
+}
+
+// key32 checks a base64 encoded AES-256 key.
+func (v *validator) key32(encoded, setting string) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	v.check(err == nil && len(key) == 32, "%s must be 32 base64 encoded bytes", setting)
+}
+
+// Validate reports every setting that would keep the backend from starting or working correctly. The
+// messages name the YAML key followed by the environment variable that sets it.
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	_, _, err := net.SplitHostPort(c.Server.Addr)
+	v.check(err == nil, "server.addr (LISTEN_ADDR) must be host:port or :port, got %q", c.Server.Addr)
+	v.check(len(c.Server.CORSOrigins) > 0, "server.cors_origins (CORS_ALLOWED_ORIGINS) needs at least one origin")
+	for _, origin := range c.Server.CORSOrigins {
+		u, err := url.Parse(origin)
+		valid := err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && (u.Path == "" || u.Path == "/")
+		// cookies are sent cross-origin, so a wildcard would hand admin sessions to any site
+		v.check(valid, "server.cors_origins (CORS_ALLOWED_ORIGINS) entries must be http(s)://host[:port] origins, got %q", origin)
+	}
+	v.check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout (SHUTDOWN_TIMEOUT) must be positive")
+
+	v.check(c.Postgres.Host != "", "postgres.host (DB_HOST) is required")
+	v.port(c.Postgres.Port, "postgres.port (DB_PORT)")
+	v.check(c.Postgres.User != "", "postgres.user (DB_USER) is required")
+	v.check(c.Postgres.Name != "", "postgres.name (DB_NAME) is required")
+	v.oneOf(c.Postgres.SSLMode, "postgres.sslmode (DB_SSLMODE)", postgresSSLModes...)
+
+	v.check(c.MSSQL.Server != "", "mssql.server (MS_DB_SERVER) is required")
+	v.port(c.MSSQL.Port, "mssql.port (MS_DB_PORT)")
+	v.check(c.MSSQL.Name != "", "mssql.name (MS_DB_NAME) is required")
+	v.check(c.MSSQL.CredentialRef != "" || (c.MSSQL.User != "" && c.MSSQL.Password != ""),
+		"mssql needs user and password (MS_DB_USER, MS_DB_PASSWORD) or credential_ref (MS_DB_CREDENTIAL_REF)")
+	v.check(c.MSSQL.CheckPolicy || !c.MSSQL.CheckExpiration,
+		"mssql.check_expiration (MS_CHECK_EXPIRATION) needs mssql.check_policy (MS_CHECK_POLICY)")
+
+	v.check(c.Engines.Postgres.Database != "", "engines.postgres.database (PG_ADMIN_DATABASE) must not be empty")
+	v.oneOf(c.Engines.Postgres.SSLMode, "engines.postgres.sslmode (PG_ADMIN_SSLMODE)", postgresSSLModes...)
+	v.oneOf(c.Engines.MySQL.TLS, "engines.mysql.tls (MYSQL_ADMIN_TLS)", "true", "false", "skip-verify", "preferred")
+
+	v.oneOf(c.Secrets.Provider, "secrets.provider (SECRETS_PROVIDER)", "env", "file", "vault")
+	if c.Secrets.Provider == "file" {
+		v.check(c.Secrets.File != "", "secrets.file (SECRETS_FILE) is required by the file provider")
+		v.check(c.Secrets.FileKey != "", "secrets.file_key (SECRETS_FILE_KEY) is required by the file provider")
+	}
+	if c.Secrets.FileKey != "" {
+		v.key32(c.Secrets.FileKey, "secrets.file_key (SECRETS_FILE_KEY)")
+	}
+	if c.Secrets.Provider == "vault" {
+		v.check(c.Secrets.Vault.Addr != "", "secrets.vault.addr (VAULT_ADDR) is required by the vault provider")
+		v.check(c.Secrets.Vault.Token != "", "secrets.vault.token (VAULT_TOKEN) is required by the vault provider")
+	}
+	if c.Secrets.Vault.Addr != "" {
+		u, err := url.Parse(c.Secrets.Vault.Addr)
+		v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"secrets.vault.addr (VAULT_ADDR) must be an http(s) URL, got %q", c.Secrets.Vault.Addr)
+	}
+
+	if c.SMTP.Server != "" {
+		v.port(c.SMTP.Port, "smtp.port (SMTP_PORT)")
+		v.check(c.SMTP.From != "", "smtp.from (SMTP_FROM) is required when smtp.server is set")
+	}
+
+	v.check(len(c.Session.Secret) >= minSessionSecretLength,
+		"session.secret (SESSION_SECRET) must be at least %d characters, generate one with openssl rand -base64 32", minSessionSecretLength)
+	v.check(c.Session.TTL > 0, "session.ttl (SESSION_TTL) must be positive")
+	v.check(c.Session.MaxAge >= c.Session.TTL, "session.max_age (SESSION_MAX_AGE) must not be shorter than session.ttl")
+
+	v.check((c.Admin.BootstrapUsername == "") == (c.Admin.BootstrapPasswordHash == ""),
+		"admin.bootstrap_username and admin.bootstrap_password_hash (ADMIN_BOOTSTRAP_USERNAME, ADMIN_BOOTSTRAP_PASSWORD_HASH) must be set together")
+	if c.Admin.BootstrapPasswordHash != "" {
+		v.check(strings.HasPrefix(c.Admin.BootstrapPasswordHash, "$2a$"),
+			"admin.bootstrap_password_hash (ADMIN_BOOTSTRAP_PASSWORD_HASH) must be a bcrypt hash as produced by crypt(password, gen_salt('bf'))")
+	}
+
+	v.check(c.Jobs.Workers > 0, "jobs.workers (PASSWORD_JOB_WORKERS) must be at least 1")
+	v.check(c.Jobs.PollInterval > 0, "jobs.poll_interval (PASSWORD_JOB_POLL_INTERVAL) must be positive")
+	v.check(c.Jobs.EncryptionKey != "", "jobs.encryption_key (JOB_ENCRYPTION_KEY) is required, generate one with openssl rand -base64 32")
+	if c.Jobs.EncryptionKey != "" {
+		v.key32(c.Jobs.EncryptionKey, "jobs.encryption_key (JOB_ENCRYPTION_KEY)")
+	}
+
+	v.check(c.PasswordSync.Attempts > 0, "password_sync.attempts (PASSWORD_SYNC_ATTEMPTS) must be at least 1")
+	v.check(c.PasswordSync.RetryDelay >= 0, "password_sync.retry_delay (PASSWORD_SYNC_RETRY_DELAY) must not be negative")
+	v.check(c.PasswordSync.Concurrency > 0, "password_sync.concurrency (PASSWORD_SYNC_CONCURRENCY) must be at least 1")
+	v.check(c.PasswordSync.ServerTimeout > 0, "password_sync.server_timeout (PASSWORD_SYNC_SERVER_TIMEOUT) must be positive")
+
+	v.check(c.Grants.RevokeInterval > 0, "grants.revoke_interval (JIT_REVOKE_INTERVAL) must be positive")
+	v.check(c.Grants.MaxDurationHours > 0, "grants.max_duration_hours (JIT_MAX_DURATION_HOURS) must be at least 1")
+	v.check(c.Grants.ServerTimeout > 0, "grants.server_timeout (JIT_SERVER_TIMEOUT) must be positive")
+
+	v.check(c.Idempotency.KeyTTL > 0, "idempotency.key_ttl (IDEMPOTENCY_KEY_TTL) must be positive")
+	v.check(c.Idempotency.InProgressTTL > 0 && c.Idempotency.InProgressTTL <= c.Idempotency.KeyTTL,
+		"idempotency.in_progress_ttl (IDEMPOTENCY_IN_PROGRESS_TTL) must be positive and not longer than idempotency.key_ttl")
+	v.check(c.Topology.CacheTTL >= 0, "topology.cache_ttl (TOPOLOGY_CACHE_TTL) must not be negative")
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+// minSessionSecretLength is the length of 32 random bytes in hex, shorter secrets are likely typed by hand.
+const minSessionSecretLength = 32
+
+var postgresSSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
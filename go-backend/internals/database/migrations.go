@@ -0,0 +1,71 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+
+	"go-backend/internals/migrate"
+	"go-backend/internals/procedures"
+
+	"github.com/rs/zerolog/log"
+)
+
+// The schema is versioned by the files in migrations/, which are part of the binary. A change to the
+// schema is a new pair of files, applied files must not be edited.
+//
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+//go:embed migrations/mssql/*.sql
+var mssqlMigrations embed.FS
+
+// PostgresMigrator returns the migrations of the application database.
+func PostgresMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	return migrate.New(db, migrate.Postgres, postgresMigrations, "migrations/postgres")
+}
+
+// MSSQLMigrator returns the migrations of the central SQL Server, the procedures the backend calls there.
+func MSSQLMigrator(msdb *sql.DB) (*migrate.Migrator, error) {
+	return migrate.New(msdb, migrate.SQLServer, mssqlMigrations, "migrations/mssql")
+}
+
+// MigratePostgres applies the pending migrations of the application database and purges stale rows.
+func MigratePostgres(ctx context.Context, db *sql.DB) error {
+	migrator, err := PostgresMigrator(db)
+	if err != nil {
+		return err
+	}
+	if err := up(ctx, "PostgreSQL", migrator); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "CALL purge_stale_rows()")
+	return err
+}
+
+// MigrateMSSQL applies the pending migrations of the central SQL Server and installs the procedures the
+// backend also deploys to the servers of the inventory, so both come from the same scripts.
+func MigrateMSSQL(ctx context.Context, msdb *sql.DB) error {
+	migrator, err := MSSQLMigrator(msdb)
+	if err != nil {
+		return err
+	}
+	if err := up(ctx, "MS SQL Server", migrator); err != nil {
+		return err
+	}
+	return procedures.Install(ctx, msdb)
+}
+
+func up(ctx context.Context, name string, migrator *migrate.Migrator) error {
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return err
+	}
+	for _, migration := range applied {
+		log.Info().Msgf("Applied %s migration %d_%s", name, migration.Version, migration.Name)
+	}
+	if len(applied) == 0 {
+		log.Info().Msgf("%s schema is up to date", name)
+	}
+	return nil
+}
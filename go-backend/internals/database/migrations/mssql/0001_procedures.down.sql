@@ -0,0 +1,5 @@
+-- Removes the procedures of the central SQL Server
+DROP PROCEDURE IF EXISTS dbo.CheckLoginExpiration;
+DROP PROCEDURE IF EXISTS dbo.FindRelatedServers;
+DROP PROCEDURE IF EXISTS dbo.ValidateAccessRequest;
+DROP PROCEDURE IF EXISTS dbo.ValidateUserCredentials;
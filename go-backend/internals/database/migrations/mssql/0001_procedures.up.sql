@@ -0,0 +1,114 @@
+-- Procedures of the central SQL Server, each one is its own batch. GrantDatabaseAccess, RevokeDatabaseAccess
+-- and ResetUserPassword are installed from internals/procedures after the migrations, see MigrateMSSQL.
+
+GO
+
+CREATE OR ALTER PROCEDURE dbo.CheckLoginExpiration
+    @LoginName NVARCHAR(255),
+    @SqlInstance NVARCHAR(255),
+    @IsExpired BIT OUTPUT,
+    @IsValid BIT OUTPUT
+AS
+BEGIN
+    SET @IsExpired = 0;
+    SET @IsValid = 0;
+
+    SELECT 
+        @IsExpired = lei.is_expired, 
+        @IsValid = CASE 
+            WHEN lei.is_expired = 1 THEN 0 
+            ELSE 1 
+        END
+    FROM dbo.server_login_expiry_collection_computed c
+    JOIN dbo.all_server_login_expiry_info lei
+        ON lei.sql_instance = c.sql_instance 
+        AND lei.collection_time = c.collection_time_latest
+    WHERE 
+        lei.login_name = @LoginName 
+        AND c.sql_instance = @SqlInstance;
+
+    IF @IsValid = 0 OR @IsExpired = 1
+    BEGIN
+        RAISERROR(18456, 16, 1);
+        RETURN;
+    END
+
+END;
+
+
+GO
+
+CREATE OR ALTER PROCEDURE dbo.FindRelatedServers
+    @ServerIP NVARCHAR(255)
+AS 
+BEGIN
+    SET NOCOUNT ON;
+
+-- returns one row per other member of the availability groups the server or listener belongs to
+with cte as (
+	select * 
+	from dbo.sma_hadr_ag ag
+	where 1=1
+	and (ag.server = @ServerIP or ag.ag_listener_ip1 = @ServerIP or ag.ag_listener_ip2 = @ServerIP)
+)
+,cte2 as (
+	select r.server
+	from cte c
+	join dbo.sma_hadr_ag r
+		on r.server <> c.server
+		and ( r.ag_listener_ip1 = c.ag_listener_ip1 and  r.ag_listener_ip2 = c.ag_listener_ip2)
+	union
+	select c.server
+	from cte c
+)
+select server
+from cte2
+where server is not null
+and server <> @ServerIP
+END;
+
+GO
+
+CREATE OR ALTER PROCEDURE dbo.ValidateAccessRequest
+    @Username NVARCHAR(255),
+    @IsValid BIT OUTPUT
+AS
+BEGIN
+    SET @IsValid = 0;
+
+    IF EXISTS (
+        SELECT 1
+        FROM dbo.login_email_mapping
+        WHERE login_name = @Username
+    )
+    BEGIN
+        SET @IsValid = 1;
+    END
+END;
+
+GO
+
+CREATE OR ALTER PROCEDURE dbo.ValidateUserCredentials
+    @Username NVARCHAR(255),
+    @ServerIP NVARCHAR(255),
+    @Email NVARCHAR(255),
+    @IsValid BIT OUTPUT
+AS
+BEGIN
+    SET @IsValid = 0;
+
+    IF EXISTS (
+        SELECT 1
+        FROM dbo.login_email_mapping
+        WHERE login_name = @Username
+        AND (
+              sql_instance_ip = @ServerIP OR
+              sql_instance_ip = '*'
+          )
+        AND CHARINDEX(@Email, owner_group_email) > 0
+    )
+    BEGIN
+        SET @IsValid = 1;
+    END
+END;
+    
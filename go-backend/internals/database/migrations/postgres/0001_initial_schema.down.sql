@@ -0,0 +1,66 @@
+-- Removes the baseline schema together with all audit history, admins and queued jobs
+
+DROP TABLE IF EXISTS server_procedures;
+DROP TABLE IF EXISTS topology_overrides;
+DROP TABLE IF EXISTS database_servers;
+DROP TABLE IF EXISTS idempotency_keys;
+DROP TABLE IF EXISTS password_job_steps;
+DROP TABLE IF EXISTS password_jobs;
+DROP TABLE IF EXISTS pass_reset_server_attempts;
+DROP TABLE IF EXISTS login_attempts;
+DROP TABLE IF EXISTS admin_mfa_challenges;
+DROP TABLE IF EXISTS admin_recovery_codes;
+DROP TABLE IF EXISTS admin_sessions;
+DROP TABLE IF EXISTS temporary_grants;
+DROP TABLE IF EXISTS temporary_access_requests;
+DROP TABLE IF EXISTS access_request_decisions;
+DROP TABLE IF EXISTS approver_group_members;
+DROP TABLE IF EXISTS approval_stages;
+DROP TABLE IF EXISTS access_requests;
+DROP TABLE IF EXISTS admin_setup_tokens;
+DROP TABLE IF EXISTS admin;
+DROP TABLE IF EXISTS pass_reset_logs;
+
+DROP PROCEDURE IF EXISTS purge_stale_rows;
+DROP PROCEDURE IF EXISTS create_server_procedures_table;
+DROP PROCEDURE IF EXISTS create_topology_overrides_table;
+DROP PROCEDURE IF EXISTS create_database_servers_table;
+DROP PROCEDURE IF EXISTS create_idempotency_keys_table;
+DROP FUNCTION IF EXISTS get_password_job;
+DROP PROCEDURE IF EXISTS create_password_jobs_tables;
+DROP FUNCTION IF EXISTS get_partial_pass_resets;
+DROP FUNCTION IF EXISTS get_pass_reset_server_attempts;
+DROP PROCEDURE IF EXISTS log_pass_reset_server_attempt;
+DROP PROCEDURE IF EXISTS create_pass_reset_server_attempts_table;
+DROP PROCEDURE IF EXISTS clear_login_failures;
+DROP PROCEDURE IF EXISTS release_login_attempt;
+DROP FUNCTION IF EXISTS claim_login_attempt;
+DROP FUNCTION IF EXISTS check_login_lockout;
+DROP FUNCTION IF EXISTS record_login_failure;
+DROP PROCEDURE IF EXISTS create_login_attempts_table;
+DROP FUNCTION IF EXISTS use_admin_recovery_code;
+DROP PROCEDURE IF EXISTS create_admin_mfa_tables;
+DROP PROCEDURE IF EXISTS create_admin_sessions_table;
+DROP FUNCTION IF EXISTS get_all_temporary_grants;
+DROP FUNCTION IF EXISTS get_all_temporary_access_requests;
+DROP PROCEDURE IF EXISTS create_temporary_grants_tables;
+DROP FUNCTION IF EXISTS get_access_request_decisions;
+DROP FUNCTION IF EXISTS get_approval_stages;
+DROP PROCEDURE IF EXISTS create_approval_workflow_tables;
+DROP FUNCTION IF EXISTS get_all_access_requests;
+DROP FUNCTION IF EXISTS log_access_request;
+DROP PROCEDURE IF EXISTS create_access_requests_table;
+DROP FUNCTION IF EXISTS get_all_logs;
+DROP FUNCTION IF EXISTS check_admin_credentials;
+DROP FUNCTION IF EXISTS set_admin_role;
+DROP FUNCTION IF EXISTS get_all_admins;
+DROP FUNCTION IF EXISTS delete_admin;
+DROP FUNCTION IF EXISTS force_admin_password_change;
+DROP FUNCTION IF EXISTS set_admin_disabled;
+DROP FUNCTION IF EXISTS change_admin_password;
+DROP FUNCTION IF EXISTS insert_admin_with_hash;
+DROP FUNCTION IF EXISTS insert_into_admin;
+DROP PROCEDURE IF EXISTS update_pass_reset_logs;
+DROP PROCEDURE IF EXISTS log_updates;
+DROP PROCEDURE IF EXISTS create_admin_table;
+DROP PROCEDURE IF EXISTS create_pass_reset_logs_table;
@@ -0,0 +1,1037 @@
+-- Baseline schema of the backend. It also brings databases created by postgres_init.sql up to date, which is
+-- why every table is created only if it does not exist yet.
+
+-- Procedure to create the pass_reset_logs table, the audit history is kept across restarts
+DROP PROCEDURE IF EXISTS create_pass_reset_logs_table;
+CREATE OR REPLACE PROCEDURE create_pass_reset_logs_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS pass_reset_logs (
+        id SERIAL PRIMARY KEY,
+        username TEXT NOT NULL,
+        serverIP TEXT NOT NULL,
+        request_type TEXT NOT NULL DEFAULT 'Password Reset',
+        request_status TEXT DEFAULT 'Pending',
+        message TEXT,
+        correlation_id TEXT, -- X-Request-ID of the request that wrote the row
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata') 
+    );
+    -- pass_reset_logs tables created by postgres_init.sql lack the column
+    ALTER TABLE pass_reset_logs ADD COLUMN IF NOT EXISTS correlation_id TEXT;
+    CREATE INDEX IF NOT EXISTS pass_reset_logs_correlation_id_idx ON pass_reset_logs (correlation_id);
+END;
+$$;
+
+-- Procedure to create the admin table, admins are kept across restarts
+DROP PROCEDURE IF EXISTS create_admin_table;
+CREATE OR REPLACE PROCEDURE create_admin_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS admin (
+        id SERIAL PRIMARY KEY,
+        username TEXT NOT NULL UNIQUE,     
+        password_hash TEXT NOT NULL,
+        role TEXT NOT NULL DEFAULT 'viewer' CHECK (role IN ('viewer', 'operator', 'approver', 'superadmin')),
+        disabled BOOLEAN NOT NULL DEFAULT FALSE,
+        must_change_password BOOLEAN NOT NULL DEFAULT FALSE,
+        password_last_updated TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        last_login TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata')
+    );
+
+    -- admin tables created by older versions lack these columns
+    ALTER TABLE admin ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'viewer' CHECK (role IN ('viewer', 'operator', 'approver', 'superadmin'));
+    ALTER TABLE admin ADD COLUMN IF NOT EXISTS disabled BOOLEAN NOT NULL DEFAULT FALSE;
+    ALTER TABLE admin ADD COLUMN IF NOT EXISTS must_change_password BOOLEAN NOT NULL DEFAULT FALSE;
+
+    -- postgres_init.sql seeded admin/admin123 on every start, a known password must not stay usable
+    UPDATE admin SET disabled = TRUE, must_change_password = TRUE
+    WHERE username = 'admin' AND password_hash = crypt('admin123', password_hash);
+
+    -- one-time tokens used to create the first admin on an empty installation
+    CREATE TABLE IF NOT EXISTS admin_setup_tokens (
+        token_hash TEXT PRIMARY KEY,
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        expires_at TIMESTAMPTZ NOT NULL,
+        used_at TIMESTAMPTZ
+    );
+END;
+$$;
+
+-- Procedure to create the pass_reset_logs table, used for logging password reset requests
+DROP PROCEDURE IF EXISTS log_updates;
+CREATE OR REPLACE PROCEDURE log_updates(
+    IN uname TEXT, 
+    IN ser_ip TEXT, 
+    IN req_type TEXT, 
+    IN req_status TEXT, 
+    IN msg TEXT,
+    IN corr_id TEXT DEFAULT NULL
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    INSERT INTO pass_reset_logs (username, serverIP, request_type, request_status, message, correlation_id, created_at)
+    VALUES (uname, ser_ip, req_type, req_status, msg, NULLIF(corr_id, ''), CURRENT_TIMESTAMP);
+END;
+$$;
+
+-- used for updating password reset requests, only the row written by the same request is touched
+DROP PROCEDURE IF EXISTS update_pass_reset_logs;
+CREATE OR REPLACE PROCEDURE update_pass_reset_logs(
+    IN corr_id TEXT, 
+    IN req_type TEXT, 
+    IN req_status TEXT, 
+    IN msg TEXT
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE pass_reset_logs SET 
+        request_status = req_status,
+        message = msg,
+        created_at = CURRENT_TIMESTAMP
+    WHERE correlation_id = corr_id AND request_type = req_type;
+END;
+$$;
+
+-- Procedure to insert admin cerdentials into the admin table
+CREATE EXTENSION IF NOT EXISTS pgcrypto; -- used for hashing and comparing passwords
+
+DROP FUNCTION IF EXISTS insert_into_admin;
+CREATE FUNCTION insert_into_admin(IN uname TEXT, IN pass TEXT, IN admin_role TEXT DEFAULT 'viewer')
+RETURNS VOID
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    INSERT INTO admin (username, password_hash, role)
+    VALUES (uname, crypt(pass, gen_salt('bf')), admin_role);
+END;
+$$;
+
+-- Function to insert an admin whose password was hashed elsewhere with crypt(), e.g. the bootstrap admin
+DROP FUNCTION IF EXISTS insert_admin_with_hash;
+CREATE FUNCTION insert_admin_with_hash(IN uname TEXT, IN pass_hash TEXT, IN admin_role TEXT)
+RETURNS VOID
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    INSERT INTO admin (username, password_hash, role)
+    VALUES (uname, pass_hash, admin_role);
+END;
+$$;
+
+-- Function to change the password of an admin, clears a pending forced password change
+DROP FUNCTION IF EXISTS change_admin_password;
+CREATE FUNCTION change_admin_password(IN uname TEXT, IN new_pass TEXT)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE admin SET
+        password_hash = crypt(new_pass, gen_salt('bf')),
+        must_change_password = FALSE,
+        password_last_updated = CURRENT_TIMESTAMP
+    WHERE username = uname;
+    RETURN FOUND;
+END;
+$$;
+
+-- Function to enable or disable an admin, returns false if the admin does not exist
+DROP FUNCTION IF EXISTS set_admin_disabled;
+CREATE FUNCTION set_admin_disabled(IN uname TEXT, IN is_disabled BOOLEAN)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE admin SET disabled = is_disabled WHERE username = uname;
+    RETURN FOUND;
+END;
+$$;
+
+-- Function to make an admin change their password on next use, returns false if the admin does not exist
+DROP FUNCTION IF EXISTS force_admin_password_change;
+CREATE FUNCTION force_admin_password_change(IN uname TEXT)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE admin SET must_change_password = TRUE WHERE username = uname;
+    RETURN FOUND;
+END;
+$$;
+
+-- Function to delete an admin together with their sessions, returns false if the admin does not exist
+DROP FUNCTION IF EXISTS delete_admin;
+CREATE FUNCTION delete_admin(IN uname TEXT)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    DELETE FROM admin WHERE username = uname;
+    IF NOT FOUND THEN
+        RETURN FALSE;
+    END IF;
+    DELETE FROM admin_sessions WHERE username = uname;
+    RETURN TRUE;
+END;
+$$;
+
+-- Function to get all admins in the form of a table, password hashes are not returned
+DROP FUNCTION IF EXISTS get_all_admins;
+CREATE OR REPLACE FUNCTION get_all_admins()
+RETURNS TABLE (
+    username TEXT,
+    role TEXT,
+    disabled BOOLEAN,
+    must_change_password BOOLEAN,
+    password_last_updated TIMESTAMPTZ,
+    last_login TIMESTAMPTZ,
+    created_at TIMESTAMPTZ
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        SELECT a.username, a.role, a.disabled, a.must_change_password, a.password_last_updated, a.last_login, a.created_at
+        FROM admin a
+        ORDER BY a.username;
+END;
+$$;
+
+-- Function to change the role of an admin, returns false if the admin does not exist
+DROP FUNCTION IF EXISTS set_admin_role;
+CREATE FUNCTION set_admin_role(IN uname TEXT, IN admin_role TEXT)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE admin SET role = admin_role WHERE username = uname;
+    RETURN FOUND;
+END;
+$$;
+
+-- Procedure to validate admin credentials given by the admin user
+DROP FUNCTION IF EXISTS check_admin_credentials;
+CREATE FUNCTION check_admin_credentials(ad_uname TEXT, ad_pass TEXT)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    admin_exists BOOLEAN;
+BEGIN
+    SELECT EXISTS (
+        SELECT 1 FROM admin
+        WHERE username = ad_uname
+        AND password_hash = crypt(ad_pass, password_hash)
+        AND NOT disabled
+    ) INTO admin_exists;
+
+    IF admin_exists THEN
+        UPDATE admin SET last_login = CURRENT_TIMESTAMP WHERE username = ad_uname;
+    END IF;
+
+    RETURN admin_exists;
+END;
+$$;
+
+
+-- Procedure to get all logs from the pass_reset_logs table in the form of a table
+DROP FUNCTION IF EXISTS get_all_logs;
+CREATE OR REPLACE FUNCTION get_all_logs()
+RETURNS TABLE (
+    id INT,
+    username TEXT,
+    serverIP TEXT,
+    request_type TEXT,
+    request_status TEXT,
+    message TEXT,
+    correlation_id TEXT,
+    created_at TIMESTAMPTZ
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY 
+        SELECT 
+            p.id, p.username, p.serverIP, p.request_type, p.request_status, p.message,
+            p.correlation_id, p.created_at
+        FROM pass_reset_logs p;
+END;
+$$;
+
+-- Procedure to create the access_requests table, used for database access requests raised from the access-control page
+DROP PROCEDURE IF EXISTS create_access_requests_table;
+CREATE OR REPLACE PROCEDURE create_access_requests_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS access_requests (
+        id SERIAL PRIMARY KEY,
+        username TEXT NOT NULL,
+        database_name TEXT NOT NULL,
+        access_level TEXT NOT NULL,
+        reason TEXT,
+        request_status TEXT NOT NULL DEFAULT 'Pending',
+        reviewed_by TEXT,
+        message TEXT,
+        current_stage INT NOT NULL DEFAULT 1,
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        reviewed_at TIMESTAMPTZ
+    );
+END;
+$$;
+
+-- Function to record a new access request, returns the id of the request
+DROP FUNCTION IF EXISTS log_access_request;
+CREATE FUNCTION log_access_request(IN uname TEXT, IN db_name TEXT, IN acc_level TEXT, IN req_reason TEXT)
+RETURNS INT
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    new_id INT;
+BEGIN
+    INSERT INTO access_requests (username, database_name, access_level, reason, created_at)
+    VALUES (uname, db_name, acc_level, req_reason, CURRENT_TIMESTAMP)
+    RETURNING id INTO new_id;
+
+    RETURN new_id;
+END;
+$$;
+
+-- Function to get all access requests in the form of a table
+DROP FUNCTION IF EXISTS get_all_access_requests;
+CREATE OR REPLACE FUNCTION get_all_access_requests()
+RETURNS TABLE (
+    id INT,
+    username TEXT,
+    database_name TEXT,
+    access_level TEXT,
+    reason TEXT,
+    request_status TEXT,
+    reviewed_by TEXT,
+    message TEXT,
+    current_stage INT,
+    created_at TIMESTAMPTZ,
+    reviewed_at TIMESTAMPTZ
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        SELECT
+            a.id, a.username, a.database_name, a.access_level, a.reason, a.request_status,
+            a.reviewed_by, a.message, a.current_stage, a.created_at, a.reviewed_at
+        FROM access_requests a
+        ORDER BY a.id DESC;
+END;
+$$;
+
+
+-- Procedure to create the tables backing the multi-stage approval workflow for access requests
+DROP PROCEDURE IF EXISTS create_approval_workflow_tables;
+CREATE OR REPLACE PROCEDURE create_approval_workflow_tables()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS approval_stages (
+        id SERIAL PRIMARY KEY,
+        access_level TEXT NOT NULL,
+        stage_order INT NOT NULL,
+        stage_name TEXT NOT NULL,
+        approver_group TEXT NOT NULL,
+        UNIQUE (access_level, stage_order)
+    );
+
+    CREATE TABLE IF NOT EXISTS approver_group_members (
+        group_name TEXT NOT NULL,
+        approver TEXT NOT NULL,
+        added_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        PRIMARY KEY (group_name, approver)
+    );
+
+    CREATE TABLE IF NOT EXISTS access_request_decisions (
+        id SERIAL PRIMARY KEY,
+        request_id INT NOT NULL REFERENCES access_requests(id) ON DELETE CASCADE,
+        stage_order INT NOT NULL,
+        stage_name TEXT NOT NULL,
+        approver_group TEXT NOT NULL,
+        approver TEXT NOT NULL,
+        decision TEXT NOT NULL,
+        comment TEXT,
+        decided_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata')
+    );
+
+    -- default stages: data owner first, then the DBA on-call
+    INSERT INTO approval_stages (access_level, stage_order, stage_name, approver_group)
+    VALUES
+        ('read', 1, 'Data Owner', 'data-owners'),
+        ('read', 2, 'DBA On-Call', 'dba-oncall'),
+        ('write', 1, 'Data Owner', 'data-owners'),
+        ('write', 2, 'DBA On-Call', 'dba-oncall'),
+        ('admin', 1, 'Data Owner', 'data-owners'),
+        ('admin', 2, 'DBA On-Call', 'dba-oncall')
+    ON CONFLICT (access_level, stage_order) DO NOTHING;
+END;
+$$;
+
+-- Function to get the configured approval stages in the form of a table
+DROP FUNCTION IF EXISTS get_approval_stages;
+CREATE OR REPLACE FUNCTION get_approval_stages()
+RETURNS TABLE (
+    access_level TEXT,
+    stage_order INT,
+    stage_name TEXT,
+    approver_group TEXT
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        SELECT s.access_level, s.stage_order, s.stage_name, s.approver_group
+        FROM approval_stages s
+        ORDER BY s.access_level, s.stage_order;
+END;
+$$;
+
+-- Function to get the decisions taken on an access request in the form of a table
+DROP FUNCTION IF EXISTS get_access_request_decisions;
+CREATE OR REPLACE FUNCTION get_access_request_decisions(IN req_id INT)
+RETURNS TABLE (
+    stage_order INT,
+    stage_name TEXT,
+    approver_group TEXT,
+    approver TEXT,
+    decision TEXT,
+    comment TEXT,
+    decided_at TIMESTAMPTZ
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        SELECT d.stage_order, d.stage_name, d.approver_group, d.approver, d.decision, d.comment, d.decided_at
+        FROM access_request_decisions d
+        WHERE d.request_id = req_id
+        ORDER BY d.id;
+END;
+$$;
+
+-- Procedure to create the tables for time-boxed (just-in-time) database access grants. A request is granted
+-- once an admin approves it and its window starts with the approval, so a pending request only records the
+-- requested duration. One temporary_grants row is kept per server so that revocation can be retried per
+-- replica, already_member records that the login held the role before and the revoker leaves it in place.
+DROP PROCEDURE IF EXISTS create_temporary_grants_tables;
+CREATE OR REPLACE PROCEDURE create_temporary_grants_tables()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS temporary_access_requests (
+        id SERIAL PRIMARY KEY,
+        username TEXT NOT NULL,
+        email TEXT NOT NULL,
+        serverIP TEXT NOT NULL,
+        database_name TEXT NOT NULL,
+        role_name TEXT NOT NULL,
+        duration_seconds INT NOT NULL,
+        request_status TEXT NOT NULL DEFAULT 'Pending' CHECK (request_status IN ('Pending', 'Approved', 'Rejected')),
+        reviewed_by TEXT,
+        message TEXT,
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        reviewed_at TIMESTAMPTZ,
+        expires_at TIMESTAMPTZ
+    );
+
+    CREATE TABLE IF NOT EXISTS temporary_grants (
+        id SERIAL PRIMARY KEY,
+        request_id INT NOT NULL REFERENCES temporary_access_requests(id) ON DELETE CASCADE,
+        server TEXT NOT NULL,
+        grant_status TEXT NOT NULL DEFAULT 'Granting',
+        already_member BOOLEAN NOT NULL DEFAULT FALSE,
+        granted_at TIMESTAMPTZ,
+        revoked_at TIMESTAMPTZ,
+        revoke_attempts INT NOT NULL DEFAULT 0,
+        next_attempt_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+        last_error TEXT
+    );
+
+    CREATE INDEX IF NOT EXISTS temporary_grants_pending_idx
+        ON temporary_grants (next_attempt_at) WHERE grant_status <> 'Revoked';
+END;
+$$;
+
+-- Function to get all temporary access requests in the form of a table, the newest first
+DROP FUNCTION IF EXISTS get_all_temporary_access_requests;
+CREATE OR REPLACE FUNCTION get_all_temporary_access_requests()
+RETURNS TABLE (
+    id INT,
+    username TEXT,
+    serverIP TEXT,
+    database_name TEXT,
+    role_name TEXT,
+    duration_seconds INT,
+    request_status TEXT,
+    reviewed_by TEXT,
+    message TEXT,
+    created_at TIMESTAMPTZ,
+    reviewed_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        SELECT r.id, r.username, r.serverIP, r.database_name, r.role_name, r.duration_seconds, r.request_status,
+            r.reviewed_by, r.message, r.created_at, r.reviewed_at, r.expires_at
+        FROM temporary_access_requests r
+        ORDER BY r.id DESC;
+END;
+$$;
+
+-- Function to get all temporary grants in the form of a table
+DROP FUNCTION IF EXISTS get_all_temporary_grants;
+CREATE OR REPLACE FUNCTION get_all_temporary_grants()
+RETURNS TABLE (
+    id INT,
+    request_id INT,
+    username TEXT,
+    database_name TEXT,
+    role_name TEXT,
+    server TEXT,
+    grant_status TEXT,
+    already_member BOOLEAN,
+    granted_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    revoke_attempts INT,
+    last_error TEXT
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        SELECT g.id, g.request_id, r.username, r.database_name, r.role_name, g.server, g.grant_status,
+            g.already_member, g.granted_at, r.expires_at, g.revoked_at, g.revoke_attempts, g.last_error
+        FROM temporary_grants g
+        JOIN temporary_access_requests r ON r.id = g.request_id
+        ORDER BY g.id DESC;
+END;
+$$;
+
+-- Procedure to create the admin_sessions table, only a hash of the session token is stored
+DROP PROCEDURE IF EXISTS create_admin_sessions_table;
+CREATE OR REPLACE PROCEDURE create_admin_sessions_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS admin_sessions (
+        token_hash TEXT PRIMARY KEY,
+        username TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        expires_at TIMESTAMPTZ NOT NULL,
+        absolute_expires_at TIMESTAMPTZ NOT NULL,
+        revoked_at TIMESTAMPTZ
+    );
+END;
+$$;
+
+-- Procedure to create the tables used for TOTP two-factor authentication of admins
+DROP PROCEDURE IF EXISTS create_admin_mfa_tables;
+CREATE OR REPLACE PROCEDURE create_admin_mfa_tables()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    ALTER TABLE admin ADD COLUMN IF NOT EXISTS totp_secret TEXT;
+    ALTER TABLE admin ADD COLUMN IF NOT EXISTS totp_pending_secret TEXT;
+    ALTER TABLE admin ADD COLUMN IF NOT EXISTS totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+    ALTER TABLE admin ADD COLUMN IF NOT EXISTS totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+    -- recovery codes are hashed with crypt(), like admin passwords
+    CREATE TABLE IF NOT EXISTS admin_recovery_codes (
+        id SERIAL PRIMARY KEY,
+        username TEXT NOT NULL REFERENCES admin(username) ON DELETE CASCADE,
+        code_hash TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        used_at TIMESTAMPTZ
+    );
+
+    -- second login step, issued after the password was verified
+    CREATE TABLE IF NOT EXISTS admin_mfa_challenges (
+        token_hash TEXT PRIMARY KEY,
+        username TEXT NOT NULL REFERENCES admin(username) ON DELETE CASCADE,
+        expires_at TIMESTAMPTZ NOT NULL,
+        attempts INT NOT NULL DEFAULT 0,
+        used_at TIMESTAMPTZ
+    );
+END;
+$$;
+
+-- Function to consume an unused recovery code of an admin, returns false if no code matched
+DROP FUNCTION IF EXISTS use_admin_recovery_code;
+CREATE FUNCTION use_admin_recovery_code(IN uname TEXT, IN code TEXT)
+RETURNS BOOLEAN
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    code_id INT;
+BEGIN
+    SELECT id INTO code_id FROM admin_recovery_codes
+    WHERE username = uname AND used_at IS NULL AND code_hash = crypt(code, code_hash)
+    LIMIT 1
+    FOR UPDATE;
+
+    IF code_id IS NULL THEN
+        RETURN FALSE;
+    END IF;
+
+    UPDATE admin_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE id = code_id;
+    RETURN TRUE;
+END;
+$$;
+
+-- Procedure to create the login_attempts table, failed logins are counted per username and per client IP
+DROP PROCEDURE IF EXISTS create_login_attempts_table;
+CREATE OR REPLACE PROCEDURE create_login_attempts_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS login_attempts (
+        scope TEXT NOT NULL,
+        key_type TEXT NOT NULL,
+        key TEXT NOT NULL,
+        failures INT NOT NULL DEFAULT 0,
+        locked_until TIMESTAMPTZ,
+        last_failure_at TIMESTAMPTZ,
+        PRIMARY KEY (scope, key_type, key)
+    );
+END;
+$$;
+
+-- Function to record a failed login. Once the threshold is reached the key is locked for
+-- base_seconds, doubling with every further failure up to max_seconds. Failures older than
+-- reset_seconds are forgotten. Returns the time the key is locked until, or NULL.
+DROP FUNCTION IF EXISTS record_login_failure;
+CREATE FUNCTION record_login_failure(
+    IN att_scope TEXT,
+    IN att_key_type TEXT,
+    IN att_key TEXT,
+    IN threshold INT,
+    IN base_seconds INT,
+    IN max_seconds INT,
+    IN reset_seconds INT
+)
+RETURNS TIMESTAMPTZ
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    new_failures INT;
+    lock_until TIMESTAMPTZ;
+BEGIN
+    INSERT INTO login_attempts AS a (scope, key_type, key, failures, last_failure_at)
+    VALUES (att_scope, att_key_type, att_key, 1, CURRENT_TIMESTAMP)
+    ON CONFLICT (scope, key_type, key) DO UPDATE SET
+        failures = CASE
+            WHEN a.last_failure_at < CURRENT_TIMESTAMP - reset_seconds * INTERVAL '1 second' THEN 1
+            ELSE a.failures + 1
+        END,
+        last_failure_at = CURRENT_TIMESTAMP
+    RETURNING failures INTO new_failures;
+
+    IF new_failures < threshold THEN
+        RETURN NULL;
+    END IF;
+
+    lock_until := CURRENT_TIMESTAMP + LEAST(
+        base_seconds * POWER(2, LEAST(new_failures - threshold, 20)),
+        max_seconds
+    ) * INTERVAL '1 second';
+
+    UPDATE login_attempts SET locked_until = lock_until
+    WHERE scope = att_scope AND key_type = att_key_type AND key = att_key;
+
+    RETURN lock_until;
+END;
+$$;
+
+-- Function to get the time a key is locked until, returns NULL if it is not locked
+DROP FUNCTION IF EXISTS check_login_lockout;
+CREATE FUNCTION check_login_lockout(IN att_scope TEXT, IN att_key_type TEXT, IN att_key TEXT)
+RETURNS TIMESTAMPTZ
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    lock_until TIMESTAMPTZ;
+BEGIN
+    SELECT locked_until INTO lock_until FROM login_attempts
+    WHERE scope = att_scope AND key_type = att_key_type AND key = att_key
+    AND locked_until > CURRENT_TIMESTAMP;
+
+    RETURN lock_until;
+END;
+$$;
+
+-- Function to claim an attempt for a key. Unless the key is locked, the attempt is counted as a failure
+-- straight away and locks the key once failures reach threshold, all in one statement, so concurrent
+-- attempts cannot all pass the check before any of them is counted. A successful attempt gives its
+-- claim back with release_login_attempt. Returns NULL when the attempt may go ahead, otherwise the time
+-- the key is locked until, and whether this attempt locked the key.
+DROP FUNCTION IF EXISTS claim_login_attempt;
+CREATE FUNCTION claim_login_attempt(
+    IN att_scope TEXT,
+    IN att_key_type TEXT,
+    IN att_key TEXT,
+    IN threshold INT,
+    IN base_seconds INT,
+    IN max_seconds INT,
+    IN reset_seconds INT,
+    OUT locked_until TIMESTAMPTZ,
+    OUT locks_key BOOLEAN
+)
+LANGUAGE plpgsql
+AS $$
+DECLARE
+    new_lock TIMESTAMPTZ;
+BEGIN
+    INSERT INTO login_attempts AS a (scope, key_type, key, failures, last_failure_at, locked_until)
+    VALUES (att_scope, att_key_type, att_key, 1, CURRENT_TIMESTAMP,
+        CASE WHEN threshold <= 1 THEN CURRENT_TIMESTAMP + LEAST(base_seconds, max_seconds) * INTERVAL '1 second' END)
+    ON CONFLICT (scope, key_type, key) DO UPDATE SET
+        failures = CASE
+            WHEN a.last_failure_at < CURRENT_TIMESTAMP - reset_seconds * INTERVAL '1 second' THEN 1
+            ELSE a.failures + 1
+        END,
+        last_failure_at = CURRENT_TIMESTAMP,
+        locked_until = CASE
+            WHEN a.last_failure_at < CURRENT_TIMESTAMP - reset_seconds * INTERVAL '1 second' THEN NULL
+            WHEN a.failures + 1 >= threshold THEN CURRENT_TIMESTAMP + LEAST(
+                base_seconds * POWER(2, LEAST(a.failures + 1 - threshold, 20)),
+                max_seconds
+            ) * INTERVAL '1 second'
+        END
+    WHERE a.locked_until IS NULL OR a.locked_until <= CURRENT_TIMESTAMP
+    RETURNING a.locked_until INTO new_lock;
+
+    IF FOUND THEN
+        locked_until := NULL;
+        locks_key := new_lock IS NOT NULL;
+        RETURN;
+    END IF;
+
+    SELECT a.locked_until INTO locked_until FROM login_attempts a
+    WHERE a.scope = att_scope AND a.key_type = att_key_type AND a.key = att_key;
+    locks_key := FALSE;
+END;
+$$;
+
+-- Procedure to give back the claim of a successful attempt. The failure it counted is removed, and the
+-- lock it set when it reached threshold is lifted.
+DROP PROCEDURE IF EXISTS release_login_attempt;
+CREATE OR REPLACE PROCEDURE release_login_attempt(IN att_scope TEXT, IN att_key_type TEXT, IN att_key TEXT, IN threshold INT)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE login_attempts SET
+        failures = GREATEST(failures - 1, 0),
+        locked_until = CASE WHEN failures - 1 < threshold THEN NULL ELSE locked_until END
+    WHERE scope = att_scope AND key_type = att_key_type AND key = att_key;
+END;
+$$;
+
+-- Procedure to forget the failed logins of a key after a successful login
+DROP PROCEDURE IF EXISTS clear_login_failures;
+CREATE OR REPLACE PROCEDURE clear_login_failures(IN att_scope TEXT, IN att_key_type TEXT, IN att_key TEXT)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    DELETE FROM login_attempts WHERE scope = att_scope AND key_type = att_key_type AND key = att_key;
+END;
+$$;
+
+-- Procedure to create the table of per-server outcomes of a password update, linked to pass_reset_logs by correlation_id
+DROP PROCEDURE IF EXISTS create_pass_reset_server_attempts_table;
+CREATE OR REPLACE PROCEDURE create_pass_reset_server_attempts_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS pass_reset_server_attempts (
+        id SERIAL PRIMARY KEY,
+        correlation_id TEXT NOT NULL,
+        server TEXT NOT NULL,
+        is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+        operation TEXT NOT NULL DEFAULT 'Update' CHECK (operation IN ('Update', 'Rollback')),
+        attempt INT NOT NULL DEFAULT 1,
+        attempt_status TEXT NOT NULL,
+        error TEXT,
+        duration_ms INT NOT NULL DEFAULT 0,
+        attempted_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata')
+    );
+    CREATE INDEX IF NOT EXISTS pass_reset_server_attempts_correlation_id_idx ON pass_reset_server_attempts (correlation_id);
+END;
+$$;
+
+-- Procedure to record the outcome of a password update on one server
+DROP PROCEDURE IF EXISTS log_pass_reset_server_attempt;
+CREATE OR REPLACE PROCEDURE log_pass_reset_server_attempt(
+    IN corr_id TEXT,
+    IN server_name TEXT,
+    IN primary_server BOOLEAN,
+    IN op TEXT,
+    IN attempt_no INT,
+    IN att_status TEXT,
+    IN err TEXT,
+    IN duration INT
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    INSERT INTO pass_reset_server_attempts (correlation_id, server, is_primary, operation, attempt, attempt_status, error, duration_ms, attempted_at)
+    VALUES (corr_id, server_name, primary_server, op, attempt_no, att_status, NULLIF(err, ''), duration, CURRENT_TIMESTAMP);
+END;
+$$;
+
+-- Function to get the per-server breakdown of one password update
+DROP FUNCTION IF EXISTS get_pass_reset_server_attempts;
+CREATE OR REPLACE FUNCTION get_pass_reset_server_attempts(IN corr_id TEXT)
+RETURNS TABLE (
+    server TEXT,
+    is_primary BOOLEAN,
+    operation TEXT,
+    attempt INT,
+    attempt_status TEXT,
+    error TEXT,
+    duration_ms INT,
+    attempted_at TIMESTAMPTZ
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        SELECT a.server, a.is_primary, a.operation, a.attempt, a.attempt_status, a.error, a.duration_ms, a.attempted_at
+        FROM pass_reset_server_attempts a
+        WHERE a.correlation_id = corr_id
+        ORDER BY a.id;
+END;
+$$;
+
+-- Function to get password updates that left the login with the new password on some servers and the old one on others.
+-- The last attempt on each server decides which password it has.
+DROP FUNCTION IF EXISTS get_partial_pass_resets;
+CREATE OR REPLACE FUNCTION get_partial_pass_resets()
+RETURNS TABLE (
+    correlation_id TEXT,
+    username TEXT,
+    serverIP TEXT,
+    request_status TEXT,
+    message TEXT,
+    succeeded BIGINT,
+    failed BIGINT,
+    created_at TIMESTAMPTZ
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        WITH last_attempts AS (
+            SELECT DISTINCT ON (a.correlation_id, a.server) a.correlation_id, a.server, a.attempt_status
+            FROM pass_reset_server_attempts a
+            ORDER BY a.correlation_id, a.server, a.id DESC
+        )
+        SELECT p.correlation_id, p.username, p.serverIP, p.request_status, p.message,
+            COUNT(*) FILTER (WHERE l.attempt_status IN ('Success', 'Rollback Failed')),
+            COUNT(*) FILTER (WHERE l.attempt_status IN ('Failed', 'Rolled Back')),
+            p.created_at
+        FROM pass_reset_logs p
+        JOIN last_attempts l ON l.correlation_id = p.correlation_id
+        WHERE p.request_type = 'Password Update'
+        GROUP BY p.id
+        HAVING COUNT(*) FILTER (WHERE l.attempt_status IN ('Success', 'Rollback Failed')) > 0
+            AND COUNT(*) FILTER (WHERE l.attempt_status IN ('Failed', 'Rolled Back')) > 0
+        ORDER BY p.created_at DESC;
+END;
+$$;
+
+-- Procedure to create the queue of password update jobs and their steps. The passwords are stored encrypted
+-- and cleared once the job has finished.
+DROP PROCEDURE IF EXISTS create_password_jobs_tables;
+CREATE OR REPLACE PROCEDURE create_password_jobs_tables()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS password_jobs (
+        id TEXT PRIMARY KEY,
+        correlation_id TEXT,
+        username TEXT NOT NULL,
+        email TEXT NOT NULL,
+        server_ip TEXT NOT NULL,
+        database_name TEXT,
+        client_ip TEXT,
+        payload BYTEA,
+        job_status TEXT NOT NULL DEFAULT 'Queued' CHECK (job_status IN ('Queued', 'Running', 'Succeeded', 'Failed')),
+        message TEXT,
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        started_at TIMESTAMPTZ,
+        finished_at TIMESTAMPTZ
+    );
+
+    CREATE INDEX IF NOT EXISTS password_jobs_queued_idx
+        ON password_jobs (created_at) WHERE job_status = 'Queued';
+
+    CREATE TABLE IF NOT EXISTS password_job_steps (
+        job_id TEXT NOT NULL REFERENCES password_jobs(id) ON DELETE CASCADE,
+        step_order INT NOT NULL,
+        step_name TEXT NOT NULL,
+        step_status TEXT NOT NULL DEFAULT 'Pending',
+        message TEXT,
+        started_at TIMESTAMPTZ,
+        finished_at TIMESTAMPTZ,
+        PRIMARY KEY (job_id, step_name)
+    );
+END;
+$$;
+
+-- Function to get a password job with its steps, one row per step
+DROP FUNCTION IF EXISTS get_password_job;
+CREATE OR REPLACE FUNCTION get_password_job(IN job TEXT)
+RETURNS TABLE (
+    id TEXT,
+    correlation_id TEXT,
+    job_status TEXT,
+    message TEXT,
+    created_at TIMESTAMPTZ,
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    step_name TEXT,
+    step_status TEXT,
+    step_message TEXT,
+    step_started_at TIMESTAMPTZ,
+    step_finished_at TIMESTAMPTZ
+)
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    RETURN QUERY
+        SELECT j.id, j.correlation_id, j.job_status, j.message, j.created_at, j.started_at, j.finished_at,
+            s.step_name, s.step_status, s.message, s.started_at, s.finished_at
+        FROM password_jobs j
+        LEFT JOIN password_job_steps s ON s.job_id = j.id
+        WHERE j.id = job
+        ORDER BY s.step_order;
+END;
+$$;
+
+-- Procedure to create the table of Idempotency-Key results, a key is kept until expires_at
+DROP PROCEDURE IF EXISTS create_idempotency_keys_table;
+CREATE OR REPLACE PROCEDURE create_idempotency_keys_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS idempotency_keys (
+        scope TEXT NOT NULL,
+        idempotency_key TEXT NOT NULL,
+        fingerprint TEXT NOT NULL,
+        key_status TEXT NOT NULL DEFAULT 'In Progress' CHECK (key_status IN ('In Progress', 'Completed')),
+        status_code INT,
+        response_body BYTEA,
+        location TEXT,
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        expires_at TIMESTAMPTZ NOT NULL,
+        PRIMARY KEY (scope, idempotency_key)
+    );
+
+    CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
+END;
+$$;
+
+-- Procedure to create the database_servers table, the inventory of servers users can reset their passwords on.
+-- credential_ref names the admin credentials of the server, empty for the defaults of its engine.
+DROP PROCEDURE IF EXISTS create_database_servers_table;
+CREATE OR REPLACE PROCEDURE create_database_servers_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS database_servers (
+        id SERIAL PRIMARY KEY,
+        name TEXT NOT NULL UNIQUE,
+        host TEXT NOT NULL,
+        port INT NOT NULL CHECK (port BETWEEN 1 AND 65535),
+        engine TEXT NOT NULL CHECK (engine IN ('mssql', 'postgres', 'mysql')),
+        environment TEXT NOT NULL DEFAULT 'production',
+        availability_group TEXT,
+        credential_ref TEXT,
+        enabled BOOLEAN NOT NULL DEFAULT TRUE,
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        updated_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        UNIQUE (host, port)
+    );
+
+    CREATE INDEX IF NOT EXISTS database_servers_host_idx ON database_servers (host);
+END;
+$$;
+
+-- Procedure to create the topology_overrides table. An admin can pin a replica to a server so that it is
+-- always updated, or exclude one the availability group reports so that it is never touched. Overrides
+-- belong to an inventory entry, entries on different ports of a host have their own.
+DROP PROCEDURE IF EXISTS create_topology_overrides_table;
+CREATE OR REPLACE PROCEDURE create_topology_overrides_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS topology_overrides (
+        server_id INT NOT NULL REFERENCES database_servers (id) ON DELETE CASCADE,
+        replica_host TEXT NOT NULL,
+        action TEXT NOT NULL CHECK (action IN ('pin', 'exclude')),
+        created_by TEXT NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        PRIMARY KEY (server_id, replica_host)
+    );
+END;
+$$;
+
+-- Procedure to create the server_procedures table, the stored procedures deployed to the SQL Servers of the
+-- inventory with one row per inventory entry and procedure. checksum is the SHA-256 of the script that was
+-- deployed, a different definition on the server is drift.
+DROP PROCEDURE IF EXISTS create_server_procedures_table;
+CREATE OR REPLACE PROCEDURE create_server_procedures_table()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    CREATE TABLE IF NOT EXISTS server_procedures (
+        server_id INT NOT NULL REFERENCES database_servers (id) ON DELETE CASCADE,
+        procedure_name TEXT NOT NULL,
+        checksum TEXT NOT NULL,
+        deployed_by TEXT NOT NULL,
+        deployed_at TIMESTAMPTZ DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'Asia/Kolkata'),
+        PRIMARY KEY (server_id, procedure_name)
+    );
+END;
+$$;
+
+-- Procedure to delete rows that are of no use anymore, called on every start of the backend
+DROP PROCEDURE IF EXISTS purge_stale_rows;
+CREATE OR REPLACE PROCEDURE purge_stale_rows()
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    -- sessions that ended more than a day ago are of no use anymore
+    DELETE FROM admin_sessions WHERE absolute_expires_at < CURRENT_TIMESTAMP - INTERVAL '1 day';
+    DELETE FROM admin_mfa_challenges WHERE expires_at < CURRENT_TIMESTAMP - INTERVAL '1 day';
+    DELETE FROM login_attempts WHERE last_failure_at < CURRENT_TIMESTAMP - INTERVAL '7 days';
+END;
+$$;
+
+CALL create_pass_reset_logs_table();
+CALL create_pass_reset_server_attempts_table();
+CALL create_admin_table();
+CALL create_access_requests_table();
+CALL create_approval_workflow_tables();
+CALL create_temporary_grants_tables();
+CALL create_admin_sessions_table();
+CALL create_admin_mfa_tables();
+CALL create_login_attempts_table();
+CALL create_password_jobs_tables();
+CALL create_idempotency_keys_table();
+CALL create_database_servers_table();
+CALL create_topology_overrides_table();
+CALL create_server_procedures_table();
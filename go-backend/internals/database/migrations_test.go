@@ -0,0 +1,44 @@
+package database
+
+import (
+	"strings"
+	"testing"
+
+	"go-backend/internals/procedures"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	postgres, err := PostgresMigrator(nil)
+	if err != nil {
+		t.Fatalf("PostgreSQL migrations: %v", err)
+	}
+	mssql, err := MSSQLMigrator(nil)
+	if err != nil {
+		t.Fatalf("MSSQL migrations: %v", err)
+	}
+
+	for _, migration := range append(postgres.Migrations, mssql.Migrations...) {
+		if migration.Down == "" {
+			t.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+		}
+	}
+	// the procedures deployed to every server are only defined by their scripts in internals/procedures
+	set, err := procedures.Set()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, migration := range mssql.Migrations {
+		for _, procedure := range set {
+			if strings.Contains(migration.Up, "PROCEDURE "+procedure.Name) {
+				t.Errorf("migration %d_%s defines %s, which is installed from its script", migration.Version, migration.Name, procedure.Name)
+			}
+		}
+	}
+
+	// the audit history and the admins have to survive a restart
+	for _, migration := range postgres.Migrations {
+		if strings.Contains(strings.ToUpper(migration.Up), "DROP TABLE IF EXISTS PASS_RESET") {
+			t.Errorf("migration %d_%s drops an audit table", migration.Version, migration.Name)
+		}
+	}
+}
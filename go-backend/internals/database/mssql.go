@@ -1,96 +1,58 @@
 package database
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
-	"sync"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"go-backend/internals/config"
+	"go-backend/internals/secrets"
 
-	"github.com/joho/godotenv"
 	_ "github.com/microsoft/go-mssqldb"
 	"github.com/rs/zerolog/log"
 )
 
-var (
-	msdb *sql.DB
-	msdbInitOnce sync.Once
-)
-
-func ConnectMSSQL() (*sql.DB, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		return nil, fmt.Errorf("error loading .env file: %v", err)
-	}
-	log.Info().Msg("Loaded environment variables from .env file")
+// ConnectMSSQL opens a new connection pool to the central SQL Server. A credential reference in cfg is
+// resolved through refs. The caller must close the pool.
+func ConnectMSSQL(cfg config.MSSQLConfig, refs *secrets.Resolver) (*sql.DB, error) {
 	log.Info().Msg("Connecting to MS SQL Server...")
 
-	var err error
-	msdbInitOnce.Do(func() {
-		msconnStr := fmt.Sprintf("server=%s;user id=%s;password=%s;port=%s;database=%s",
-			os.Getenv("MS_DB_SERVER"),
-			os.Getenv("MS_DB_USER"),
-			os.Getenv("MS_DB_PASSWORD"),
-			os.Getenv("MS_DB_PORT"),
-			os.Getenv("MS_DB_NAME"))
-
-		msdb, err = sql.Open("mssql", msconnStr)
+	// a credential reference takes the credentials from a secrets provider instead of the configured user and password
+	user, password := cfg.User, cfg.Password
+	if ref := cfg.CredentialRef; ref != "" {
+		credentials, err := refs.Resolve(context.Background(), ref)
 		if err != nil {
-			return
-		}
-
-		if err = msdb.Ping(); err != nil {
-			return
+			return nil, err
 		}
-		log.Info().Msg("Connected to MS SQL Server successfully")
-
-		initMSSQL()
-	})
-
-	return msdb, err
+		user, password = credentials.Username, credentials.Password
+	}
+	msdb, err := sql.Open("mssql", mssqlConnURL(cfg, user, password))
+	if err != nil {
+		return nil, err
+	}
+	if err := msdb.Ping(); err != nil {
+		msdb.Close()
+		return nil, err
+	}
+	log.Info().Msg("Connected to MS SQL Server successfully")
+	return msdb, nil
 }
-func initMSSQL() {
-	directories := []string{"MSSQL-SP", "MSSQL-UDF"}
 
-	for _, dir := range directories {
-		log.Info().Msgf("Executing initialization scripts from directory: %s", dir)
-
-		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-
-			if !d.IsDir() && filepath.Ext(d.Name()) == ".sql" {
-				// log.Printf("Reading file: %s", path)
-
-				sqlContent, err := os.ReadFile(path)
-				if err != nil {
-					log.Fatal().Err(err).Msgf("Failed to read SQL script from file: %s", path)
-				}
-
-				log.Printf("Executing SQL script from: %s", path)
-				_, err = msdb.Exec(string(sqlContent))
-				if err != nil {
-					log.Fatal().Err(err).Msgf("Failed to execute SQL script from: %s", path)
-				}
-				// log.Printf("Successfully executed SQL script from: %s", path)
-			}
-
-			return nil
-		})
-
-		if err != nil {
-			log.Fatal().Err(err).Msgf("Failed to read initialization scripts from directory: %s", dir)
-		}
+// mssqlConnURL returns the URL connection string of the central SQL Server, which escapes credentials that
+// contain ; or = like the connections of the engine do. A server written as host\instance keeps its instance.
+func mssqlConnURL(cfg config.MSSQLConfig, user, password string) string {
+	host, instance, _ := strings.Cut(cfg.Server, `\`)
+	connURL := url.URL{
+		Scheme: "sqlserver",
+		User:   url.UserPassword(user, password),
+		Host:   net.JoinHostPort(host, strconv.Itoa(cfg.Port)),
+		Path:   instance,
 	}
-	log.Info().Msg("Successfully executed all initialization scripts")
-}
-func GetMSDB () *sql.DB {
-	if msdb == nil {
-		log.Fatal().Msg("MS SQL Server connection is not initialized")
-		log.Info().Msg("Initializing MS SQL Server connection...")
-		ConnectMSSQL()
+	if cfg.Name != "" {
+		connURL.RawQuery = url.Values{"database": {cfg.Name}}.Encode()
 	}
-
-	return msdb
-}
\ No newline at end of file
+	return connURL.String()
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"go-backend/internals/config"
+)
+
+func TestMSSQLConnURL(t *testing.T) {
+	for _, tc := range []struct {
+		server       string
+		wantHost     string
+		wantInstance string
+	}{
+		{"mssql.example.com", "mssql.example.com:1433", ""},
+		{`mssql.example.com\SALES`, "mssql.example.com:1433", "SALES"},
+	} {
+		cfg := config.MSSQLConfig{Server: tc.server, Port: 1433, Name: "master"}
+		raw := mssqlConnURL(cfg, "sa", "p;w=d@x/y:z")
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("connection URL %s: %v", raw, err)
+		}
+		password, _ := parsed.User.Password()
+		if parsed.Scheme != "sqlserver" || parsed.Host != tc.wantHost || parsed.User.Username() != "sa" || password != "p;w=d@x/y:z" {
+			t.Errorf("connection URL %s, want sa with its password on %s", raw, tc.wantHost)
+		}
+		if instance := strings.TrimPrefix(parsed.Path, "/"); instance != tc.wantInstance {
+			t.Errorf("instance = %q, want %q", instance, tc.wantInstance)
+		}
+		if got := parsed.Query().Get("database"); got != "master" {
+			t.Errorf("database = %q, want master", got)
+		}
+	}
+}
@@ -3,87 +3,52 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"os"
-	"sync"
 	"time"
 
-	"github.com/joho/godotenv"
+	"go-backend/internals/config"
+	"go-backend/internals/engine"
+
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 )
 
-var (
-	db *sql.DB
-	dbInitOnce sync.Once
-)
-func ConnectPostgres() (*sql.DB, error) {
-	if err := godotenv.Load(".env"); err != nil {
-		log.Error().Msgf("error loading .env file: %v", err)
-		return nil, err
-	}
-	log.Info().Msg("Loaded environment variables from .env file")
+// PostgresDSN is the connection string of the backend database, also used by connections outside the pool
+// such as the listener of the event relay.
+func PostgresDSN(cfg config.PostgresConfig) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		engine.QuoteConnValue(cfg.Host),
+		cfg.Port,
+		engine.QuoteConnValue(cfg.User),
+		engine.QuoteConnValue(cfg.Password),
+		engine.QuoteConnValue(cfg.Name),
+		engine.QuoteConnValue(cfg.SSLMode))
+}
+
+// ConnectPostgres opens a new connection pool to the backend database, retrying while the database starts
+// up. The caller must close the pool.
+func ConnectPostgres(cfg config.PostgresConfig) (*sql.DB, error) {
 	log.Info().Msg("Connecting to PostgreSQL...")
 
+	pgconnStr := PostgresDSN(cfg)
 	var err error
-	dbInitOnce.Do(func() {
-		pgconnStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-			os.Getenv("DB_HOST"),
-			os.Getenv("DB_PORT"),
-			os.Getenv("DB_USER"),
-			os.Getenv("DB_PASSWORD"),
-			os.Getenv("DB_NAME"))
-
-		for i := 0; i < 5; i++ {
-			db, err = sql.Open("postgres", pgconnStr)
-			if err != nil {
-				log.Info().Msgf("Attempt %d: Failed to connect to PostgreSQL: %v", i+1, err)
-				time.Sleep(2 * time.Second)
-				continue
-			}
-
-			time.Sleep(10 * time.Second)
-
-			if err = db.Ping(); err == nil {
-				break
-			}
-			log.Info().Msgf("Attempt %d: Failed to ping PostgreSQL: %v", i+1, err)
-			time.Sleep(2 * time.Second)
-		}
-
+	for i := 0; i < 5; i++ {
+		var db *sql.DB
+		db, err = sql.Open("postgres", pgconnStr)
 		if err != nil {
-			return
+			log.Info().Msgf("Attempt %d: Failed to connect to PostgreSQL: %v", i+1, err)
+			time.Sleep(2 * time.Second)
+			continue
 		}
-		log.Info().Msg("Connected to PostgreSQL successfully")
-		initPostgres()
-	})
 
-	return db, err
-}
-
-func initPostgres() {
-	_, err := db.Exec("CALL create_pass_reset_logs_table()")
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create password reset logs table")
-	}
-	log.Info().Msg("Password reset logs table created successfully")
+		time.Sleep(10 * time.Second)
 
-	_, err = db.Exec("CALL create_admin_table()")
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to create admin table")
-	}
-	log.Info().Msg("Admin table created successfully")
-
-	_, err = db.Exec("SELECT insert_into_admin($1, $2)", "admin", "admin123")
-	if err != nil {
-		log.Fatal().Err(err).Msg("Failed to insert values into the admin table")
+		if err = db.Ping(); err == nil {
+			log.Info().Msg("Connected to PostgreSQL successfully")
+			return db, nil
+		}
+		db.Close()
+		log.Info().Msgf("Attempt %d: Failed to ping PostgreSQL: %v", i+1, err)
+		time.Sleep(2 * time.Second)
 	}
-	log.Info().Msg("Values inserted into admin table successfully")
+	return nil, err
 }
-func GetDB() *sql.DB {
-	if db == nil {
-		log.Fatal().Msg("Postgres Database connection is nil")
-		log.Info().Msg("Initializing connection to PostgreSQL...")
-		ConnectPostgres()
-	}
-	return db
-}
\ No newline at end of file
@@ -0,0 +1,15 @@
+package database
+
+import (
+	"testing"
+
+	"go-backend/internals/config"
+)
+
+func TestPostgresDSN(t *testing.T) {
+	got := PostgresDSN(config.PostgresConfig{Host: "db", Port: 5432, User: "backend", Password: `it's \ spaced`, Name: "dba", SSLMode: "disable"})
+	want := `host='db' port=5432 user='backend' password='it\'s \\ spaced' dbname='dba' sslmode='disable'`
+	if got != want {
+		t.Errorf("connection string = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,121 @@
+// Package engine holds the database engines that users can reset their passwords on. Every engine
+// implements the same steps of a password reset, the engine of a server is taken from the inventory.
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go-backend/internals/config"
+	"go-backend/internals/pkg"
+	"go-backend/internals/secrets"
+	"go-backend/models"
+)
+
+const (
+	MSSQL    = "mssql"
+	Postgres = "postgres"
+	MySQL    = "mysql"
+)
+
+var ErrUnknownEngine = errors.New("unknown database engine")
+
+// Connector opens the admin connections to the servers. Servers without a credential reference use the
+// default admin credentials and connection options of their engine from Config, the references of the
+// others are resolved through Secrets.
+type Connector struct {
+	Config  *config.Config
+	Secrets *secrets.Resolver
+}
+
+func NewConnector(cfg *config.Config, secrets *secrets.Resolver) *Connector {
+	return &Connector{Config: cfg, Secrets: secrets}
+}
+
+// Server is a database server a login lives on. ID is its inventory entry and a zero Port means the
+// default port of the engine. CredentialRef names the admin credentials to use, empty for the default
+// ones of the engine.
+type Server struct {
+	ID            int
+	Name          string
+	Host          string
+	Port          int
+	Engine        string
+	CredentialRef string
+}
+
+// Engine performs the steps of a password reset on one kind of database server.
+type Engine interface {
+	Name() string
+	// ValidateOwner reports whether the email belongs to an owner of the login on the server
+	ValidateOwner(ctx context.Context, server Server, username, email string) (bool, error)
+	// CheckExpiry reports whether the login exists and is neither expired nor locked
+	CheckExpiry(ctx context.Context, server Server, username string) (bool, error)
+	// VerifyPassword reports whether the login can sign in with the password
+	VerifyPassword(ctx context.Context, server Server, username, password, database string) (bool, error)
+	ChangePassword(ctx context.Context, server Server, username, newPassword string) error
+	// FindReplicas returns the other servers that need the same change, the primary itself is not included
+	FindReplicas(ctx context.Context, server Server) ([]string, error)
+}
+
+// New returns the engine with the given name. catalog is the central SQL Server that maps logins to owners,
+// connector opens the admin connections of the engine.
+func New(name string, catalog pkg.LoginCatalog, connector *Connector) (Engine, error) {
+	switch name {
+	case MSSQL, "":
+		return &mssqlEngine{catalog: catalog, connector: connector}, nil
+	case Postgres:
+		return &postgresEngine{catalog: catalog, connector: connector}, nil
+	case MySQL:
+		return &mysqlEngine{catalog: catalog, connector: connector}, nil
+	}
+	return nil, fmt.Errorf("%w %q", ErrUnknownEngine, name)
+}
+
+// Resolve looks up what the user entered as the server in the inventory and returns the server with its
+// engine. Servers that are not listed or disabled are refused.
+func Resolve(ctx context.Context, db *sql.DB, catalog pkg.LoginCatalog, connector *Connector, name string) (Engine, Server, error) {
+	entry, err := pkg.FindServer(db, name)
+	if err != nil {
+		return nil, Server{}, err
+	}
+	server := FromInventory(entry)
+	eng, err := New(server.Engine, catalog, connector)
+	return eng, server, err
+}
+
+// ResolveReplicas takes the port and credentials of every replica from the inventory. A replica that is
+// not listed, listed more than once or disabled is refused before any server is changed, a DBA has to add
+// it to the inventory or exclude it with a topology override.
+func ResolveReplicas(db *sql.DB, primary Server, hosts []string) ([]Server, error) {
+	replicas := make([]Server, 0, len(hosts))
+	for _, host := range hosts {
+		entry, err := pkg.FindServerByHost(db, host)
+		if err != nil {
+			return nil, fmt.Errorf("replica %s of %s: %w", host, primary.Name, err)
+		}
+		replicas = append(replicas, FromInventory(entry))
+	}
+	return replicas, nil
+}
+
+// FromInventory returns the Server of an inventory entry.
+func FromInventory(entry models.DatabaseServer) Server {
+	return Server{ID: entry.ID, Name: entry.Name, Host: entry.Host, Port: entry.Port, Engine: entry.Engine, CredentialRef: entry.CredentialRef}
+}
+
+// adminCredentials returns the user and password of the admin connection to the server. The credential
+// reference of the server is resolved through the secrets providers, without one the configured defaults
+// of the engine are used.
+func (c *Connector) adminCredentials(ctx context.Context, server Server, defaultUser, defaultPassword string) (string, string, error) {
+	if server.CredentialRef == "" {
+		return defaultUser, defaultPassword, nil
+	}
+	credentials, err := c.Secrets.Resolve(ctx, server.CredentialRef)
+	if err != nil {
+		return "", "", fmt.Errorf("admin credentials of %s: %w", server.Name, err)
+	}
+	return credentials.Username, credentials.Password, nil
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"go-backend/internals/config"
+	"go-backend/internals/pkg"
+	"go-backend/internals/pkg/catalogtest"
+	"go-backend/internals/secrets"
+	"go-backend/models"
+)
+
+func newTestConnector() *Connector {
+	return NewConnector(config.Default(), secrets.NewResolver(config.Default().Secrets))
+}
+
+func TestNew(t *testing.T) {
+	connector := newTestConnector()
+	for _, name := range []string{"", MSSQL, Postgres, MySQL} {
+		eng, err := New(name, nil, connector)
+		if err != nil {
+			t.Fatalf("New(%q): %v", name, err)
+		}
+		want := name
+		if want == "" {
+			want = MSSQL
+		}
+		if eng.Name() != want {
+			t.Errorf("New(%q).Name() = %q, want %q", name, eng.Name(), want)
+		}
+	}
+	if _, err := New("oracle", nil, connector); !errors.Is(err, ErrUnknownEngine) {
+		t.Errorf("New(oracle) = %v, want ErrUnknownEngine", err)
+	}
+}
+
+// inventory is a database/sql driver that answers the lookups of pkg.FindServer and pkg.FindServerByHost
+// from its entries.
+type inventory []models.DatabaseServer
+
+func (inv inventory) open() *sql.DB {
+	return sql.OpenDB(inv)
+}
+
+func (inv inventory) Connect(context.Context) (driver.Conn, error) { return inventoryConn{inv}, nil }
+func (inv inventory) Driver() driver.Driver                        { return inv }
+func (inv inventory) Open(string) (driver.Conn, error)             { return inventoryConn{inv}, nil }
+
+type inventoryConn struct{ entries inventory }
+
+func (c inventoryConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c inventoryConn) Close() error                        { return nil }
+func (c inventoryConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c inventoryConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	value := args[0].Value.(string)
+	byName := strings.Contains(query, "name = $1")
+	var found []models.DatabaseServer
+	for _, entry := range c.entries {
+		if entry.Host == value || byName && entry.Name == value {
+			found = append(found, entry)
+		}
+	}
+	// ORDER BY name = $1 DESC
+	sort.SliceStable(found, func(i, j int) bool { return byName && found[i].Name == value && found[j].Name != value })
+	if len(found) > 2 {
+		found = found[:2]
+	}
+	return &inventoryRows{entries: found}, nil
+}
+
+type inventoryRows struct {
+	entries []models.DatabaseServer
+}
+
+func (r *inventoryRows) Columns() []string {
+	return strings.Split(strings.ReplaceAll("id, name, host, port, engine, environment, availability_group, credential_ref, enabled, created_at, updated_at", " ", ""), ",")
+}
+
+func (r *inventoryRows) Close() error { return nil }
+
+func (r *inventoryRows) Next(dest []driver.Value) error {
+	if len(r.entries) == 0 {
+		return io.EOF
+	}
+	e := r.entries[0]
+	r.entries = r.entries[1:]
+	values := []driver.Value{int64(e.ID), e.Name, e.Host, int64(e.Port), e.Engine, e.Environment, e.AvailabilityGroup, e.CredentialRef, e.Enabled, nil, nil}
+	copy(dest, values)
+	return nil
+}
+
+var testInventory = inventory{
+	{ID: 1, Name: "sales-db-01", Host: "10.0.0.11", Port: 1433, Engine: MSSQL, CredentialRef: "SALES_ADMIN", Enabled: true},
+	{ID: 2, Name: "sales-db-02", Host: "10.0.0.12", Port: 1434, Engine: MSSQL, Enabled: true},
+	{ID: 3, Name: "orders-pg", Host: "10.0.1.10", Port: 5433, Engine: Postgres, Enabled: true},
+	{ID: 4, Name: "old-db", Host: "10.0.2.10", Port: 1433, Engine: MSSQL, Enabled: false},
+	{ID: 5, Name: "legacy", Host: "10.0.3.10", Port: 1521, Engine: "oracle", Enabled: true},
+	{ID: 6, Name: "shared-a", Host: "10.0.4.10", Port: 1433, Engine: MSSQL, Enabled: true},
+	{ID: 7, Name: "shared-b", Host: "10.0.4.10", Port: 1434, Engine: MSSQL, Enabled: true},
+}
+
+func TestResolve(t *testing.T) {
+	db := testInventory.open()
+	defer db.Close()
+	connector := newTestConnector()
+	catalog := catalogtest.New()
+
+	for _, tc := range []struct {
+		entered    string
+		wantEngine string
+		wantServer Server
+	}{
+		{"sales-db-01", MSSQL, Server{ID: 1, Name: "sales-db-01", Host: "10.0.0.11", Port: 1433, Engine: MSSQL, CredentialRef: "SALES_ADMIN"}},
+		{"10.0.0.12", MSSQL, Server{ID: 2, Name: "sales-db-02", Host: "10.0.0.12", Port: 1434, Engine: MSSQL}},
+		{"orders-pg", Postgres, Server{ID: 3, Name: "orders-pg", Host: "10.0.1.10", Port: 5433, Engine: Postgres}},
+		{"shared-b", MSSQL, Server{ID: 7, Name: "shared-b", Host: "10.0.4.10", Port: 1434, Engine: MSSQL}},
+	} {
+		eng, server, err := Resolve(context.Background(), db, catalog, connector, tc.entered)
+		if err != nil {
+			t.Errorf("Resolve(%q): %v", tc.entered, err)
+			continue
+		}
+		if eng.Name() != tc.wantEngine || server != tc.wantServer {
+			t.Errorf("Resolve(%q) = %s %+v, want %s %+v", tc.entered, eng.Name(), server, tc.wantEngine, tc.wantServer)
+		}
+	}
+
+	for entered, want := range map[string]error{
+		"unknown-db": pkg.ErrUnknownServer,
+		"old-db":     pkg.ErrServerDisabled,
+		"10.0.4.10":  pkg.ErrAmbiguousServer,
+		"legacy":     ErrUnknownEngine,
+	} {
+		if _, _, err := Resolve(context.Background(), db, catalog, connector, entered); !errors.Is(err, want) {
+			t.Errorf("Resolve(%q) = %v, want %v", entered, err, want)
+		}
+	}
+}
+
+func TestResolveReplicas(t *testing.T) {
+	db := testInventory.open()
+	defer db.Close()
+	primary := Server{ID: 1, Name: "sales-db-01", Host: "10.0.0.11", Port: 1433, Engine: MSSQL}
+
+	replicas, err := ResolveReplicas(db, primary, []string{"10.0.0.12"})
+	if err != nil {
+		t.Fatalf("ResolveReplicas: %v", err)
+	}
+	want := Server{ID: 2, Name: "sales-db-02", Host: "10.0.0.12", Port: 1434, Engine: MSSQL}
+	if len(replicas) != 1 || replicas[0] != want {
+		t.Errorf("replicas = %+v, want [%+v]", replicas, want)
+	}
+
+	for host, wantErr := range map[string]error{
+		"10.0.9.9":  pkg.ErrUnknownServer,
+		"10.0.2.10": pkg.ErrServerDisabled,
+		"10.0.4.10": pkg.ErrAmbiguousServer,
+	} {
+		_, err := ResolveReplicas(db, primary, []string{"10.0.0.12", host})
+		if !errors.Is(err, wantErr) {
+			t.Errorf("ResolveReplicas with %s = %v, want %v", host, err, wantErr)
+			continue
+		}
+		if !strings.Contains(err.Error(), "replica "+host+" of sales-db-01") {
+			t.Errorf("error %q does not name the replica and the primary", err)
+		}
+	}
+}
+
+func TestAdminCredentials(t *testing.T) {
+	t.Setenv("DBA_SECRET_SALES_ADMIN_USER", "sa_sales")
+	t.Setenv("DBA_SECRET_SALES_ADMIN_PASSWORD", "secret")
+	connector := newTestConnector()
+
+	user, password, err := connector.adminCredentials(context.Background(), Server{Name: "plain"}, "sa", "default")
+	if err != nil || user != "sa" || password != "default" {
+		t.Errorf("without a reference = %q %q %v, want the defaults", user, password, err)
+	}
+	user, password, err = connector.adminCredentials(context.Background(), Server{Name: "sales-db-01", CredentialRef: "SALES_ADMIN"}, "sa", "default")
+	if err != nil || user != "sa_sales" || password != "secret" {
+		t.Errorf("with a reference = %q %q %v, want the referenced credentials", user, password, err)
+	}
+	_, _, err = connector.adminCredentials(context.Background(), Server{Name: "sales-db-02", CredentialRef: "MISSING"}, "sa", "default")
+	if err == nil || !strings.Contains(err.Error(), "admin credentials of sales-db-02") {
+		t.Errorf("with a missing reference = %v, want an error naming the server", err)
+	}
+}
@@ -0,0 +1,121 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"go-backend/internals/loginadmin"
+	"go-backend/internals/pkg"
+
+	"github.com/rs/zerolog/log"
+)
+
+// mssqlEngine resets SQL Server logins. Logins are server objects that availability groups do not
+// replicate, so the change is made on every replica. The admin connection uses the user and password of
+// the central catalog unless the server has a credential reference.
+type mssqlEngine struct {
+	catalog   pkg.LoginCatalog
+	connector *Connector
+}
+
+func (e *mssqlEngine) Name() string {
+	return MSSQL
+}
+
+func (e *mssqlEngine) ValidateOwner(ctx context.Context, server Server, username, email string) (bool, error) {
+	return e.catalog.ValidateUserCredentials(ctx, username, server.Host, email)
+}
+
+func (e *mssqlEngine) CheckExpiry(ctx context.Context, server Server, username string) (bool, error) {
+	return e.catalog.CheckLoginExpiration(ctx, username, server.Host)
+}
+
+func (e *mssqlEngine) VerifyPassword(ctx context.Context, server Server, username, password, database string) (bool, error) {
+	conn, err := e.connector.openMSSQL(ctx, server, username, password, database)
+	if err != nil {
+		if pkg.IsLoginFailed(err) {
+			log.Info().Msg("Old Password is invalid")
+			return false, nil
+		}
+		return false, err
+	}
+	conn.Close()
+	log.Info().Msg("Old Password is valid")
+	return true, nil
+}
+
+// ChangePassword applies the password policy and expiration of mssql.check_policy and
+// mssql.check_expiration together with the new password.
+func (e *mssqlEngine) ChangePassword(ctx context.Context, server Server, username, newPassword string) error {
+	conn, err := e.connector.ConnectMSSQLServer(ctx, server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return loginadmin.SetPassword(ctx, conn, username, newPassword, loginadmin.PasswordOptions{
+		CheckPolicy:     e.connector.Config.MSSQL.CheckPolicy,
+		CheckExpiration: e.connector.Config.MSSQL.CheckExpiration,
+	})
+}
+
+func (e *mssqlEngine) FindReplicas(ctx context.Context, server Server) ([]string, error) {
+	return e.catalog.FindRelatedServers(ctx, server.Host)
+}
+
+// ConnectMSSQLAdmin opens an admin connection to a SQL Server instance, such as an availability group
+// replica, with the port and credentials of its inventory entry. Hosts that are not enabled SQL Servers
+// of the inventory are refused. The caller must close the connection.
+func (c *Connector) ConnectMSSQLAdmin(ctx context.Context, db *sql.DB, host string) (*sql.DB, error) {
+	entry, err := pkg.FindServerByHost(db, host)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", host, err)
+	}
+	if entry.Engine != MSSQL {
+		return nil, fmt.Errorf("%s: %w %q, want %s", host, ErrUnknownEngine, entry.Engine, MSSQL)
+	}
+	return c.ConnectMSSQLServer(ctx, FromInventory(entry))
+}
+
+// ConnectMSSQLServer opens an admin connection to the database of the central catalog on the server.
+// The caller must close the connection.
+func (c *Connector) ConnectMSSQLServer(ctx context.Context, server Server) (*sql.DB, error) {
+	user, password, err := c.adminCredentials(ctx, server, c.Config.MSSQL.User, c.Config.MSSQL.Password)
+	if err != nil {
+		return nil, err
+	}
+	return c.openMSSQL(ctx, server, user, password, c.Config.MSSQL.Name)
+}
+
+func (c *Connector) openMSSQL(ctx context.Context, server Server, user, password, database string) (*sql.DB, error) {
+	conn, err := sql.Open("mssql", c.mssqlConnURL(server, user, password, database))
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connecting to %s: %w", server.Host, err)
+	}
+	return conn, nil
+}
+
+// mssqlConnURL returns a URL connection string, which escapes credentials that contain ; or =.
+// A zero port falls back to the port of the central catalog.
+func (c *Connector) mssqlConnURL(server Server, user, password, database string) string {
+	port := strconv.Itoa(server.Port)
+	if server.Port == 0 {
+		port = strconv.Itoa(c.Config.MSSQL.Port)
+	}
+	connURL := url.URL{
+		Scheme: "sqlserver",
+		User:   url.UserPassword(user, password),
+		Host:   net.JoinHostPort(server.Host, port),
+	}
+	if database != "" {
+		connURL.RawQuery = url.Values{"database": {database}}.Encode()
+	}
+	return connURL.String()
+}
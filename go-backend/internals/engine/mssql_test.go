@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMSSQLConnURL(t *testing.T) {
+	connector := newTestConnector()
+	for _, tc := range []struct {
+		server   Server
+		database string
+		wantHost string
+	}{
+		{Server{Host: "10.0.0.11"}, "master", "10.0.0.11:1433"},
+		{Server{Host: "10.0.0.12", Port: 1434}, "", "10.0.0.12:1434"},
+	} {
+		raw := connector.mssqlConnURL(tc.server, "sa", "p;w=d@x/y:z", tc.database)
+		parsed, err := url.Parse(raw)
+		if err != nil {
+			t.Fatalf("connection URL %s: %v", raw, err)
+		}
+		password, _ := parsed.User.Password()
+		if parsed.Scheme != "sqlserver" || parsed.Host != tc.wantHost || parsed.User.Username() != "sa" || password != "p;w=d@x/y:z" {
+			t.Errorf("connection URL %s, want sa with its password on %s", raw, tc.wantHost)
+		}
+		if got := parsed.Query().Get("database"); got != tc.database {
+			t.Errorf("database = %q, want %q", got, tc.database)
+		}
+	}
+}
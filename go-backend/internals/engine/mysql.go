@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"go-backend/internals/loginadmin"
+	"go-backend/internals/pkg"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	defaultMySQLPort   = 3306
+	maxMySQLUserLength = 32
+	// ER_ACCESS_DENIED_ERROR
+	mysqlAccessDenied = 1045
+)
+
+var ErrInvalidUserName = errors.New("invalid user name")
+
+// mysqlEngine resets MySQL users with ALTER USER. Replicas apply the statement from the binary log,
+// so there are no replicas to update. A user may exist for several hosts, every account of the user
+// gets the new password. The admin connection uses the user and password of engines.mysql unless the
+// server has a credential reference.
+type mysqlEngine struct {
+	catalog   pkg.LoginCatalog
+	connector *Connector
+}
+
+func (e *mysqlEngine) Name() string {
+	return MySQL
+}
+
+func (e *mysqlEngine) ValidateOwner(ctx context.Context, server Server, username, email string) (bool, error) {
+	return e.catalog.ValidateUserCredentials(ctx, username, server.Host, email)
+}
+
+// CheckExpiry rejects the user if any of its accounts is locked or has an expired password.
+func (e *mysqlEngine) CheckExpiry(ctx context.Context, server Server, username string) (bool, error) {
+	conn, err := e.connectAdmin(ctx, server)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var accounts, unusable int
+	err = conn.QueryRowContext(ctx, `SELECT COUNT(*), COALESCE(SUM(password_expired = 'Y' OR account_locked = 'Y'
+		OR (password_lifetime > 0 AND password_last_changed + INTERVAL password_lifetime DAY < NOW())), 0)
+		FROM mysql.user WHERE User = ?`, username).Scan(&accounts, &unusable)
+	if err != nil {
+		return false, err
+	}
+	return accounts > 0 && unusable == 0, nil
+}
+
+func (e *mysqlEngine) VerifyPassword(ctx context.Context, server Server, username, password, database string) (bool, error) {
+	conn, err := e.connector.openMySQL(ctx, server, username, password, database)
+	if err != nil {
+		var myErr *mysql.MySQLError
+		if errors.As(err, &myErr) && myErr.Number == mysqlAccessDenied {
+			return false, nil
+		}
+		return false, err
+	}
+	conn.Close()
+	return true, nil
+}
+
+// ChangePassword sets the password of all accounts of the user in a single ALTER USER, so either all
+// or none of them change. The values are escaped by the driver, ALTER USER cannot be prepared.
+func (e *mysqlEngine) ChangePassword(ctx context.Context, server Server, username, newPassword string) error {
+	if username == "" || utf8.RuneCountInString(username) > maxMySQLUserLength || strings.ContainsRune(username, 0) {
+		return ErrInvalidUserName
+	}
+	if err := loginadmin.ValidatePassword(newPassword); err != nil {
+		return err
+	}
+	conn, err := e.connectAdmin(ctx, server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	hosts, err := mysqlUserHosts(ctx, conn, username)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("user %s does not exist on %s", username, server.Host)
+	}
+	statement, args := mysqlAlterUser(username, hosts, newPassword)
+	_, err = conn.ExecContext(ctx, statement, args...)
+	return err
+}
+
+// mysqlAlterUser returns the ALTER USER statement that gives every account of the user on hosts the
+// password, with its placeholders and arguments.
+func mysqlAlterUser(username string, hosts []string, password string) (string, []interface{}) {
+	accounts := make([]string, 0, len(hosts))
+	args := make([]interface{}, 0, 3*len(hosts))
+	for _, host := range hosts {
+		accounts = append(accounts, "?@? IDENTIFIED BY ?")
+		args = append(args, username, host, password)
+	}
+	return "ALTER USER " + strings.Join(accounts, ", "), args
+}
+
+func (e *mysqlEngine) FindReplicas(ctx context.Context, server Server) ([]string, error) {
+	return nil, nil
+}
+
+func (e *mysqlEngine) connectAdmin(ctx context.Context, server Server) (*sql.DB, error) {
+	admin := e.connector.Config.Engines.MySQL
+	user, password, err := e.connector.adminCredentials(ctx, server, admin.User, admin.Password)
+	if err != nil {
+		return nil, err
+	}
+	return e.connector.openMySQL(ctx, server, user, password, "")
+}
+
+func mysqlUserHosts(ctx context.Context, conn *sql.DB, username string) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT Host FROM mysql.user WHERE User = ?", username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hosts []string
+	for rows.Next() {
+		var host string
+		if err := rows.Scan(&host); err != nil {
+			return nil, err
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, rows.Err()
+}
+
+func (c *Connector) openMySQL(ctx context.Context, server Server, user, password, database string) (*sql.DB, error) {
+	connector, err := mysql.NewConnector(c.mysqlConfig(server, user, password, database))
+	if err != nil {
+		return nil, err
+	}
+	conn := sql.OpenDB(connector)
+	if err = conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connecting to %s: %w", server.Host, err)
+	}
+	return conn, nil
+}
+
+// mysqlConfig returns the driver settings of a connection to the server, a zero port means 3306.
+func (c *Connector) mysqlConfig(server Server, user, password, database string) *mysql.Config {
+	port := server.Port
+	if port == 0 {
+		port = defaultMySQLPort
+	}
+	config := mysql.NewConfig()
+	config.User = user
+	config.Passwd = password
+	config.Net = "tcp"
+	config.Addr = net.JoinHostPort(server.Host, strconv.Itoa(port))
+	config.DBName = database
+	// escapes arguments on the client, taking NO_BACKSLASH_ESCAPES of the server into account
+	config.InterpolateParams = true
+	config.TLSConfig = c.Config.Engines.MySQL.TLS
+	return config
+}
@@ -0,0 +1,34 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMySQLAlterUser(t *testing.T) {
+	statement, args := mysqlAlterUser("app", []string{"%", "10.0.0.%"}, "it's a secret")
+	if want := "ALTER USER ?@? IDENTIFIED BY ?, ?@? IDENTIFIED BY ?"; statement != want {
+		t.Errorf("statement = %s, want %s", statement, want)
+	}
+	want := []interface{}{"app", "%", "it's a secret", "app", "10.0.0.%", "it's a secret"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+func TestMySQLConfig(t *testing.T) {
+	connector := newTestConnector()
+	config := connector.mysqlConfig(Server{Host: "fe80::1"}, "admin", "pw", "")
+	if config.Addr != "[fe80::1]:3306" || config.Net != "tcp" {
+		t.Errorf("address = %s %s, want tcp [fe80::1]:3306", config.Net, config.Addr)
+	}
+	if !config.InterpolateParams {
+		t.Error("parameters are not interpolated, ALTER USER cannot be prepared")
+	}
+	if config.TLSConfig != "preferred" {
+		t.Errorf("TLS = %q, want the default of engines.mysql.tls", config.TLSConfig)
+	}
+	if config := connector.mysqlConfig(Server{Host: "10.0.5.10", Port: 3307}, "admin", "pw", "app"); config.Addr != "10.0.5.10:3307" || config.DBName != "app" {
+		t.Errorf("address = %s database = %s, want 10.0.5.10:3307 and app", config.Addr, config.DBName)
+	}
+}
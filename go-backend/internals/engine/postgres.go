@@ -0,0 +1,209 @@
+package engine
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go-backend/internals/loginadmin"
+	"go-backend/internals/pkg"
+
+	"github.com/lib/pq"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	defaultPostgresPort = 5432
+	// NAMEDATALEN - 1
+	maxPostgresRoleLength = 63
+	// invalid_password
+	pgInvalidPassword = "28P01"
+	// the iteration count and salt length PostgreSQL uses for its own verifiers
+	scramIterations = 4096
+	scramSaltLength = 16
+)
+
+var ErrInvalidRoleName = errors.New("invalid role name")
+
+// postgresEngine resets PostgreSQL roles with ALTER ROLE. Standbys replay the change from the primary,
+// so there are no replicas to update. The admin connection uses the user and password of engines.postgres
+// unless the server has a credential reference.
+type postgresEngine struct {
+	catalog   pkg.LoginCatalog
+	connector *Connector
+}
+
+func (e *postgresEngine) Name() string {
+	return Postgres
+}
+
+func (e *postgresEngine) ValidateOwner(ctx context.Context, server Server, username, email string) (bool, error) {
+	return e.catalog.ValidateUserCredentials(ctx, username, server.Host, email)
+}
+
+func (e *postgresEngine) CheckExpiry(ctx context.Context, server Server, username string) (bool, error) {
+	conn, err := e.connectAdmin(ctx, server)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// a role without a row cannot log in, the same as an expired one
+	var isValid bool
+	err = conn.QueryRowContext(ctx, `SELECT rolcanlogin AND (rolvaliduntil IS NULL OR rolvaliduntil > CURRENT_TIMESTAMP)
+		FROM pg_roles WHERE rolname = $1`, username).Scan(&isValid)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return isValid, err
+}
+
+func (e *postgresEngine) VerifyPassword(ctx context.Context, server Server, username, password, database string) (bool, error) {
+	if database == "" {
+		database = e.connector.Config.Engines.Postgres.Database
+	}
+	conn, err := e.connector.openPostgres(ctx, server, username, password, database)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgInvalidPassword {
+			return false, nil
+		}
+		return false, err
+	}
+	conn.Close()
+	return true, nil
+}
+
+func (e *postgresEngine) ChangePassword(ctx context.Context, server Server, username, newPassword string) error {
+	statement, err := postgresAlterRole(username, newPassword)
+	if err != nil {
+		return err
+	}
+	conn, err := e.connectAdmin(ctx, server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.ExecContext(ctx, statement)
+	return err
+}
+
+func (e *postgresEngine) FindReplicas(ctx context.Context, server Server) ([]string, error) {
+	return nil, nil
+}
+
+func (e *postgresEngine) connectAdmin(ctx context.Context, server Server) (*sql.DB, error) {
+	admin := e.connector.Config.Engines.Postgres
+	user, password, err := e.connector.adminCredentials(ctx, server, admin.User, admin.Password)
+	if err != nil {
+		return nil, err
+	}
+	return e.connector.openPostgres(ctx, server, user, password, admin.Database)
+}
+
+// postgresAlterRole builds ALTER ROLE with a quoted identifier and literal, it does not take parameters.
+// The literal is a SCRAM-SHA-256 verifier computed here, so the password itself never reaches the server
+// and cannot show up in its statement log or pg_stat_activity.
+func postgresAlterRole(role, password string) (string, error) {
+	if role == "" || len(role) > maxPostgresRoleLength || strings.ContainsRune(role, 0) {
+		return "", ErrInvalidRoleName
+	}
+	if err := loginadmin.ValidatePassword(password); err != nil {
+		return "", err
+	}
+	salt := make([]byte, scramSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	verifier, err := scramVerifier(password, salt, scramIterations)
+	if err != nil {
+		return "", err
+	}
+	return "ALTER ROLE " + pq.QuoteIdentifier(role) + " WITH PASSWORD " + pq.QuoteLiteral(verifier), nil
+}
+
+// scramVerifier returns the SCRAM-SHA-256 verifier of the password in the format of pg_authid (RFC 5803).
+// PostgreSQL runs passwords through SASLprep at login but falls back to the raw password when SASLprep
+// fails, so a password SASLprep would change is refused: it is not known which of the two the server uses.
+func scramVerifier(password string, salt []byte, iterations int) (string, error) {
+	if !saslprepUnchanged(password) {
+		return "", fmt.Errorf("%w: it is changed by SASLprep", loginadmin.ErrInvalidPassword)
+	}
+	salted := pbkdf2.Key([]byte(password), salt, iterations, sha256.Size, sha256.New)
+	clientKey := scramHMAC(salted, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := scramHMAC(salted, "Server Key")
+
+	encode := base64.StdEncoding.EncodeToString
+	return fmt.Sprintf("SCRAM-SHA-256$%d:%s$%s:%s", iterations, encode(salt), encode(storedKey[:]), encode(serverKey)), nil
+}
+
+func scramHMAC(key []byte, message string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(message))
+	return mac.Sum(nil)
+}
+
+// saslprepUnchanged reports whether SASLprep (RFC 4013) leaves the password as it is: it has no non-ASCII
+// spaces, none of the characters mapped to nothing and is in NFKC. ASCII passwords always are.
+func saslprepUnchanged(password string) bool {
+	for _, r := range password {
+		if r > unicode.MaxASCII && (unicode.Is(unicode.Zs, r) || saslprepMappedToNothing(r)) {
+			return false
+		}
+	}
+	return norm.NFKC.IsNormalString(password)
+}
+
+// saslprepMappedToNothing reports the characters of table B.1 of RFC 3454.
+func saslprepMappedToNothing(r rune) bool {
+	switch {
+	case r == 0x00AD, r == 0x034F, r == 0x1806, r == 0x2060, r == 0xFEFF:
+		return true
+	case r >= 0x180B && r <= 0x180D, r >= 0x200B && r <= 0x200D, r >= 0xFE00 && r <= 0xFE0F:
+		return true
+	}
+	return false
+}
+
+func (c *Connector) openPostgres(ctx context.Context, server Server, user, password, database string) (*sql.DB, error) {
+	conn, err := sql.Open("postgres", c.postgresConnString(server, user, password, database))
+	if err != nil {
+		return nil, err
+	}
+	if err = conn.PingContext(ctx); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connecting to %s: %w", server.Host, err)
+	}
+	return conn, nil
+}
+
+// postgresConnString returns the key=value connection string of the server, a zero port means 5432.
+func (c *Connector) postgresConnString(server Server, user, password, database string) string {
+	port := server.Port
+	if port == 0 {
+		port = defaultPostgresPort
+	}
+	return strings.Join([]string{
+		"host=" + QuoteConnValue(server.Host),
+		"port=" + strconv.Itoa(port),
+		"user=" + QuoteConnValue(user),
+		"password=" + QuoteConnValue(password),
+		"dbname=" + QuoteConnValue(database),
+		"sslmode=" + QuoteConnValue(c.Config.Engines.Postgres.SSLMode),
+	}, " ")
+}
+
+// QuoteConnValue quotes a value of a key=value connection string so spaces and quotes in passwords survive.
+func QuoteConnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"go-backend/internals/loginadmin"
+)
+
+func TestScramVerifier(t *testing.T) {
+	salt := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	got, err := scramVerifier("Correct horse 9!", salt, 4096)
+	if err != nil {
+		t.Fatalf("scramVerifier: %v", err)
+	}
+	// computed independently with PBKDF2-HMAC-SHA-256 as described in RFC 5802
+	want := "SCRAM-SHA-256$4096:AAECAwQFBgcICQoLDA0ODw==$BysrIRJ5Nb90rpttCtP1cntmkxo+M2oBFYvsKRMKm2A=:HmV5N3l5TvK37HLzsV16KkWzRsl6VxXeY1/uCrjvY3w="
+	if got != want {
+		t.Errorf("verifier = %s, want %s", got, want)
+	}
+}
+
+func TestScramVerifierRefusesPasswordsChangedBySASLprep(t *testing.T) {
+	salt := make([]byte, scramSaltLength)
+	for _, password := range []string{"non\u00a0breaking", "soft\u00adhyphen", "zero\u200bwidth", "\ufb01ligature", "\uff26ullwidth"} {
+		if _, err := scramVerifier(password, salt, scramIterations); !errors.Is(err, loginadmin.ErrInvalidPassword) {
+			t.Errorf("scramVerifier(%q) = %v, want ErrInvalidPassword", password, err)
+		}
+	}
+	for _, password := range []string{"plain ascii 1!", "Grüße-123", "пароль-секрет"} {
+		if _, err := scramVerifier(password, salt, scramIterations); err != nil {
+			t.Errorf("scramVerifier(%q): %v", password, err)
+		}
+	}
+}
+
+var alterRolePattern = regexp.MustCompile(`^ALTER ROLE (.+) WITH PASSWORD 'SCRAM-SHA-256\$4096:[A-Za-z0-9+/=]{24}\$[A-Za-z0-9+/=]{44}:[A-Za-z0-9+/=]{44}'$`)
+
+func TestPostgresAlterRole(t *testing.T) {
+	statement, err := postgresAlterRole(`app"user`, "it's a secret")
+	if err != nil {
+		t.Fatalf("postgresAlterRole: %v", err)
+	}
+	match := alterRolePattern.FindStringSubmatch(statement)
+	if match == nil {
+		t.Fatalf("statement %q does not set a SCRAM-SHA-256 verifier", statement)
+	}
+	if match[1] != `"app""user"` {
+		t.Errorf("role = %s, want the quoted identifier \"app\"\"user\"", match[1])
+	}
+	if strings.Contains(statement, "secret") {
+		t.Errorf("statement %q contains the password", statement)
+	}
+
+	again, _ := postgresAlterRole(`app"user`, "it's a secret")
+	if again == statement {
+		t.Error("two verifiers of the same password share the salt")
+	}
+
+	for _, role := range []string{"", strings.Repeat("r", maxPostgresRoleLength+1), "nul\x00role"} {
+		if _, err := postgresAlterRole(role, "secret"); !errors.Is(err, ErrInvalidRoleName) {
+			t.Errorf("postgresAlterRole(%q) = %v, want ErrInvalidRoleName", role, err)
+		}
+	}
+	if _, err := postgresAlterRole("app", "bad\npassword"); !errors.Is(err, loginadmin.ErrInvalidPassword) {
+		t.Errorf("a password with a control character = %v, want ErrInvalidPassword", err)
+	}
+}
+
+func TestPostgresConnString(t *testing.T) {
+	connector := newTestConnector()
+	got := connector.postgresConnString(Server{Host: "10.0.1.10"}, "admin", `it's \ spaced`, "postgres")
+	want := `host='10.0.1.10' port=5432 user='admin' password='it\'s \\ spaced' dbname='postgres' sslmode='require'`
+	if got != want {
+		t.Errorf("connection string = %s, want %s", got, want)
+	}
+	if got := connector.postgresConnString(Server{Host: "10.0.1.10", Port: 5433}, "admin", "pw", "app"); !strings.Contains(got, "port=5433 ") {
+		t.Errorf("connection string %s does not use the port of the server", got)
+	}
+}
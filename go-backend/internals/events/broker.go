@@ -0,0 +1,124 @@
+package events
+
+import (
+	"database/sql"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TopicLogs carries every row written to pass_reset_logs
+const TopicLogs = "logs"
+
+// TopicTopology carries the servers whose cached replicas were refreshed, so every instance drops them
+const TopicTopology = "topology"
+
+// JobTopic carries the progress of one password job
+func JobTopic(jobID string) string {
+	return "job:" + jobID
+}
+
+type Event struct {
+	Topic string      `json:"topic"`
+	Type  string      `json:"type"`
+	Data  interface{} `json:"data"`
+}
+
+// Broker fans events out to the subscribers of this process. StartRelay connects the brokers of all
+// backend instances through PostgreSQL, so a stream sees what happens in any of them.
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[*subscriber]struct{}
+	closed      bool
+	// relay is set by StartRelay, Publish sends events through PostgreSQL while it is set
+	relay atomic.Pointer[sql.DB]
+}
+
+type subscriber struct {
+	match func(Event) bool
+	ch    chan Event
+}
+
+// buffered events per subscriber, a slow subscriber loses events rather than blocking publishers
+const subscriberBuffer = 64
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[*subscriber]struct{}{}}
+}
+
+// Subscribe returns a channel with every published event that match accepts and a function
+// that ends the subscription. The channel is closed once unsubscribed or once the broker is closed.
+func (b *Broker) Subscribe(match func(Event) bool) (<-chan Event, func()) {
+	sub := &subscriber{match: match, ch: make(chan Event, subscriberBuffer)}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.ch)
+		return sub.ch, func() {}
+	}
+	b.subscribers[sub] = struct{}{}
+
+	return sub.ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Close ends every subscription, so that the streams reading them return, and refuses new ones. It is
+// registered with http.Server.RegisterOnShutdown since open streams would keep Shutdown waiting.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subscribers {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// Publish sends the event to the subscribers of every instance once StartRelay runs, and to the
+// subscribers of this process only before that or when PostgreSQL cannot take it.
+func (b *Broker) Publish(event Event) {
+	if db := b.relay.Load(); db != nil {
+		err := notify(db, event)
+		if err == nil {
+			return
+		}
+		log.Warn().Err(err).Msgf("Failed to relay %s event on %s, only this instance sees it", event.Type, event.Topic)
+	}
+	b.deliver(event)
+}
+
+// deliver hands the event to the subscribers of this process. It never blocks, events for subscribers
+// with a full buffer are dropped.
+func (b *Broker) deliver(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for sub := range b.subscribers {
+		if !sub.match(event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			log.Warn().Msgf("Dropping %s event on %s for a slow subscriber", event.Type, event.Topic)
+		}
+	}
+}
+
+// OnTopic matches the events of a single topic
+func OnTopic(topic string) func(Event) bool {
+	return func(event Event) bool {
+		return event.Topic == topic
+	}
+}
+
+// All matches every event
+func All(Event) bool {
+	return true
+}
@@ -0,0 +1,24 @@
+package events
+
+import "testing"
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	b := NewBroker()
+	stream, unsubscribe := b.Subscribe(All)
+	b.Publish(Event{Topic: TopicLogs, Type: "log"})
+
+	b.Close()
+	if event, open := <-stream; !open || event.Type != "log" {
+		t.Fatalf("first event = %+v, %v, want the published log", event, open)
+	}
+	if _, open := <-stream; open {
+		t.Fatal("the stream is still open after Close")
+	}
+	unsubscribe()
+
+	late, _ := b.Subscribe(All)
+	if _, open := <-late; open {
+		t.Error("a subscription after Close must be closed")
+	}
+	b.Publish(Event{Topic: TopicLogs, Type: "log"})
+}
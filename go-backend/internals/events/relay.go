@@ -0,0 +1,86 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// RelayChannel is the PostgreSQL notification channel that carries events between backend instances
+const RelayChannel = "dba_self_service_events"
+
+// PostgreSQL refuses notification payloads of 8000 bytes or more
+const maxNotifyPayload = 7999
+
+// listenerPingInterval checks the listener connection while no notification arrives
+const listenerPingInterval = 90 * time.Second
+
+// StartRelay shares the events of every backend instance on the database. Publish sends events with
+// NOTIFY through db, and a listener opened with dsn hands the notifications of all instances, this one
+// included, to the subscribers of b until ctx is cancelled.
+func (b *Broker) StartRelay(ctx context.Context, db *sql.DB, dsn string) error {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Error().Err(err).Msg("Event relay connection failed")
+		}
+	})
+	if err := listener.Listen(RelayChannel); err != nil {
+		listener.Close()
+		return err
+	}
+	b.relay.Store(db)
+
+	go func() {
+		defer listener.Close()
+		ping := time.NewTicker(listenerPingInterval)
+		defer ping.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				b.relay.Store(nil)
+				log.Info().Msg("Event relay stopped")
+				return
+			case <-ping.C:
+				if err := listener.Ping(); err != nil {
+					log.Error().Err(err).Msg("Event relay ping failed")
+				}
+			case notification := <-listener.Notify:
+				// nil follows a reconnect, notifications sent while disconnected are lost
+				if notification == nil {
+					log.Warn().Msg("Event relay reconnected, events of the last moments may be missing")
+					continue
+				}
+				var event struct {
+					Topic string          `json:"topic"`
+					Type  string          `json:"type"`
+					Data  json.RawMessage `json:"data"`
+				}
+				if err := json.Unmarshal([]byte(notification.Extra), &event); err != nil {
+					log.Error().Err(err).Msg("Dropping an event relay notification that is not an event")
+					continue
+				}
+				b.deliver(Event{Topic: event.Topic, Type: event.Type, Data: event.Data})
+			}
+		}
+	}()
+	log.Info().Msgf("Event relay listening on %s", RelayChannel)
+	return nil
+}
+
+// notify sends the event to every instance, the subscribers of this one get it through the listener.
+func notify(db *sql.DB, event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		return fmt.Errorf("event of %d bytes is too large for NOTIFY", len(payload))
+	}
+	_, err = db.Exec("SELECT pg_notify($1, $2)", RelayChannel, string(payload))
+	return err
+}
@@ -0,0 +1,62 @@
+//go:build integration
+
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// TestRelay publishes through PostgreSQL like a second backend instance would, see
+// internals/store/postgres_integration_test.go for how to run it.
+func TestRelay(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := NewBroker()
+	if err := b.StartRelay(ctx, db, dsn); err != nil {
+		t.Fatalf("StartRelay: %v", err)
+	}
+	stream, unsubscribe := b.Subscribe(OnTopic(JobTopic("job-1")))
+	defer unsubscribe()
+
+	// another instance only shares the database with this one
+	if err := notify(db, Event{Topic: JobTopic("job-1"), Type: "step", Data: map[string]string{"name": "Validate credentials"}}); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	select {
+	case event := <-stream:
+		data, _ := json.Marshal(event.Data)
+		if event.Type != "step" || string(data) != `{"name":"Validate credentials"}` {
+			t.Errorf("event = %s %s, want the step", event.Type, data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the relayed event did not arrive")
+	}
+
+	// events too large for NOTIFY still reach this instance
+	b.Publish(Event{Topic: JobTopic("job-1"), Type: "large", Data: string(make([]byte, maxNotifyPayload))})
+	select {
+	case event := <-stream:
+		if event.Type != "large" {
+			t.Errorf("event = %s, want the large one", event.Type)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the large event did not arrive")
+	}
+}
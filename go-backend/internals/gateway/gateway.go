@@ -0,0 +1,38 @@
+// Package gateway reaches the database servers: the inventory entries through their engines and the login
+// catalog on the central SQL Server.
+package gateway
+
+import (
+	"context"
+	"database/sql"
+
+	"go-backend/internals/engine"
+	"go-backend/internals/pkg"
+)
+
+type MSSQL struct {
+	// DB holds the inventory
+	DB        *sql.DB
+	Catalog   pkg.LoginCatalog
+	Connector *engine.Connector
+}
+
+func New(db, catalog *sql.DB, connector *engine.Connector) *MSSQL {
+	return &MSSQL{DB: db, Catalog: pkg.SQLServerCatalog{DB: catalog}, Connector: connector}
+}
+
+func (g *MSSQL) ResolveServer(ctx context.Context, name string) (engine.Engine, engine.Server, error) {
+	return engine.Resolve(ctx, g.DB, g.Catalog, g.Connector, name)
+}
+
+func (g *MSSQL) ResolveReplicas(primary engine.Server, hosts []string) ([]engine.Server, error) {
+	return engine.ResolveReplicas(g.DB, primary, hosts)
+}
+
+func (g *MSSQL) ValidateLoginOwner(ctx context.Context, username, serverIP, email string) (bool, error) {
+	return g.Catalog.ValidateUserCredentials(ctx, username, serverIP, email)
+}
+
+func (g *MSSQL) ValidateAccessRequestLogin(ctx context.Context, username string) (bool, error) {
+	return g.Catalog.ValidateAccessRequest(ctx, username)
+}
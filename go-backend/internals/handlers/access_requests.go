@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-backend/internals/middleware"
+	"go-backend/internals/pkg"
+	"go-backend/internals/service"
+	"go-backend/models"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// access levels offered by the access-control page
+var validAccessLevels = map[string]bool{
+	"read":  true,
+	"write": true,
+	"admin": true,
+}
+
+func (a *App) CreateAccessRequest(w http.ResponseWriter, r *http.Request) {
+	var request models.AccessRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		log.Info().Msgf("Failed to decode access request: %v", err)
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	request.Username = strings.TrimSpace(request.Username)
+	request.Database = strings.TrimSpace(request.Database)
+	request.AccessLevel = strings.ToLower(strings.TrimSpace(request.AccessLevel))
+	log.Info().Msgf("Received access request for user: %s, database: %s, access level: %s", request.Username, request.Database, request.AccessLevel)
+
+	if request.Username == "" || request.Database == "" {
+		pkg.SendErrorResponse(w, "Username and database are required", http.StatusBadRequest)
+		return
+	}
+	if !validAccessLevels[request.AccessLevel] {
+		pkg.SendErrorResponse(w, "Invalid access level", http.StatusBadRequest)
+		return
+	}
+
+	isValidUser, err := a.Gateway.ValidateAccessRequestLogin(r.Context(), request.Username)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to validate access request login")
+		pkg.SendErrorResponse(w, "Failed to validate user", http.StatusInternalServerError)
+		return
+	}
+	if !isValidUser {
+		pkg.SendErrorResponse(w, "Login is not registered for self service", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := a.Access.CreateAccessRequest(request)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to record access request")
+		pkg.SendErrorResponse(w, "Failed to record access request", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Msgf("Access request %d recorded for user: %s", requestID, request.Username)
+	pkg.SendJSONResponse(w, map[string]interface{}{
+		"message":   "Access request submitted successfully",
+		"requestID": requestID,
+	}, http.StatusCreated)
+}
+
+func (a *App) GetAllAccessReq(w http.ResponseWriter, r *http.Request) {
+	requests, err := a.Access.AccessRequests()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query access requests")
+		pkg.SendErrorResponse2(w, "Failed to query access requests", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, requests, http.StatusOK)
+}
+
+func (a *App) ApproveAccessRequest(w http.ResponseWriter, r *http.Request) {
+	a.decideAccessRequest(w, r, service.StatusApproved)
+}
+
+func (a *App) RejectAccessRequest(w http.ResponseWriter, r *http.Request) {
+	a.decideAccessRequest(w, r, service.StatusRejected)
+}
+
+func (a *App) decideAccessRequest(w http.ResponseWriter, r *http.Request, decision string) {
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		pkg.SendErrorResponse(w, "Invalid access request id", http.StatusBadRequest)
+		return
+	}
+
+	var review models.AccessReview
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode review", http.StatusBadRequest)
+		return
+	}
+	reviewer := middleware.AdminUsername(r.Context())
+
+	result, err := a.Access.DecideAccessRequest(requestID, reviewer, decision, review.Message)
+	switch {
+	case errors.Is(err, service.ErrRequestNotFound):
+		pkg.SendErrorResponse(w, "Access request not found", http.StatusNotFound)
+		return
+	case errors.Is(err, service.ErrRequestNotPending):
+		pkg.SendErrorResponse(w, "Access request is not pending", http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrNoStages):
+		// the request stays pending until a superadmin configures the stages of its access level
+		pkg.SendErrorResponse(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, service.ErrNotApprover), errors.Is(err, service.ErrAlreadyDecided):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, service.ErrGrantFailed):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadGateway)
+		return
+	case err != nil:
+		log.Error().Err(err).Msgf("Failed to decide access request %d", requestID)
+		pkg.SendErrorResponse(w, "Failed to review access request", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Msgf("Access request %d: %s", requestID, result.Message)
+	pkg.SendJSONResponse(w, result, http.StatusOK)
+}
+
+func (a *App) GetAccessRequestDecisions(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		pkg.SendErrorResponse(w, "Invalid access request id", http.StatusBadRequest)
+		return
+	}
+
+	decisions, err := a.Access.AccessRequestDecisions(requestID)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to query decisions of access request %d", requestID)
+		pkg.SendErrorResponse2(w, "Failed to query access request decisions", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, decisions, http.StatusOK)
+}
+
+func (a *App) GetApprovalStages(w http.ResponseWriter, r *http.Request) {
+	stages, err := a.Access.ApprovalStages()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query approval stages")
+		pkg.SendErrorResponse2(w, "Failed to query approval stages", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, stages, http.StatusOK)
+}
+
+func (a *App) SetApprovalStages(w http.ResponseWriter, r *http.Request) {
+	accessLevel := strings.ToLower(mux.Vars(r)["accessLevel"])
+	if !validAccessLevels[accessLevel] {
+		pkg.SendErrorResponse(w, "Invalid access level", http.StatusBadRequest)
+		return
+	}
+
+	var stages []models.ApprovalStage
+	if err := json.NewDecoder(r.Body).Decode(&stages); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode approval stages", http.StatusBadRequest)
+		return
+	}
+	if err := a.Access.SetApprovalStages(accessLevel, stages); err != nil {
+		log.Error().Err(err).Msgf("Failed to set approval stages for %s", accessLevel)
+		pkg.SendErrorResponse(w, "Failed to set approval stages: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	pkg.SendSuccessResponse(w, "Approval stages updated")
+}
+
+func (a *App) AddApproverGroupMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := a.Access.AddApproverGroupMember(vars["group"], vars["approver"]); err != nil {
+		log.Error().Err(err).Msg("Failed to add approver group member")
+		pkg.SendErrorResponse(w, "Failed to add approver group member", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendSuccessResponse(w, "Approver added to group")
+}
+
+func (a *App) RemoveApproverGroupMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := a.Access.RemoveApproverGroupMember(vars["group"], vars["approver"]); err != nil {
+		log.Error().Err(err).Msg("Failed to remove approver group member")
+		pkg.SendErrorResponse(w, "Failed to remove approver group member", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendSuccessResponse(w, "Approver removed from group")
+}
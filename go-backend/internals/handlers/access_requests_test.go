@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-backend/internals/service"
+	"go-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeAccess decides every access request with the same error, the rest is left to the embedded interface.
+type fakeAccess struct {
+	AccessStore
+	err error
+}
+
+func (f *fakeAccess) DecideAccessRequest(requestID int, approver, decision, comment string) (models.AccessDecisionResult, error) {
+	return models.AccessDecisionResult{RequestID: requestID}, f.err
+}
+
+func TestDecideAccessRequestWithoutStages(t *testing.T) {
+	app := &App{Access: &fakeAccess{err: service.ErrNoStages}}
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, "/access-requests/7/approve", strings.NewReader(`{"message":"ok"}`)),
+		map[string]string{"id": "7"})
+	rec := httptest.NewRecorder()
+	app.ApproveAccessRequest(rec, req)
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("approving a request without stages = %d, want 409", rec.Code)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["error"] != service.ErrNoStages.Error() {
+		t.Errorf("error = %q, want %q", body["error"], service.ErrNoStages.Error())
+	}
+}
@@ -2,15 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
-	"go-backend/internals/database"
+	"go-backend/internals/middleware"
 	"go-backend/internals/pkg"
+	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 
 )
 
-func AdminLogin(w http.ResponseWriter, r *http.Request) {
+func (a *App) AdminLogin(w http.ResponseWriter, r *http.Request) {
     var credentials struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
@@ -21,12 +24,24 @@ func AdminLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	db := database.GetDB()
+	clientIP := pkg.ClientIP(r, a.Config.Server)
+
+	requestID := pkg.RequestIDFromContext(r.Context())
+	retryAfter, err := a.Lockouts.ClaimLoginAttempt(requestID, pkg.ScopeAdminLogin, credentials.Username, clientIP, clientIP)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to check admin login lockout")
+		pkg.SendErrorResponse(w, "Failed to validate admin credentials", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		log.Info().Msgf("Admin login for %s from %s rejected, locked out", credentials.Username, clientIP)
+		pkg.SendLockoutResponse(w, retryAfter)
+		return
+	}
 
 	pkg.HashPassword(credentials.Password)
 
-	var isValidAdmin bool
-	err := db.QueryRow("SELECT check_admin_credentials($1, $2)", credentials.Username, credentials.Password).Scan(&isValidAdmin)
+	isValidAdmin, err := a.Admins.CheckAdminCredentials(credentials.Username, credentials.Password)
 	if err != nil {
 		log.Error().Err(err).Msg("Failed to validate admin credentials")
 		pkg.SendErrorResponse(w, "Failed to validate admin credentials", http.StatusInternalServerError)
@@ -39,8 +54,125 @@ func AdminLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// pkg.SendSuccessResponse(w, "Admin login successful")
-	log.Info().Msg("Admin login successful")
+	mfaEnabled, err := a.MFA.IsMFAEnabled(credentials.Username)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to check two-factor authentication status")
+		pkg.SendErrorResponse(w, "Failed to validate admin credentials", http.StatusInternalServerError)
+		return
+	}
+	if mfaEnabled {
+		// the session is only issued once the second step succeeds, until then the username stays counted
+		a.Lockouts.ReleaseLoginAttempt(pkg.ScopeAdminLogin, "", clientIP)
+		mfaToken, err := a.MFA.CreateMFAChallenge(credentials.Username)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to create two-factor challenge")
+			pkg.SendErrorResponse(w, "Failed to start two-factor authentication", http.StatusInternalServerError)
+			return
+		}
+		log.Info().Msgf("Admin %s passed the password step, awaiting two-factor code", credentials.Username)
+		pkg.SendJSONResponse(w, map[string]interface{}{
+			"message":     "Two-factor authentication required",
+			"mfaRequired": true,
+			"mfaToken":    mfaToken,
+		}, http.StatusOK)
+		return
+	}
+
+	a.Lockouts.ReleaseLoginAttempt(pkg.ScopeAdminLogin, credentials.Username, clientIP)
+	a.startAdminSession(w, credentials.Username, "Admin login successful")
+}
 
-	http.Redirect(w, r, "/getAllResetReq", http.StatusSeeOther)
+func (a *App) startAdminSession(w http.ResponseWriter, username, message string) {
+	session, err := a.Sessions.CreateSession(username)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create admin session")
+		pkg.SendErrorResponse(w, "Failed to create admin session", http.StatusInternalServerError)
+		return
+	}
+	pkg.SetSessionCookie(w, a.Config.Session, session)
+	log.Info().Msgf("Admin %s logged in", username)
+
+	a.sendSessionResponse(w, message, session)
+}
+
+func (a *App) AdminLogout(w http.ResponseWriter, r *http.Request) {
+	session, _ := middleware.SessionFromContext(r.Context())
+	if err := a.Sessions.RevokeSession(session); err != nil {
+		log.Error().Err(err).Msg("Failed to revoke admin session")
+		pkg.SendErrorResponse(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+	pkg.ClearSessionCookie(w, a.Config.Session)
+	log.Info().Msgf("Admin %s logged out", session.Username)
+	pkg.SendSuccessResponse(w, "Logged out successfully")
+}
+
+func (a *App) AdminRefreshSession(w http.ResponseWriter, r *http.Request) {
+	session, _ := middleware.SessionFromContext(r.Context())
+	refreshed, err := a.Sessions.RefreshSession(session)
+	if errors.Is(err, pkg.ErrInvalidSession) {
+		pkg.ClearSessionCookie(w, a.Config.Session)
+		pkg.SendErrorResponse(w, "Session has reached its maximum lifetime, please log in again", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to refresh admin session")
+		pkg.SendErrorResponse(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+	pkg.SetSessionCookie(w, a.Config.Session, refreshed)
+	a.sendSessionResponse(w, "Session refreshed", refreshed)
+}
+
+func (a *App) GetAdminSession(w http.ResponseWriter, r *http.Request) {
+	session, _ := middleware.SessionFromContext(r.Context())
+	a.sendSessionResponse(w, "Session is active", session)
+}
+
+func (a *App) sendSessionResponse(w http.ResponseWriter, message string, session pkg.Session) {
+	pkg.SendJSONResponse(w, map[string]interface{}{
+		"message":               message,
+		"username":              session.Username,
+		"role":                  session.Role,
+		"permissions":           middleware.Permissions(session.Role),
+		"mustChangePassword":    session.MustChangePassword,
+		"mfaEnabled":            session.MFAEnabled,
+		"mfaEnrollmentRequired": a.Config.Admin.MFARequired && !session.MFAEnabled,
+		"expiresAt":             session.ExpiresAt.Format(time.RFC3339),
+	}, http.StatusOK)
+}
+
+func (a *App) SetAdminRole(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	var body struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	if !middleware.IsValidRole(body.Role) {
+		pkg.SendErrorResponse(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+	if username == middleware.AdminUsername(r.Context()) {
+		pkg.SendErrorResponse(w, "Admins cannot change their own role", http.StatusForbidden)
+		return
+	}
+	if body.Role != middleware.RoleSuperadmin && a.isLastActiveSuperadmin(w, username) {
+		return
+	}
+
+	updated, err := a.Admins.SetAdminRole(username, body.Role)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to set role of admin %s", username)
+		pkg.SendErrorResponse(w, "Failed to set admin role", http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		pkg.SendErrorResponse(w, "Admin not found", http.StatusNotFound)
+		return
+	}
+	log.Info().Msgf("Admin %s set role of %s to %s", middleware.AdminUsername(r.Context()), username, body.Role)
+	pkg.SendSuccessResponse(w, "Admin role updated")
 }
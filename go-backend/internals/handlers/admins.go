@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go-backend/internals/middleware"
+	"go-backend/internals/pkg"
+	"go-backend/internals/store"
+	"go-backend/models"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// AdminSetup creates the first superadmin of an empty installation from the one-time setup token.
+func (a *App) AdminSetup(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Token    string `json:"token"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	body.Username = strings.TrimSpace(body.Username)
+	if body.Username == "" || body.Token == "" {
+		pkg.SendErrorResponse(w, "Token and username are required", http.StatusBadRequest)
+		return
+	}
+
+	err := a.Admins.CreateFirstAdmin(body.Token, body.Username, body.Password)
+	switch {
+	case errors.Is(err, pkg.ErrWeakAdminPassword):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, pkg.ErrInvalidSetupToken):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, pkg.ErrAdminsExist):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to create the first admin")
+		pkg.SendErrorResponse(w, "Failed to create admin", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Msgf("First admin %s created with the setup token", body.Username)
+	pkg.SendJSONResponse(w, map[string]string{"message": "Admin created successfully"}, http.StatusCreated)
+}
+
+// ChangeAdminPassword lets the logged in admin change their own password. All their other
+// sessions are ended and a fresh session is issued.
+func (a *App) ChangeAdminPassword(w http.ResponseWriter, r *http.Request) {
+	session, _ := middleware.SessionFromContext(r.Context())
+	var body struct {
+		OldPassword string `json:"oldPassword"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	if err := pkg.ValidateAdminPassword(body.NewPassword); err != nil {
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if body.OldPassword == body.NewPassword {
+		pkg.SendErrorResponse(w, "New password cannot be the same as the old password", http.StatusBadRequest)
+		return
+	}
+
+	isValidAdmin, err := a.Admins.CheckAdminCredentials(session.Username, body.OldPassword)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to validate admin credentials")
+		pkg.SendErrorResponse(w, "Failed to validate admin credentials", http.StatusInternalServerError)
+		return
+	}
+	if !isValidAdmin {
+		pkg.SendErrorResponse(w, "Old password is invalid", http.StatusUnauthorized)
+		return
+	}
+
+	if err := a.Admins.ChangeAdminPassword(session.Username, body.NewPassword); err != nil {
+		log.Error().Err(err).Msg("Failed to change admin password")
+		pkg.SendErrorResponse(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+	if err := a.Sessions.RevokeAllSessions(session.Username); err != nil {
+		log.Error().Err(err).Msgf("Failed to revoke sessions of admin %s", session.Username)
+	}
+	newSession, err := a.Sessions.CreateSession(session.Username)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create admin session")
+		pkg.ClearSessionCookie(w, a.Config.Session)
+		pkg.SendErrorResponse(w, "Password changed, please log in again", http.StatusInternalServerError)
+		return
+	}
+	pkg.SetSessionCookie(w, a.Config.Session, newSession)
+	log.Info().Msgf("Admin %s changed their password", session.Username)
+	a.sendSessionResponse(w, "Password changed successfully", newSession)
+}
+
+func (a *App) GetAllAdmins(w http.ResponseWriter, r *http.Request) {
+	admins, err := a.Admins.ListAdmins()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query admins")
+		pkg.SendErrorResponse2(w, "Failed to query admins", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, admins, http.StatusOK)
+}
+
+// CreateAdmin adds an admin with a temporary password that has to be changed on first login.
+func (a *App) CreateAdmin(w http.ResponseWriter, r *http.Request) {
+	var request models.NewAdminRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	request.Username = strings.TrimSpace(request.Username)
+	if request.Username == "" {
+		pkg.SendErrorResponse(w, "Username is required", http.StatusBadRequest)
+		return
+	}
+	if !middleware.IsValidRole(request.Role) {
+		pkg.SendErrorResponse(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+	if err := pkg.ValidateAdminPassword(request.Password); err != nil {
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := a.Admins.CreateAdmin(request.Username, request.Password, request.Role)
+	if errors.Is(err, store.ErrAdminExists) {
+		pkg.SendErrorResponse(w, "Admin already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to create admin %s", request.Username)
+		pkg.SendErrorResponse(w, "Failed to create admin", http.StatusInternalServerError)
+		return
+	}
+	if _, err := a.Admins.ForceAdminPasswordChange(request.Username); err != nil {
+		log.Error().Err(err).Msgf("Failed to flag password change for admin %s", request.Username)
+	}
+
+	log.Info().Msgf("Admin %s created admin %s with role %s", middleware.AdminUsername(r.Context()), request.Username, request.Role)
+	pkg.SendJSONResponse(w, map[string]string{"message": "Admin created successfully"}, http.StatusCreated)
+}
+
+func (a *App) DisableAdmin(w http.ResponseWriter, r *http.Request) {
+	a.setAdminDisabled(w, r, true)
+}
+
+func (a *App) EnableAdmin(w http.ResponseWriter, r *http.Request) {
+	a.setAdminDisabled(w, r, false)
+}
+
+func (a *App) setAdminDisabled(w http.ResponseWriter, r *http.Request, disabled bool) {
+	username := mux.Vars(r)["username"]
+	if disabled {
+		if username == middleware.AdminUsername(r.Context()) {
+			pkg.SendErrorResponse(w, "Admins cannot disable themselves", http.StatusForbidden)
+			return
+		}
+		if a.isLastActiveSuperadmin(w, username) {
+			return
+		}
+	}
+
+	updated, err := a.Admins.SetAdminDisabled(username, disabled)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to update admin %s", username)
+		pkg.SendErrorResponse(w, "Failed to update admin", http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		pkg.SendErrorResponse(w, "Admin not found", http.StatusNotFound)
+		return
+	}
+	if disabled {
+		if err := a.Sessions.RevokeAllSessions(username); err != nil {
+			log.Error().Err(err).Msgf("Failed to revoke sessions of admin %s", username)
+		}
+		log.Info().Msgf("Admin %s disabled admin %s", middleware.AdminUsername(r.Context()), username)
+		pkg.SendSuccessResponse(w, "Admin disabled")
+		return
+	}
+	log.Info().Msgf("Admin %s enabled admin %s", middleware.AdminUsername(r.Context()), username)
+	pkg.SendSuccessResponse(w, "Admin enabled")
+}
+
+func (a *App) DeleteAdmin(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if username == middleware.AdminUsername(r.Context()) {
+		pkg.SendErrorResponse(w, "Admins cannot delete themselves", http.StatusForbidden)
+		return
+	}
+	if a.isLastActiveSuperadmin(w, username) {
+		return
+	}
+
+	deleted, err := a.Admins.DeleteAdmin(username)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to delete admin %s", username)
+		pkg.SendErrorResponse(w, "Failed to delete admin", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		pkg.SendErrorResponse(w, "Admin not found", http.StatusNotFound)
+		return
+	}
+	log.Info().Msgf("Admin %s deleted admin %s", middleware.AdminUsername(r.Context()), username)
+	pkg.SendSuccessResponse(w, "Admin deleted")
+}
+
+// ForceAdminPasswordChange ends the admin's sessions and makes them change their password on next login.
+func (a *App) ForceAdminPasswordChange(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	updated, err := a.Admins.ForceAdminPasswordChange(username)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to force password change for admin %s", username)
+		pkg.SendErrorResponse(w, "Failed to force password change", http.StatusInternalServerError)
+		return
+	}
+	if !updated {
+		pkg.SendErrorResponse(w, "Admin not found", http.StatusNotFound)
+		return
+	}
+	if err := a.Sessions.RevokeAllSessions(username); err != nil {
+		log.Error().Err(err).Msgf("Failed to revoke sessions of admin %s", username)
+	}
+	log.Info().Msgf("Admin %s forced a password change for admin %s", middleware.AdminUsername(r.Context()), username)
+	pkg.SendSuccessResponse(w, "Admin must change their password on next login")
+}
+
+// isLastActiveSuperadmin writes an error response and returns true when removing the admin
+// would leave the installation without an active superadmin.
+func (a *App) isLastActiveSuperadmin(w http.ResponseWriter, username string) bool {
+	isLast, err := a.Admins.IsLastActiveSuperadmin(username)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to count superadmins")
+		pkg.SendErrorResponse(w, "Failed to update admin", http.StatusInternalServerError)
+		return true
+	}
+	if isLast {
+		pkg.SendErrorResponse(w, "At least one active superadmin is required", http.StatusConflict)
+	}
+	return isLast
+}
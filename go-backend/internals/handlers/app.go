@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"go-backend/internals/config"
+	"go-backend/internals/engine"
+	"go-backend/internals/events"
+	"go-backend/internals/middleware"
+	"go-backend/internals/pkg"
+	"go-backend/internals/service"
+	"go-backend/models"
+)
+
+// App holds everything the handlers depend on. The stores are backed by PostgreSQL in production and
+// can be replaced with fakes to exercise a handler on its own.
+type App struct {
+	Config      *config.Config
+	Events      *events.Broker
+	Admins      AdminStore
+	Sessions    SessionStore
+	MFA         MFAStore
+	Lockouts    LockoutStore
+	Audit       AuditStore
+	Jobs        service.PasswordJobStore
+	Servers     ServerStore
+	Topology    TopologyStore
+	Procedures  ProcedureStore
+	Access      AccessStore
+	Grants      GrantStore
+	Idempotency middleware.IdempotencyStore
+	Gateway     service.Gateway
+	Mailer      service.Mailer
+	Clock       service.Clock
+}
+
+// Store is a single backend for every store of the App, as store.Postgres is.
+type Store interface {
+	AdminStore
+	SessionStore
+	MFAStore
+	LockoutStore
+	AuditStore
+	service.PasswordJobStore
+	ServerStore
+	TopologyStore
+	ProcedureStore
+	AccessStore
+	GrantStore
+	middleware.IdempotencyStore
+}
+
+func NewApp(cfg *config.Config, broker *events.Broker, store Store, gateway service.Gateway, mailer service.Mailer, clock service.Clock) *App {
+	return &App{
+		Config:      cfg,
+		Events:      broker,
+		Admins:      store,
+		Sessions:    store,
+		MFA:         store,
+		Lockouts:    store,
+		Audit:       store,
+		Jobs:        store,
+		Servers:     store,
+		Topology:    store,
+		Procedures:  store,
+		Access:      store,
+		Grants:      store,
+		Idempotency: store,
+		Gateway:     gateway,
+		Mailer:      mailer,
+		Clock:       clock,
+	}
+}
+
+// PasswordJobs returns the runner for the password updates queued by UpdatePassword.
+func (a *App) PasswordJobs() *service.PasswordJobs {
+	return &service.PasswordJobs{
+		Config:   a.Config,
+		Events:   a.Events,
+		Jobs:     a.Jobs,
+		Audit:    a.Audit,
+		Lockouts: a.Lockouts,
+		Replicas: a.Topology,
+		Gateway:  a.Gateway,
+		Mailer:   a.Mailer,
+		Clock:    a.Clock,
+	}
+}
+
+// AdminStore manages the admin accounts. The methods that change an admin report false when it does not exist.
+type AdminStore interface {
+	CheckAdminCredentials(username, password string) (bool, error)
+	ListAdmins() ([]models.Admin, error)
+	// CreateAdmin returns store.ErrAdminExists if the username is taken
+	CreateAdmin(username, password, role string) error
+	CreateFirstAdmin(token, username, password string) error
+	ChangeAdminPassword(username, password string) error
+	ForceAdminPasswordChange(username string) (bool, error)
+	SetAdminDisabled(username string, disabled bool) (bool, error)
+	SetAdminRole(username, role string) (bool, error)
+	DeleteAdmin(username string) (bool, error)
+	IsLastActiveSuperadmin(username string) (bool, error)
+}
+
+type SessionStore interface {
+	middleware.SessionLookup
+	CreateSession(username string) (pkg.Session, error)
+	RefreshSession(session pkg.Session) (pkg.Session, error)
+	RevokeSession(session pkg.Session) error
+	RevokeAllSessions(username string) error
+}
+
+type MFAStore interface {
+	IsMFAEnabled(username string) (bool, error)
+	StartMFAEnrollment(username string) (secret, uri string, err error)
+	ConfirmMFAEnrollment(username, code string) ([]string, error)
+	DisableMFA(username string) error
+	VerifyMFACode(username, code string) (bool, error)
+	CreateMFAChallenge(username string) (string, error)
+	MFAChallengeUsername(token string) (string, error)
+	CompleteMFAChallenge(token, code, recoveryCode string) (string, error)
+}
+
+type LockoutStore interface {
+	service.LoginFailures
+	CheckLockout(scope, username, clientIP string) (time.Duration, error)
+}
+
+type AuditStore interface {
+	service.AuditLog
+	LogPasswordUpdate(requestID, username, serverIP, requestType, requestStatus, message string)
+	ResetRequests() ([]models.ResetRequest, error)
+	ServerAttempts(correlationID string) ([]models.ServerAttempt, error)
+	PartialResets() ([]models.PartialReset, error)
+}
+
+type ServerStore interface {
+	ListServers() ([]models.DatabaseServer, error)
+	GetServer(id int) (models.DatabaseServer, error)
+	FindServer(server string) (models.DatabaseServer, error)
+	CreateServer(server models.DatabaseServer) (models.DatabaseServer, error)
+	UpdateServer(server models.DatabaseServer) (models.DatabaseServer, error)
+	DeleteServer(id int) error
+}
+
+type TopologyStore interface {
+	service.ReplicaSource
+	RefreshReplicas(ctx context.Context, eng engine.Engine, server engine.Server) (models.ServerTopology, error)
+	SetReplicaOverride(server engine.Server, override models.TopologyOverride) error
+	RemoveReplicaOverride(server engine.Server, replicaHost string) error
+}
+
+// ProcedureStore verifies and deploys the stored procedures the backend runs on a SQL Server.
+type ProcedureStore interface {
+	ServerProcedures(ctx context.Context, server engine.Server) (models.ProcedureReport, error)
+	DeployServerProcedures(ctx context.Context, server engine.Server, deployedBy string) (models.ProcedureReport, error)
+}
+
+// AccessStore holds the access requests and their approval workflow.
+type AccessStore interface {
+	CreateAccessRequest(request models.AccessRequest) (int, error)
+	AccessRequests() ([]models.AccessRequestRecord, error)
+	DecideAccessRequest(requestID int, approver, decision, comment string) (models.AccessDecisionResult, error)
+	AccessRequestDecisions(requestID int) ([]models.AccessDecision, error)
+	ApprovalStages() ([]models.ApprovalStage, error)
+	SetApprovalStages(accessLevel string, stages []models.ApprovalStage) error
+	AddApproverGroupMember(group, approver string) error
+	RemoveApproverGroupMember(group, approver string) error
+}
+
+// GrantStore holds the temporary access requests, which are granted once an admin approves them.
+type GrantStore interface {
+	RequestTemporaryAccess(request models.TemporaryAccessRequest) (int, error)
+	ApproveTemporaryAccess(requestID int, approver, comment string) (time.Time, error)
+	RejectTemporaryAccess(requestID int, approver, comment string) error
+	TemporaryAccessRequests() ([]models.TemporaryAccessRecord, error)
+	TemporaryGrants() ([]models.TemporaryGrant, error)
+	ExpireTemporaryAccess(requestID int) error
+}
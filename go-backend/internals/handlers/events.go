@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-backend/internals/events"
+	"go-backend/internals/pkg"
+	"go-backend/internals/service"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// comment lines sent while nothing happens so that proxies keep the stream open
+const keepAliveInterval = 15 * time.Second
+
+// JobEvents streams the progress of a password job as Server-Sent Events. The job ID is only known to
+// the user who queued the job, so it is what authorizes the stream. The stream starts with a "job" event
+// holding the current state, then sends a "step" event per step and ends with a final "job" event.
+func (a *App) JobEvents(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["id"]
+
+	// subscribe before reading the job so that no step falls in between
+	stream, unsubscribe := a.Events.Subscribe(events.OnTopic(events.JobTopic(jobID)))
+	defer unsubscribe()
+
+	job, err := a.Jobs.GetPasswordJob(jobID)
+	if errors.Is(err, service.ErrJobNotFound) {
+		pkg.SendErrorResponse(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msg("Failed to query job")
+		pkg.SendErrorResponse(w, "Failed to query job", http.StatusInternalServerError)
+		return
+	}
+	flusher, ok := startEventStream(w)
+	if !ok {
+		return
+	}
+	if err := writeEvent(w, flusher, events.Event{Topic: events.JobTopic(jobID), Type: "job", Data: job}); err != nil {
+		return
+	}
+	if job.Status == service.JobStatusSucceeded || job.Status == service.JobStatusFailed {
+		return
+	}
+
+	streamEvents(w, r, flusher, stream, func(event events.Event) (events.Event, bool) {
+		if event.Type != "finished" {
+			return event, true
+		}
+		job, err := a.Jobs.GetPasswordJob(jobID)
+		if err != nil {
+			log.Ctx(r.Context()).Error().Err(err).Msg("Failed to query job")
+			return event, false
+		}
+		return events.Event{Topic: event.Topic, Type: "job", Data: job}, false
+	})
+}
+
+// AdminEvents streams every event of the backend instances to an admin, including new pass_reset_logs rows.
+func (a *App) AdminEvents(w http.ResponseWriter, r *http.Request) {
+	stream, unsubscribe := a.Events.Subscribe(events.All)
+	defer unsubscribe()
+
+	flusher, ok := startEventStream(w)
+	if !ok {
+		return
+	}
+	streamEvents(w, r, flusher, stream, func(event events.Event) (events.Event, bool) {
+		return event, true
+	})
+}
+
+func startEventStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		pkg.SendErrorResponse(w, "Streaming is not supported", http.StatusInternalServerError)
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return flusher, true
+}
+
+// streamEvents writes events until the client disconnects or next returns false for the last event to send.
+func streamEvents(w http.ResponseWriter, r *http.Request, flusher http.Flusher, stream <-chan events.Event,
+	next func(events.Event) (events.Event, bool)) {
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case event, open := <-stream:
+			if !open {
+				return
+			}
+			event, more := next(event)
+			if err := writeEvent(w, flusher, event); err != nil || !more {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, flusher http.Flusher, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	flusher.Flush()
+	return nil
+}
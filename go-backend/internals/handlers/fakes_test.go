@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-backend/internals/engine"
+	"go-backend/internals/pkg"
+	"go-backend/internals/service"
+	"go-backend/models"
+)
+
+// fakeStore keeps the job queue, the audit log, the lockouts and the inventory in memory. Methods the
+// password update does not use are left to the embedded interfaces and panic when called.
+type fakeStore struct {
+	ServerStore
+	TopologyStore
+
+	mu            sync.Mutex
+	servers       map[string]models.DatabaseServer
+	jobs          map[string]*fakeJob
+	queue         []string
+	statuses      map[string]string
+	attempts      []models.ServerAttempt
+	loginFailures int
+	lockedOut     bool
+	// lockoutKeys are the usernames the lockouts were checked and counted under
+	lockoutKeys map[string]bool
+}
+
+type fakeJob struct {
+	queued service.QueuedPasswordJob
+	job    models.PasswordJob
+	order  []int
+}
+
+func newFakeStore(servers ...models.DatabaseServer) *fakeStore {
+	s := &fakeStore{servers: map[string]models.DatabaseServer{}, jobs: map[string]*fakeJob{}, statuses: map[string]string{},
+		lockoutKeys: map[string]bool{}}
+	for _, server := range servers {
+		s.servers[strings.ToLower(server.Name)] = server
+	}
+	return s
+}
+
+func (s *fakeStore) FindServer(server string) (models.DatabaseServer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.servers[strings.ToLower(server)]
+	for _, listed := range s.servers {
+		if !ok && listed.Host == server {
+			entry, ok = listed, true
+		}
+	}
+	if !ok {
+		return models.DatabaseServer{}, pkg.ErrUnknownServer
+	}
+	if !entry.Enabled {
+		return models.DatabaseServer{}, pkg.ErrServerDisabled
+	}
+	return entry, nil
+}
+
+func (s *fakeStore) findServerByHost(host string) (models.DatabaseServer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range s.servers {
+		if entry.Host != host {
+			continue
+		}
+		if !entry.Enabled {
+			return models.DatabaseServer{}, pkg.ErrServerDisabled
+		}
+		return entry, nil
+	}
+	return models.DatabaseServer{}, pkg.ErrUnknownServer
+}
+
+// Replicas reports every replica the engine finds, there are no overrides.
+func (s *fakeStore) Replicas(ctx context.Context, eng engine.Engine, server engine.Server) (models.ServerTopology, error) {
+	hosts, err := eng.FindReplicas(ctx, server)
+	if err != nil {
+		return models.ServerTopology{}, err
+	}
+	topology := models.ServerTopology{Server: server.Name, Host: server.Host, Engine: server.Engine, Replicas: []models.TopologyNode{}}
+	for _, host := range hosts {
+		topology.Replicas = append(topology.Replicas, models.TopologyNode{Host: host, Source: "discovered"})
+	}
+	return topology, nil
+}
+
+func (s *fakeStore) CheckLockout(scope, username, clientIP string) (time.Duration, error) {
+	s.mu.Lock()
+	s.lockoutKeys[username] = true
+	s.mu.Unlock()
+	if s.lockedOut {
+		return time.Minute, nil
+	}
+	return 0, nil
+}
+
+func (s *fakeStore) ClaimLoginAttempt(requestID, scope, username, clientIP, serverIP string) (time.Duration, error) {
+	if s.lockedOut {
+		return time.Minute, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockoutKeys[username] = true
+	s.loginFailures++
+	return 0, nil
+}
+
+func (s *fakeStore) ReleaseLoginAttempt(scope, username, clientIP string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loginFailures = 0
+}
+
+func (s *fakeStore) RecordLoginFailure(requestID, scope, username, clientIP, serverIP string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lockoutKeys[username] = true
+	s.loginFailures++
+}
+
+func (s *fakeStore) ClearLoginFailures(scope, username string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loginFailures = 0
+}
+
+func (s *fakeStore) LogPasswordUpdate(requestID, username, serverIP, requestType, requestStatus, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[requestID] = requestStatus
+}
+
+func (s *fakeStore) LogStatus(requestID, requestType, requestStatus, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[requestID] = requestStatus
+}
+
+func (s *fakeStore) LogServerAttempt(requestID string, attempt models.ServerAttempt) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts = append(s.attempts, attempt)
+}
+
+func (s *fakeStore) ResetRequests() ([]models.ResetRequest, error)         { return nil, nil }
+func (s *fakeStore) ServerAttempts(string) ([]models.ServerAttempt, error) { return nil, nil }
+func (s *fakeStore) PartialResets() ([]models.PartialReset, error)         { return nil, nil }
+
+func (s *fakeStore) InsertPasswordJob(job service.QueuedPasswordJob, steps []service.JobStep) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := &fakeJob{queued: job, job: models.PasswordJob{JobID: job.ID, RequestID: job.RequestID, Status: service.JobStatusQueued}}
+	s.jobs[job.ID] = stored
+	s.queue = append(s.queue, job.ID)
+	stored.addSteps(steps)
+	return nil
+}
+
+func (s *fakeStore) ClaimPasswordJob() (service.QueuedPasswordJob, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return service.QueuedPasswordJob{}, false, nil
+	}
+	stored := s.jobs[s.queue[0]]
+	s.queue = s.queue[1:]
+	stored.job.Status = service.JobStatusRunning
+	return stored.queued, true, nil
+}
+
+func (s *fakeStore) GetPasswordJob(jobID string) (models.PasswordJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.jobs[jobID]
+	if !ok {
+		return models.PasswordJob{}, service.ErrJobNotFound
+	}
+	job := stored.job
+	job.Steps = append([]models.PasswordJobStep(nil), stored.job.Steps...)
+	return job, nil
+}
+
+func (s *fakeStore) FailStalePasswordJobs(startedBefore time.Time, message string) error {
+	return nil
+}
+
+func (s *fakeStore) AddPasswordJobSteps(jobID string, steps []service.JobStep) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[jobID].addSteps(steps)
+	return nil
+}
+
+func (s *fakeStore) SetPasswordJobStep(jobID, step, status, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.jobs[jobID].job.Steps {
+		if s.jobs[jobID].job.Steps[i].Name == step {
+			s.jobs[jobID].job.Steps[i].Status = status
+			s.jobs[jobID].job.Steps[i].Message = message
+			return nil
+		}
+	}
+	return fmt.Errorf("job %s has no step %q", jobID, step)
+}
+
+func (s *fakeStore) SkipPendingPasswordJobSteps(jobID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.jobs[jobID].job.Steps {
+		if s.jobs[jobID].job.Steps[i].Status == service.StepPending {
+			s.jobs[jobID].job.Steps[i].Status = service.StepSkipped
+		}
+	}
+	return nil
+}
+
+func (s *fakeStore) FinishPasswordJob(jobID, status, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[jobID].job.Status = status
+	s.jobs[jobID].job.Message = message
+	s.jobs[jobID].queued.Payload = nil
+	return nil
+}
+
+// addSteps keeps the steps in step order, like get_password_job returns them.
+func (j *fakeJob) addSteps(steps []service.JobStep) {
+	for _, step := range steps {
+		j.job.Steps = append(j.job.Steps, models.PasswordJobStep{Name: step.Name, Status: service.StepPending})
+		j.order = append(j.order, step.Order)
+	}
+	for i := 1; i < len(j.order); i++ {
+		for k := i; k > 0 && j.order[k] < j.order[k-1]; k-- {
+			j.order[k], j.order[k-1] = j.order[k-1], j.order[k]
+			j.job.Steps[k], j.job.Steps[k-1] = j.job.Steps[k-1], j.job.Steps[k]
+		}
+	}
+}
+
+// fakeGateway resolves servers from the fake inventory to a single fakeEngine.
+type fakeGateway struct {
+	store   *fakeStore
+	engine  *fakeEngine
+	catalog pkg.LoginCatalog
+}
+
+func (g *fakeGateway) ResolveServer(ctx context.Context, name string) (engine.Engine, engine.Server, error) {
+	entry, err := g.store.FindServer(name)
+	if err != nil {
+		return nil, engine.Server{}, err
+	}
+	return g.engine, engine.Server{ID: entry.ID, Name: entry.Name, Host: entry.Host, Port: entry.Port, Engine: entry.Engine}, nil
+}
+
+// ResolveReplicas refuses replicas that are not enabled in the fake inventory like engine.ResolveReplicas.
+func (g *fakeGateway) ResolveReplicas(primary engine.Server, hosts []string) ([]engine.Server, error) {
+	var replicas []engine.Server
+	for _, host := range hosts {
+		entry, err := g.store.findServerByHost(host)
+		if err != nil {
+			return nil, fmt.Errorf("replica %s of %s: %w", host, primary.Name, err)
+		}
+		replicas = append(replicas, engine.Server{ID: entry.ID, Name: entry.Name, Host: entry.Host, Port: entry.Port, Engine: entry.Engine})
+	}
+	return replicas, nil
+}
+
+func (g *fakeGateway) ValidateLoginOwner(ctx context.Context, username, serverIP, email string) (bool, error) {
+	return g.catalog.ValidateUserCredentials(ctx, username, serverIP, email)
+}
+
+func (g *fakeGateway) ValidateAccessRequestLogin(ctx context.Context, username string) (bool, error) {
+	return g.catalog.ValidateAccessRequest(ctx, username)
+}
+
+// fakeEngine answers ownership, expiry and replicas from the catalog like the SQL Server engine does and
+// keeps the passwords of the logins per host. Hosts in down refuse every change.
+type fakeEngine struct {
+	catalog pkg.LoginCatalog
+
+	mu        sync.Mutex
+	passwords map[string]string
+	down      map[string]bool
+}
+
+func newFakeEngine(catalog pkg.LoginCatalog) *fakeEngine {
+	return &fakeEngine{catalog: catalog, passwords: map[string]string{}, down: map[string]bool{}}
+}
+
+func (e *fakeEngine) Name() string {
+	return engine.MSSQL
+}
+
+func (e *fakeEngine) ValidateOwner(ctx context.Context, server engine.Server, username, email string) (bool, error) {
+	return e.catalog.ValidateUserCredentials(ctx, username, server.Host, email)
+}
+
+func (e *fakeEngine) CheckExpiry(ctx context.Context, server engine.Server, username string) (bool, error) {
+	return e.catalog.CheckLoginExpiration(ctx, username, server.Host)
+}
+
+func (e *fakeEngine) VerifyPassword(ctx context.Context, server engine.Server, username, password, database string) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.passwords[username+"@"+server.Host] == password, nil
+}
+
+func (e *fakeEngine) ChangePassword(ctx context.Context, server engine.Server, username, newPassword string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.down[server.Host] {
+		return errors.New("connection refused")
+	}
+	e.passwords[username+"@"+server.Host] = newPassword
+	return nil
+}
+
+func (e *fakeEngine) FindReplicas(ctx context.Context, server engine.Server) ([]string, error) {
+	return e.catalog.FindRelatedServers(ctx, server.Host)
+}
+
+func (e *fakeEngine) password(username, host string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.passwords[username+"@"+host]
+}
+
+type fakeMailer struct {
+	err  error
+	sent []string
+}
+
+func (m *fakeMailer) SendConfirmation(to, username string) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
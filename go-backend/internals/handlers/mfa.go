@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"go-backend/internals/middleware"
+	"go-backend/internals/pkg"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// AdminLoginMFA is the second login step: it exchanges the token from AdminLogin plus a TOTP
+// or recovery code for a session.
+func (a *App) AdminLoginMFA(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		MFAToken     string `json:"mfaToken"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recoveryCode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	if body.MFAToken == "" || (body.Code == "" && body.RecoveryCode == "") {
+		pkg.SendErrorResponse(w, "Login token and code are required", http.StatusBadRequest)
+		return
+	}
+
+	username, err := a.MFA.MFAChallengeUsername(body.MFAToken)
+	if errors.Is(err, pkg.ErrInvalidMFAChallenge) {
+		log.Info().Msgf("Two-factor login step failed: %v", err)
+		pkg.SendErrorResponse(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to look up two-factor challenge")
+		pkg.SendErrorResponse(w, "Failed to verify two-factor code", http.StatusInternalServerError)
+		return
+	}
+
+	clientIP := pkg.ClientIP(r, a.Config.Server)
+	retryAfter, err := a.Lockouts.ClaimLoginAttempt(pkg.RequestIDFromContext(r.Context()), pkg.ScopeAdminLogin, username, clientIP, clientIP)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to check admin login lockout")
+		pkg.SendErrorResponse(w, "Failed to verify two-factor code", http.StatusInternalServerError)
+		return
+	}
+	if retryAfter > 0 {
+		pkg.SendLockoutResponse(w, retryAfter)
+		return
+	}
+
+	username, err = a.MFA.CompleteMFAChallenge(body.MFAToken, body.Code, body.RecoveryCode)
+	switch {
+	case errors.Is(err, pkg.ErrInvalidMFACode):
+		log.Info().Msgf("Two-factor login step failed for %s: %v", username, err)
+		pkg.SendErrorResponse(w, err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, pkg.ErrInvalidMFAChallenge):
+		log.Info().Msgf("Two-factor login step failed: %v", err)
+		pkg.SendErrorResponse(w, err.Error(), http.StatusUnauthorized)
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to verify two-factor code")
+		pkg.SendErrorResponse(w, "Failed to verify two-factor code", http.StatusInternalServerError)
+		return
+	}
+	if body.RecoveryCode != "" {
+		log.Warn().Msgf("Admin %s logged in with a recovery code", username)
+	}
+
+	a.Lockouts.ReleaseLoginAttempt(pkg.ScopeAdminLogin, username, clientIP)
+	a.startAdminSession(w, username, "Admin login successful")
+}
+
+func (a *App) EnrollMFA(w http.ResponseWriter, r *http.Request) {
+	username := middleware.AdminUsername(r.Context())
+	secret, uri, err := a.MFA.StartMFAEnrollment(username)
+	if errors.Is(err, pkg.ErrMFAAlreadyEnabled) {
+		pkg.SendErrorResponse(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to start two-factor enrollment for %s", username)
+		pkg.SendErrorResponse(w, "Failed to start two-factor enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	pkg.SendJSONResponse(w, map[string]string{
+		"message":         "Scan the provisioning URI with an authenticator app and verify the first code",
+		"secret":          secret,
+		"provisioningURI": uri,
+	}, http.StatusOK)
+}
+
+func (a *App) VerifyMFAEnrollment(w http.ResponseWriter, r *http.Request) {
+	username := middleware.AdminUsername(r.Context())
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := a.MFA.ConfirmMFAEnrollment(username, body.Code)
+	switch {
+	case errors.Is(err, pkg.ErrMFANotEnrolled):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, pkg.ErrInvalidMFACode):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Error().Err(err).Msgf("Failed to confirm two-factor enrollment for %s", username)
+		pkg.SendErrorResponse(w, "Failed to confirm two-factor enrollment", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Msgf("Admin %s enabled two-factor authentication", username)
+	pkg.SendJSONResponse(w, map[string]interface{}{
+		"message":       "Two-factor authentication enabled, store the recovery codes somewhere safe",
+		"recoveryCodes": codes,
+	}, http.StatusOK)
+}
+
+// DisableMFA turns off two-factor authentication for the logged in admin after checking a current code.
+func (a *App) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	username := middleware.AdminUsername(r.Context())
+	var body struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	valid, err := a.MFA.VerifyMFACode(username, body.Code)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to verify two-factor code")
+		pkg.SendErrorResponse(w, "Failed to verify two-factor code", http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		pkg.SendErrorResponse(w, pkg.ErrInvalidMFACode.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err := a.MFA.DisableMFA(username); err != nil {
+		log.Error().Err(err).Msgf("Failed to disable two-factor authentication for %s", username)
+		pkg.SendErrorResponse(w, "Failed to disable two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Msgf("Admin %s disabled two-factor authentication", username)
+	pkg.SendSuccessResponse(w, "Two-factor authentication disabled")
+}
+
+// ResetAdminMFA clears the authenticator of another admin, e.g. after a lost device, and ends their sessions.
+func (a *App) ResetAdminMFA(w http.ResponseWriter, r *http.Request) {
+	username := mux.Vars(r)["username"]
+	if err := a.MFA.DisableMFA(username); err != nil {
+		log.Error().Err(err).Msgf("Failed to reset two-factor authentication for %s", username)
+		pkg.SendErrorResponse(w, "Failed to reset two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	if err := a.Sessions.RevokeAllSessions(username); err != nil {
+		log.Error().Err(err).Msgf("Failed to revoke sessions of admin %s", username)
+	}
+	log.Info().Msgf("Admin %s reset two-factor authentication of %s", middleware.AdminUsername(r.Context()), username)
+	pkg.SendSuccessResponse(w, "Two-factor authentication reset")
+}
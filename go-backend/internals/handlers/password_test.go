@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go-backend/internals/config"
+	"go-backend/internals/events"
+	"go-backend/internals/pkg"
+	"go-backend/internals/pkg/catalogtest"
+	"go-backend/internals/service"
+	"go-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	primaryHost = "10.0.0.11"
+	replicaHost = "10.0.0.12"
+)
+
+// passwordFixture is an availability group of two SQL Server instances with the login app_user owned by
+// owner@example.com and the password "old-password" on both.
+type passwordFixture struct {
+	app     *App
+	store   *fakeStore
+	catalog *catalogtest.Catalog
+	engine  *fakeEngine
+	mailer  *fakeMailer
+}
+
+func newPasswordFixture(t *testing.T) *passwordFixture {
+	t.Helper()
+	// retries without delay keep the replica failure fast
+	c := config.Default()
+	c.PasswordSync.Attempts = 2
+	c.PasswordSync.RetryDelay = 0
+	c.Jobs.EncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+	store := newFakeStore(
+		models.DatabaseServer{ID: 1, Name: "sales-db-01", Host: primaryHost, Port: 1433, Engine: "mssql", Enabled: true},
+		models.DatabaseServer{ID: 2, Name: "sales-db-02", Host: replicaHost, Port: 1433, Engine: "mssql", Enabled: true},
+	)
+	catalog := catalogtest.New()
+	catalog.AddLogin("app_user", primaryHost, "owner@example.com")
+	catalog.AddLogin("app_user", replicaHost, "owner@example.com")
+	catalog.SetReplicas(primaryHost, replicaHost)
+	eng := newFakeEngine(catalog)
+	eng.passwords["app_user@"+primaryHost] = "old-password"
+	eng.passwords["app_user@"+replicaHost] = "old-password"
+	mailer := &fakeMailer{}
+
+	app := &App{
+		Config:   c,
+		Events:   events.NewBroker(),
+		Lockouts: store,
+		Audit:    store,
+		Jobs:     store,
+		Servers:  store,
+		Topology: store,
+		Gateway:  &fakeGateway{store: store, engine: eng, catalog: catalog},
+		Mailer:   mailer,
+		Clock:    fixedClock{now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+	}
+	return &passwordFixture{app: app, store: store, catalog: catalog, engine: eng, mailer: mailer}
+}
+
+func (f *passwordFixture) updatePassword(t *testing.T, request models.UpdatePasswordRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(request)
+	rec := httptest.NewRecorder()
+	f.app.UpdatePassword(rec, httptest.NewRequest(http.MethodPut, "/update-password", bytes.NewReader(body)))
+	return rec
+}
+
+// runJob runs the queued job and returns it as GET /jobs/{id} reports it.
+func (f *passwordFixture) runJob(t *testing.T, jobID string) models.PasswordJob {
+	t.Helper()
+	if processed, err := f.app.PasswordJobs().RunNext(context.Background()); err != nil || !processed {
+		t.Fatalf("RunNext = %v, %v, want the queued job to run", processed, err)
+	}
+	rec := httptest.NewRecorder()
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/jobs/"+jobID, nil), map[string]string{"id": jobID})
+	f.app.GetJob(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /jobs/%s = %d %s", jobID, rec.Code, rec.Body)
+	}
+	var job models.PasswordJob
+	if err := json.Unmarshal(rec.Body.Bytes(), &job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func stepStatus(job models.PasswordJob, name string) string {
+	for _, step := range job.Steps {
+		if step.Name == name {
+			return step.Status
+		}
+	}
+	return ""
+}
+
+func TestUpdatePassword(t *testing.T) {
+	valid := models.UpdatePasswordRequest{
+		Username:    "app_user",
+		Email:       "owner@example.com",
+		OldPassword: "old-password",
+		NewPassword: "new-password",
+		ServerIP:    "sales-db-01",
+	}
+
+	cases := []struct {
+		name   string
+		change func(*passwordFixture, *models.UpdatePasswordRequest)
+		// queued runs between queueing the job and running it
+		queued  func(*passwordFixture)
+		code    int
+		message string
+		// expectations on the job, only checked when the request is queued
+		jobStatus     string
+		failedStep    string
+		passwords     map[string]string
+		loginFailures int
+		mails         int
+	}{
+		{
+			name:      "success",
+			code:      http.StatusAccepted,
+			jobStatus: service.JobStatusSucceeded,
+			passwords: map[string]string{primaryHost: "new-password", replicaHost: "new-password"},
+			mails:     1,
+		},
+		{
+			name:    "same password",
+			change:  func(_ *passwordFixture, r *models.UpdatePasswordRequest) { r.NewPassword = r.OldPassword },
+			code:    http.StatusBadRequest,
+			message: "New password cannot be the same as the old password",
+		},
+		{
+			name:    "locked out",
+			change:  func(f *passwordFixture, _ *models.UpdatePasswordRequest) { f.store.lockedOut = true },
+			code:    http.StatusTooManyRequests,
+			message: "Too many failed attempts",
+		},
+		{
+			name:    "server not in the inventory",
+			change:  func(_ *passwordFixture, r *models.UpdatePasswordRequest) { r.ServerIP = "unknown-db" },
+			code:    http.StatusBadRequest,
+			message: "server is not in the inventory",
+		},
+		{
+			name:          "invalid owner",
+			change:        func(_ *passwordFixture, r *models.UpdatePasswordRequest) { r.Email = "someone@example.com" },
+			code:          http.StatusAccepted,
+			jobStatus:     service.JobStatusFailed,
+			failedStep:    "Validate credentials",
+			passwords:     map[string]string{primaryHost: "old-password", replicaHost: "old-password"},
+			loginFailures: 1,
+		},
+		{
+			name:       "expired login",
+			change:     func(f *passwordFixture, _ *models.UpdatePasswordRequest) { f.catalog.Expire("app_user", primaryHost) },
+			code:       http.StatusAccepted,
+			jobStatus:  service.JobStatusFailed,
+			failedStep: "Check login expiry",
+			passwords:  map[string]string{primaryHost: "old-password", replicaHost: "old-password"},
+		},
+		{
+			name:          "wrong old password",
+			change:        func(_ *passwordFixture, r *models.UpdatePasswordRequest) { r.OldPassword = "guess" },
+			code:          http.StatusAccepted,
+			jobStatus:     service.JobStatusFailed,
+			failedStep:    "Check old password",
+			passwords:     map[string]string{primaryHost: "old-password", replicaHost: "old-password"},
+			loginFailures: 1,
+		},
+		{
+			// a job queued before the lockout must not get another guess
+			name:       "locked out while queued",
+			queued:     func(f *passwordFixture) { f.store.lockedOut = true },
+			code:       http.StatusAccepted,
+			jobStatus:  service.JobStatusFailed,
+			failedStep: "Check old password",
+			passwords:  map[string]string{primaryHost: "old-password", replicaHost: "old-password"},
+		},
+		{
+			name:       "catalog unreachable",
+			change:     func(f *passwordFixture, _ *models.UpdatePasswordRequest) { f.catalog.Err = errors.New("login timeout") },
+			code:       http.StatusAccepted,
+			jobStatus:  service.JobStatusFailed,
+			failedStep: "Validate credentials",
+			passwords:  map[string]string{primaryHost: "old-password", replicaHost: "old-password"},
+		},
+		{
+			// a replica is never reached with the port and credentials of the primary
+			name: "replica disabled in the inventory",
+			change: func(f *passwordFixture, _ *models.UpdatePasswordRequest) {
+				replica := f.store.servers["sales-db-02"]
+				replica.Enabled = false
+				f.store.servers["sales-db-02"] = replica
+			},
+			code:       http.StatusAccepted,
+			jobStatus:  service.JobStatusFailed,
+			failedStep: "Find replicas",
+			passwords:  map[string]string{primaryHost: "old-password", replicaHost: "old-password"},
+		},
+		{
+			// the primary is rolled back so the login keeps one password across the group
+			name:       "replica failure",
+			change:     func(f *passwordFixture, _ *models.UpdatePasswordRequest) { f.engine.down[replicaHost] = true },
+			code:       http.StatusAccepted,
+			jobStatus:  service.JobStatusFailed,
+			failedStep: "Update password on " + replicaHost,
+			passwords:  map[string]string{primaryHost: "old-password", replicaHost: "old-password"},
+		},
+		{
+			// the password stays changed, only the email step is marked as failed
+			name:       "email failure",
+			change:     func(f *passwordFixture, _ *models.UpdatePasswordRequest) { f.mailer.err = errors.New("smtp: 554") },
+			code:       http.StatusAccepted,
+			jobStatus:  service.JobStatusSucceeded,
+			failedStep: "Send confirmation email",
+			passwords:  map[string]string{primaryHost: "new-password", replicaHost: "new-password"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newPasswordFixture(t)
+			request := valid
+			if tc.change != nil {
+				tc.change(f, &request)
+			}
+
+			rec := f.updatePassword(t, request)
+			if rec.Code != tc.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.code, rec.Body)
+			}
+			var response map[string]string
+			json.Unmarshal(rec.Body.Bytes(), &response)
+			if tc.code != http.StatusAccepted {
+				if !bytes.Contains(rec.Body.Bytes(), []byte(tc.message)) {
+					t.Errorf("body = %s, want %q", rec.Body, tc.message)
+				}
+				if len(f.store.jobs) != 0 {
+					t.Errorf("a rejected request must not queue a job")
+				}
+				return
+			}
+			if rec.Header().Get("Location") != "/jobs/"+response["jobID"] {
+				t.Errorf("Location = %q, want the job of %s", rec.Header().Get("Location"), response["jobID"])
+			}
+
+			if tc.queued != nil {
+				tc.queued(f)
+			}
+			job := f.runJob(t, response["jobID"])
+			if job.Status != tc.jobStatus {
+				t.Errorf("job status = %s (%s), want %s", job.Status, job.Message, tc.jobStatus)
+			}
+			if tc.failedStep != "" {
+				if got := stepStatus(job, tc.failedStep); got != service.StepFailed {
+					t.Errorf("step %q = %q, want %s; steps %+v", tc.failedStep, got, service.StepFailed, job.Steps)
+				}
+			}
+			if tc.jobStatus == service.JobStatusFailed && stepStatus(job, "Send confirmation email") != service.StepSkipped {
+				t.Errorf("a failed job must skip the email, steps %+v", job.Steps)
+			}
+			for host, want := range tc.passwords {
+				if got := f.engine.password("app_user", host); got != want {
+					t.Errorf("password on %s = %q, want %q", host, got, want)
+				}
+			}
+			if f.store.loginFailures != tc.loginFailures {
+				t.Errorf("login failures = %d, want %d", f.store.loginFailures, tc.loginFailures)
+			}
+			if len(f.mailer.sent) != tc.mails {
+				t.Errorf("mails sent = %d, want %d", len(f.mailer.sent), tc.mails)
+			}
+		})
+	}
+}
+
+func TestUpdatePasswordKeepsPasswordsOutOfTheQueue(t *testing.T) {
+	f := newPasswordFixture(t)
+	rec := f.updatePassword(t, models.UpdatePasswordRequest{
+		Username: "app_user", Email: "owner@example.com", OldPassword: "old-password", NewPassword: "new-password", ServerIP: "sales-db-01",
+	})
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	for _, stored := range f.store.jobs {
+		if stored.queued.Request.OldPassword != "" || stored.queued.Request.NewPassword != "" {
+			t.Errorf("queued request holds the passwords in plain text")
+		}
+		if bytes.Contains(stored.queued.Payload, []byte("new-password")) {
+			t.Errorf("payload is not encrypted")
+		}
+	}
+}
+
+// The lockout of a login must not depend on whether the user entered the name or the host of the server.
+func TestUpdatePasswordLocksOutPerInventoryEntry(t *testing.T) {
+	f := newPasswordFixture(t)
+	for _, server := range []string{"sales-db-01", primaryHost} {
+		rec := f.updatePassword(t, models.UpdatePasswordRequest{
+			Username: "app_user", Email: "owner@example.com", OldPassword: "guess", NewPassword: "new-password", ServerIP: server,
+		})
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("status for %s = %d: %s", server, rec.Code, rec.Body)
+		}
+		var response map[string]string
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		f.runJob(t, response["jobID"])
+	}
+	want := pkg.PasswordLockoutKey("app_user", 1)
+	if len(f.store.lockoutKeys) != 1 || !f.store.lockoutKeys[want] {
+		t.Errorf("lockout keys = %v, want only %q", f.store.lockoutKeys, want)
+	}
+	if f.store.loginFailures != 2 {
+		t.Errorf("login failures = %d, want both attempts counted", f.store.loginFailures)
+	}
+}
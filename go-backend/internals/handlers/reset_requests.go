@@ -1,52 +1,45 @@
 package handlers
 
 import (
-	"go-backend/internals/database"
 	"go-backend/internals/pkg"
-	"go-backend/models"
 	"net/http"
 
-	"github.com/lib/pq"
+	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 )
 
-
-func GetAllResetReq(w http.ResponseWriter, r *http.Request) {
-	db := database.GetDB()
-	rows, err := db.Query("SELECT * FROM get_all_logs()")
+func (a *App) GetAllResetReq(w http.ResponseWriter, r *http.Request) {
+	requests, err := a.Audit.ResetRequests()
 	if err != nil {
-			pkg.SendErrorResponse2(w, "Failed to query reset requests", http.StatusInternalServerError)
-			return
-	}
-	defer rows.Close()
-	
-	var requests []models.ResetRequest
-	for rows.Next() {
-		var request models.ResetRequest
-		var requestTime pq.NullTime
-	
-		if err := rows.Scan(&request.RequestID, &request.Username, &request.ServerIP, &request.RequestType, &request.RequestStatus, &request.Message, &requestTime); err != nil {
-			pkg.SendErrorResponse(w, "Failed to scan reset requests", http.StatusInternalServerError)
-			log.Error().Msgf("Failed to scan reset requests: %v", err)
-			return
-		}
-	
-		if requestTime.Valid {
-			request.RequestTime = requestTime.Time.Format("2006-01-02 15:04:05")
-		} else {
-			request.RequestTime = "N/A"
-		}
-	
-		requests = append(requests, request)
-	}
-	
-	if err := rows.Err(); err != nil {
-		pkg.SendErrorResponse2(w, "Failed to iterate over reset requests", http.StatusInternalServerError)
-		log.Error().Msgf("Failed to iterate over reset requests: %v", err)
+		log.Error().Msgf("Failed to query reset requests: %v", err)
+		pkg.SendErrorResponse2(w, "Failed to query reset requests", http.StatusInternalServerError)
 		return
 	}
-	
 	pkg.SendJSONResponse(w, requests, http.StatusOK)
+}
+
+// GetPasswordUpdateServers returns the per-server breakdown of one password update, looked up by its correlation ID.
+func (a *App) GetPasswordUpdateServers(w http.ResponseWriter, r *http.Request) {
+	attempts, err := a.Audit.ServerAttempts(mux.Vars(r)["correlationID"])
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query server attempts")
+		pkg.SendErrorResponse2(w, "Failed to query server attempts", http.StatusInternalServerError)
+		return
 	}
-	
+	if len(attempts) == 0 {
+		pkg.SendErrorResponse(w, "No server attempts found for this request", http.StatusNotFound)
+		return
+	}
+	pkg.SendJSONResponse(w, attempts, http.StatusOK)
+}
 
+// GetPartialResets lists password updates that left the login out of sync across the availability group.
+func (a *App) GetPartialResets(w http.ResponseWriter, r *http.Request) {
+	resets, err := a.Audit.PartialResets()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query partial resets")
+		pkg.SendErrorResponse2(w, "Failed to query partial resets", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, resets, http.StatusOK)
+}
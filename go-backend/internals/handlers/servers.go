@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go-backend/internals/engine"
+	"go-backend/internals/middleware"
+	"go-backend/internals/pkg"
+	"go-backend/internals/procedures"
+	"go-backend/internals/topology"
+	"go-backend/models"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+func (a *App) GetAllServers(w http.ResponseWriter, r *http.Request) {
+	servers, err := a.Servers.ListServers()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query servers")
+		pkg.SendErrorResponse(w, "Failed to query servers", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, servers, http.StatusOK)
+}
+
+func (a *App) GetServer(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	server, err := a.Servers.GetServer(id)
+	if err != nil {
+		sendServerError(w, err, "Failed to query server")
+		return
+	}
+	pkg.SendJSONResponse(w, server, http.StatusOK)
+}
+
+// CreateServer adds a server to the inventory. A server without a port gets the default port of its engine.
+func (a *App) CreateServer(w http.ResponseWriter, r *http.Request) {
+	var request models.DatabaseServer
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	server, err := a.Servers.CreateServer(request)
+	if err != nil {
+		sendServerError(w, err, "Failed to create server")
+		return
+	}
+	log.Info().Msgf("Admin %s added server %s (%s:%d, %s)", middleware.AdminUsername(r.Context()), server.Name, server.Host, server.Port, server.Engine)
+	pkg.SendJSONResponse(w, server, http.StatusCreated)
+}
+
+// UpdateServer replaces every field of an inventory entry.
+func (a *App) UpdateServer(w http.ResponseWriter, r *http.Request) {
+	var request models.DatabaseServer
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	request.ID, _ = strconv.Atoi(mux.Vars(r)["id"])
+	server, err := a.Servers.UpdateServer(request)
+	if err != nil {
+		sendServerError(w, err, "Failed to update server")
+		return
+	}
+	log.Info().Msgf("Admin %s updated server %s (%s:%d, %s, enabled %t)", middleware.AdminUsername(r.Context()),
+		server.Name, server.Host, server.Port, server.Engine, server.Enabled)
+	pkg.SendJSONResponse(w, server, http.StatusOK)
+}
+
+func (a *App) DeleteServer(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(mux.Vars(r)["id"])
+	if err := a.Servers.DeleteServer(id); err != nil {
+		sendServerError(w, err, "Failed to delete server")
+		return
+	}
+	log.Info().Msgf("Admin %s deleted server %d", middleware.AdminUsername(r.Context()), id)
+	pkg.SendJSONResponse(w, map[string]string{"message": "Server deleted successfully"}, http.StatusOK)
+}
+
+func sendServerError(w http.ResponseWriter, err error, message string) {
+	switch {
+	case errors.Is(err, pkg.ErrInvalidServer):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, pkg.ErrServerNotFound):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, pkg.ErrDuplicateServer):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusConflict)
+	default:
+		log.Error().Err(err).Msg(message)
+		pkg.SendErrorResponse(w, message, http.StatusInternalServerError)
+	}
+}
+
+// GetServerReplicas shows which replicas a password update on the server will touch, so users can check
+// before submitting. The server is the name or host the user would enter.
+func (a *App) GetServerReplicas(w http.ResponseWriter, r *http.Request) {
+	eng, server, ok := a.resolveServer(w, r)
+	if !ok {
+		return
+	}
+	serverTopology, err := a.Topology.Replicas(r.Context(), eng, server)
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msgf("Failed to find the replicas of %s", server.Name)
+		pkg.SendErrorResponse(w, "Failed to find the replicas of the server", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, serverTopology, http.StatusOK)
+}
+
+// RefreshServerReplicas fetches the replicas of the server again instead of waiting for the cache to expire.
+func (a *App) RefreshServerReplicas(w http.ResponseWriter, r *http.Request) {
+	eng, server, ok := a.resolveServer(w, r)
+	if !ok {
+		return
+	}
+	serverTopology, err := a.Topology.RefreshReplicas(r.Context(), eng, server)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to refresh the replicas of %s", server.Name)
+		pkg.SendErrorResponse(w, "Failed to refresh the replicas of the server", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Msgf("Admin %s refreshed the replicas of %s", middleware.AdminUsername(r.Context()), server.Name)
+	pkg.SendJSONResponse(w, serverTopology, http.StatusOK)
+}
+
+// SetReplicaOverride pins a replica to the server or excludes it, the body is {"action": "pin" | "exclude"}.
+func (a *App) SetReplicaOverride(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	_, server, ok := a.resolveServer(w, r)
+	if !ok {
+		return
+	}
+	replica := mux.Vars(r)["replica"]
+	err := a.Topology.SetReplicaOverride(server, models.TopologyOverride{
+		ReplicaHost: replica,
+		Action:      body.Action,
+		CreatedBy:   middleware.AdminUsername(r.Context()),
+	})
+	if errors.Is(err, topology.ErrInvalidOverride) {
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to override replica %s of %s", replica, server.Name)
+		pkg.SendErrorResponse(w, "Failed to override replica", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Msgf("Admin %s set %s on replica %s of %s", middleware.AdminUsername(r.Context()), body.Action, replica, server.Name)
+	pkg.SendJSONResponse(w, map[string]string{"message": "Replica override saved"}, http.StatusOK)
+}
+
+func (a *App) RemoveReplicaOverride(w http.ResponseWriter, r *http.Request) {
+	_, server, ok := a.resolveServer(w, r)
+	if !ok {
+		return
+	}
+	replica := mux.Vars(r)["replica"]
+	err := a.Topology.RemoveReplicaOverride(server, replica)
+	if errors.Is(err, topology.ErrOverrideNotFound) {
+		pkg.SendErrorResponse(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to remove the override of replica %s of %s", replica, server.Name)
+		pkg.SendErrorResponse(w, "Failed to remove replica override", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Msgf("Admin %s removed the override of replica %s of %s", middleware.AdminUsername(r.Context()), replica, server.Name)
+	pkg.SendJSONResponse(w, map[string]string{"message": "Replica override removed"}, http.StatusOK)
+}
+
+// GetServerProcedures compares the stored procedures on a SQL Server with the ones built into the backend.
+func (a *App) GetServerProcedures(w http.ResponseWriter, r *http.Request) {
+	_, server, ok := a.resolveServer(w, r)
+	if !ok {
+		return
+	}
+	report, err := a.Procedures.ServerProcedures(r.Context(), server)
+	if errors.Is(err, procedures.ErrNotMSSQL) {
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msgf("Failed to verify the stored procedures of %s", server.Name)
+		pkg.SendErrorResponse(w, "Failed to verify the stored procedures of the server", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, report, http.StatusOK)
+}
+
+// DeployServerProcedures creates or alters the built-in stored procedures on a SQL Server and returns
+// the state of the server afterwards.
+func (a *App) DeployServerProcedures(w http.ResponseWriter, r *http.Request) {
+	_, server, ok := a.resolveServer(w, r)
+	if !ok {
+		return
+	}
+	admin := middleware.AdminUsername(r.Context())
+	report, err := a.Procedures.DeployServerProcedures(r.Context(), server, admin)
+	if errors.Is(err, procedures.ErrNotMSSQL) {
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Ctx(r.Context()).Error().Err(err).Msgf("Failed to deploy the stored procedures to %s", server.Name)
+		pkg.SendErrorResponse(w, "Failed to deploy the stored procedures to the server", http.StatusInternalServerError)
+		return
+	}
+	log.Info().Msgf("Admin %s deployed stored procedures version %s to %s", admin, report.Version, server.Name)
+	pkg.SendJSONResponse(w, report, http.StatusOK)
+}
+
+// resolveServer looks up the {server} of the route in the inventory and answers 404 or 400 if it cannot be used.
+func (a *App) resolveServer(w http.ResponseWriter, r *http.Request) (engine.Engine, engine.Server, bool) {
+	eng, server, err := a.Gateway.ResolveServer(r.Context(), mux.Vars(r)["server"])
+	switch {
+	case errors.Is(err, pkg.ErrUnknownServer) || errors.Is(err, pkg.ErrServerDisabled):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusNotFound)
+		return nil, engine.Server{}, false
+	case errors.Is(err, pkg.ErrAmbiguousServer):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return nil, engine.Server{}, false
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to look up the server")
+		pkg.SendErrorResponse(w, "Failed to look up the server", http.StatusInternalServerError)
+		return nil, engine.Server{}, false
+	}
+	return eng, server, true
+}
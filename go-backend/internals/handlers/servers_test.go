@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-backend/internals/engine"
+	"go-backend/internals/procedures"
+	"go-backend/models"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeProcedures reports every procedure of a SQL Server as missing until it is deployed.
+type fakeProcedures struct {
+	deployed map[string]bool
+}
+
+func (f *fakeProcedures) ServerProcedures(ctx context.Context, server engine.Server) (models.ProcedureReport, error) {
+	if server.Engine != engine.MSSQL {
+		return models.ProcedureReport{}, procedures.ErrNotMSSQL
+	}
+	status := procedures.StatusMissing
+	if f.deployed[server.Host] {
+		status = procedures.StatusCurrent
+	}
+	return models.ProcedureReport{
+		Server:     server.Name,
+		Host:       server.Host,
+		Drift:      status != procedures.StatusCurrent,
+		Procedures: []models.ProcedureStatus{{Name: "dbo.ResetUserPassword", Status: status}},
+	}, nil
+}
+
+func (f *fakeProcedures) DeployServerProcedures(ctx context.Context, server engine.Server, deployedBy string) (models.ProcedureReport, error) {
+	if server.Engine != engine.MSSQL {
+		return models.ProcedureReport{}, procedures.ErrNotMSSQL
+	}
+	f.deployed[server.Host] = true
+	return f.ServerProcedures(ctx, server)
+}
+
+func TestServerProcedures(t *testing.T) {
+	store := newFakeStore(
+		models.DatabaseServer{Name: "sales-db-01", Host: primaryHost, Port: 1433, Engine: engine.MSSQL, Enabled: true},
+		models.DatabaseServer{Name: "billing-pg", Host: "10.0.1.5", Port: 5432, Engine: engine.Postgres, Enabled: true},
+	)
+	app := &App{
+		Procedures: &fakeProcedures{deployed: map[string]bool{}},
+		Gateway:    &fakeGateway{store: store},
+	}
+	serve := func(handler http.HandlerFunc, method, server string) (int, models.ProcedureReport) {
+		req := mux.SetURLVars(httptest.NewRequest(method, "/servers/"+server+"/procedures", nil), map[string]string{"server": server})
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		var report models.ProcedureReport
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatalf("decode report: %v", err)
+			}
+		}
+		return rec.Code, report
+	}
+
+	code, report := serve(app.GetServerProcedures, http.MethodGet, "sales-db-01")
+	if code != http.StatusOK || !report.Drift || report.Procedures[0].Status != procedures.StatusMissing {
+		t.Fatalf("before deploying = %d, %+v, want the procedures missing", code, report)
+	}
+	code, report = serve(app.DeployServerProcedures, http.MethodPost, "sales-db-01")
+	if code != http.StatusOK || report.Drift || report.Host != primaryHost {
+		t.Fatalf("deploy = %d, %+v, want no drift", code, report)
+	}
+	if code, report = serve(app.GetServerProcedures, http.MethodGet, "sales-db-01"); report.Drift {
+		t.Errorf("after deploying = %d, %+v, want no drift", code, report)
+	}
+
+	if code, _ := serve(app.DeployServerProcedures, http.MethodPost, "billing-pg"); code != http.StatusBadRequest {
+		t.Errorf("deploy to PostgreSQL = %d, want 400", code)
+	}
+	if code, _ := serve(app.GetServerProcedures, http.MethodGet, "unknown-db"); code != http.StatusNotFound {
+		t.Errorf("unknown server = %d, want 404", code)
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internals/engine"
+	"go-backend/internals/middleware"
+	"go-backend/internals/pkg"
+	"go-backend/internals/service"
+	"go-backend/models"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+func (a *App) RequestTemporaryAccess(w http.ResponseWriter, r *http.Request) {
+	var request models.TemporaryAccessRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode request", http.StatusBadRequest)
+		return
+	}
+	request.Username = strings.TrimSpace(request.Username)
+	request.Database = strings.TrimSpace(request.Database)
+	request.Role = strings.ToLower(strings.TrimSpace(request.Role))
+	log.Info().Msgf("Received temporary access request for user: %s, serverIP: %s, database: %s, role: %s, hours: %d",
+		request.Username, request.ServerIP, request.Database, request.Role, request.DurationHours)
+
+	if request.Username == "" || request.Database == "" || request.ServerIP == "" {
+		pkg.SendErrorResponse(w, "Username, server and database are required", http.StatusBadRequest)
+		return
+	}
+
+	// only SQL Servers of the inventory can be targeted, the request keeps the host the grants are made on
+	server, err := a.Servers.FindServer(request.ServerIP)
+	if err != nil {
+		if errors.Is(err, pkg.ErrUnknownServer) || errors.Is(err, pkg.ErrServerDisabled) || errors.Is(err, pkg.ErrAmbiguousServer) {
+			log.Info().Msgf("Temporary access request for %s rejected: %v", request.ServerIP, err)
+			pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Error().Err(err).Msg("Failed to look up the server")
+		pkg.SendErrorResponse(w, "Failed to look up the server", http.StatusInternalServerError)
+		return
+	}
+	if server.Engine != engine.MSSQL {
+		pkg.SendErrorResponse(w, service.ErrTemporaryAccessEngine.Error(), http.StatusBadRequest)
+		return
+	}
+	request.ServerIP = server.Host
+
+	isValidUser, err := a.Gateway.ValidateLoginOwner(r.Context(), request.Username, request.ServerIP, request.Email)
+	if err != nil {
+		pkg.SendErrorResponse(w, "Failed to validate user credentials", http.StatusInternalServerError)
+		return
+	}
+	if !isValidUser {
+		pkg.SendErrorResponse(w, "Invalid user credentials", http.StatusUnauthorized)
+		return
+	}
+
+	requestID, err := a.Grants.RequestTemporaryAccess(request)
+	switch {
+	case errors.Is(err, service.ErrInvalidTemporaryRole):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrInvalidDuration):
+		pkg.SendErrorResponse(w, "Duration must be between 1 and "+strconv.Itoa(int(service.MaxTemporaryAccessDuration(a.Config.Grants).Hours()))+" hours", http.StatusBadRequest)
+		return
+	case err != nil:
+		log.Error().Err(err).Msg("Failed to record temporary access request")
+		pkg.SendErrorResponse(w, "Failed to record temporary access request", http.StatusInternalServerError)
+		return
+	}
+
+	log.Info().Msgf("Temporary access request %d recorded for user: %s, waiting for approval", requestID, request.Username)
+	pkg.SendJSONResponse(w, map[string]interface{}{
+		"message":   "Temporary access requested, it is granted once an admin approves it",
+		"requestID": requestID,
+	}, http.StatusAccepted)
+}
+
+func (a *App) GetAllTemporaryAccessReq(w http.ResponseWriter, r *http.Request) {
+	requests, err := a.Grants.TemporaryAccessRequests()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query temporary access requests")
+		pkg.SendErrorResponse2(w, "Failed to query temporary access requests", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, requests, http.StatusOK)
+}
+
+// ApproveTemporaryAccess grants a pending temporary access request, its window starts now.
+func (a *App) ApproveTemporaryAccess(w http.ResponseWriter, r *http.Request) {
+	requestID, review, ok := decodeTemporaryAccessReview(w, r)
+	if !ok {
+		return
+	}
+
+	expiresAt, err := a.Grants.ApproveTemporaryAccess(requestID, middleware.AdminUsername(r.Context()), review.Message)
+	if writeTemporaryAccessError(w, requestID, err) {
+		return
+	}
+	pkg.SendJSONResponse(w, map[string]interface{}{
+		"message":   "Temporary access granted",
+		"requestID": requestID,
+		"expiresAt": expiresAt.Format(time.RFC3339),
+	}, http.StatusOK)
+}
+
+func (a *App) RejectTemporaryAccess(w http.ResponseWriter, r *http.Request) {
+	requestID, review, ok := decodeTemporaryAccessReview(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.Grants.RejectTemporaryAccess(requestID, middleware.AdminUsername(r.Context()), review.Message)
+	if writeTemporaryAccessError(w, requestID, err) {
+		return
+	}
+	pkg.SendSuccessResponse(w, "Temporary access request rejected")
+}
+
+func decodeTemporaryAccessReview(w http.ResponseWriter, r *http.Request) (int, models.AccessReview, bool) {
+	var review models.AccessReview
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		pkg.SendErrorResponse(w, "Invalid temporary access request id", http.StatusBadRequest)
+		return 0, review, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+		pkg.SendErrorResponse(w, "Failed to decode review", http.StatusBadRequest)
+		return 0, review, false
+	}
+	return requestID, review, true
+}
+
+// writeTemporaryAccessError answers a failed decision on a temporary access request, it reports whether there was one.
+func writeTemporaryAccessError(w http.ResponseWriter, requestID int, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, service.ErrTemporaryGrantNotFound):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrTemporaryRequestNotPending):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, pkg.ErrUnknownServer) || errors.Is(err, pkg.ErrServerDisabled) || errors.Is(err, pkg.ErrAmbiguousServer) ||
+		errors.Is(err, service.ErrTemporaryAccessEngine):
+		// the server or one of its replicas changed in the inventory since the request, a DBA has to fix it first
+		pkg.SendErrorResponse(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrTemporaryRequestSelfApproval):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrTemporaryGrantFailed):
+		pkg.SendErrorResponse(w, err.Error(), http.StatusBadGateway)
+	default:
+		log.Error().Err(err).Msgf("Failed to decide temporary access request %d", requestID)
+		pkg.SendErrorResponse(w, "Failed to review temporary access request", http.StatusInternalServerError)
+	}
+	return true
+}
+
+func (a *App) GetAllTemporaryGrants(w http.ResponseWriter, r *http.Request) {
+	grants, err := a.Grants.TemporaryGrants()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to query temporary grants")
+		pkg.SendErrorResponse2(w, "Failed to query temporary grants", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendJSONResponse(w, grants, http.StatusOK)
+}
+
+func (a *App) RevokeTemporaryAccess(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		pkg.SendErrorResponse(w, "Invalid temporary access request id", http.StatusBadRequest)
+		return
+	}
+
+	err = a.Grants.ExpireTemporaryAccess(requestID)
+	if errors.Is(err, service.ErrTemporaryGrantNotFound) {
+		pkg.SendErrorResponse(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to expire temporary access request %d", requestID)
+		pkg.SendErrorResponse(w, "Failed to revoke temporary access", http.StatusInternalServerError)
+		return
+	}
+	pkg.SendSuccessResponse(w, "Temporary access scheduled for revocation")
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-backend/internals/config"
+	"go-backend/internals/engine"
+	"go-backend/internals/pkg/catalogtest"
+	"go-backend/models"
+)
+
+// fakeGrants records the temporary access requests, the decisions are left to the embedded interface.
+type fakeGrants struct {
+	GrantStore
+	requests []models.TemporaryAccessRequest
+}
+
+func (g *fakeGrants) RequestTemporaryAccess(request models.TemporaryAccessRequest) (int, error) {
+	g.requests = append(g.requests, request)
+	return len(g.requests), nil
+}
+
+func TestRequestTemporaryAccessResolvesTheServer(t *testing.T) {
+	store := newFakeStore(
+		models.DatabaseServer{ID: 1, Name: "sales-db-01", Host: primaryHost, Port: 1433, Engine: engine.MSSQL, Enabled: true},
+		models.DatabaseServer{ID: 2, Name: "sales-db-old", Host: "10.0.0.99", Port: 1433, Engine: engine.MSSQL, Enabled: false},
+		models.DatabaseServer{ID: 3, Name: "billing-pg", Host: "10.0.1.5", Port: 5432, Engine: engine.Postgres, Enabled: true},
+	)
+	catalog := catalogtest.New()
+	for _, host := range []string{primaryHost, "10.0.0.99", "10.0.1.5", "10.9.9.9"} {
+		catalog.AddLogin("app_user", host, "owner@example.com")
+	}
+	grants := &fakeGrants{}
+	app := &App{
+		Config:  config.Default(),
+		Servers: store,
+		Grants:  grants,
+		Gateway: &fakeGateway{store: store, catalog: catalog},
+	}
+	request := func(server string) int {
+		body, _ := json.Marshal(models.TemporaryAccessRequest{Username: "app_user", Email: "owner@example.com", ServerIP: server,
+			Database: "sales", Role: "db_datareader", DurationHours: 2})
+		rec := httptest.NewRecorder()
+		app.RequestTemporaryAccess(rec, httptest.NewRequest(http.MethodPost, "/temporary-access", bytes.NewReader(body)))
+		return rec.Code
+	}
+
+	for _, server := range []string{"10.9.9.9", "sales-db-old", "billing-pg"} {
+		if code := request(server); code != http.StatusBadRequest {
+			t.Errorf("request on %s = %d, want 400", server, code)
+		}
+	}
+	if len(grants.requests) != 0 {
+		t.Fatalf("%d requests were recorded for servers that cannot be granted on", len(grants.requests))
+	}
+
+	if code := request("sales-db-01"); code != http.StatusAccepted {
+		t.Fatalf("request on sales-db-01 = %d, want 202", code)
+	}
+	if got := grants.requests[0].ServerIP; got != primaryHost {
+		t.Errorf("the request was recorded for %q, want the host of the inventory entry %s", got, primaryHost)
+	}
+}
@@ -0,0 +1,116 @@
+// Package loginadmin builds and runs the ALTER LOGIN statements used to change SQL Server passwords.
+// ALTER LOGIN does not accept parameters, so the login name and passwords have to be written into the
+// statement text. They are validated first and then quoted the same way QUOTENAME does.
+package loginadmin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxNameLength is the length of sysname and maxPasswordLength the longest password SQL Server accepts
+const (
+	maxNameLength     = 128
+	maxPasswordLength = 128
+)
+
+var (
+	ErrInvalidLoginName = errors.New("invalid login name")
+	ErrInvalidPassword  = errors.New("invalid password")
+	ErrInvalidOptions   = errors.New("CHECK_EXPIRATION cannot be enabled while CHECK_POLICY is disabled")
+)
+
+// Execer is satisfied by *sql.DB, *sql.Conn and *sql.Tx.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// PasswordOptions are the settings applied together with a new password.
+type PasswordOptions struct {
+	// OldPassword makes the server check the current password before it is replaced
+	OldPassword string
+	// CheckPolicy enforces the Windows password policy on the login
+	CheckPolicy bool
+	// CheckExpiration enforces password expiration, it requires CheckPolicy
+	CheckExpiration bool
+}
+
+// ValidateLoginName rejects names that are empty, longer than sysname, not valid UTF-8, contain control
+// characters or have leading or trailing spaces, which SQL Server would silently trim to another login.
+func ValidateLoginName(name string) error {
+	if name == "" || !utf8.ValidString(name) || utf8.RuneCountInString(name) > maxNameLength {
+		return ErrInvalidLoginName
+	}
+	if strings.TrimSpace(name) != name || strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return ErrInvalidLoginName
+	}
+	return nil
+}
+
+// ValidatePassword rejects passwords that are empty, too long, not valid UTF-8 or contain control characters.
+func ValidatePassword(password string) error {
+	if password == "" || !utf8.ValidString(password) || utf8.RuneCountInString(password) > maxPasswordLength {
+		return ErrInvalidPassword
+	}
+	if strings.IndexFunc(password, unicode.IsControl) >= 0 {
+		return ErrInvalidPassword
+	}
+	return nil
+}
+
+// QuoteName returns name as a bracketed identifier, doubling any closing bracket like QUOTENAME.
+func QuoteName(name string) string {
+	return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+}
+
+// QuoteLiteral returns s as a Unicode string literal, doubling any single quote.
+func QuoteLiteral(s string) string {
+	return "N'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// BuildSetPassword returns the statement that gives the login a new password and applies the policy options.
+// It is a single statement, so the login never keeps the new password without the options or the other way round.
+func BuildSetPassword(login, newPassword string, opts PasswordOptions) (string, error) {
+	if err := ValidateLoginName(login); err != nil {
+		return "", err
+	}
+	if err := ValidatePassword(newPassword); err != nil {
+		return "", err
+	}
+	if opts.OldPassword != "" {
+		if err := ValidatePassword(opts.OldPassword); err != nil {
+			return "", fmt.Errorf("old password: %w", err)
+		}
+	}
+	if opts.CheckExpiration && !opts.CheckPolicy {
+		return "", ErrInvalidOptions
+	}
+
+	statement := "ALTER LOGIN " + QuoteName(login) + " WITH PASSWORD = " + QuoteLiteral(newPassword)
+	if opts.OldPassword != "" {
+		statement += " OLD_PASSWORD = " + QuoteLiteral(opts.OldPassword)
+	}
+	return statement + ", CHECK_POLICY = " + onOff(opts.CheckPolicy) + ", CHECK_EXPIRATION = " + onOff(opts.CheckExpiration), nil
+}
+
+// SetPassword changes the password of the login on the server behind db.
+func SetPassword(ctx context.Context, db Execer, login, newPassword string, opts PasswordOptions) error {
+	statement, err := BuildSetPassword(login, newPassword, opts)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, statement)
+	return err
+}
+
+func onOff(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
@@ -0,0 +1,279 @@
+package loginadmin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var hostileNames = []string{
+	"app_user",
+	"DOMAIN\\svc-account",
+	"x]; DROP LOGIN sa; --",
+	"]]]",
+	"[",
+	"o'brien",
+	"x' OR '1'='1",
+	"user] WITH PASSWORD = N'owned'; --",
+	"Ünïcødé ログイン",
+}
+
+var hostilePasswords = []string{
+	"Secret123!",
+	"'",
+	"''",
+	"'; DROP TABLE dbo.users; --",
+	"abc' OLD_PASSWORD = 'x",
+	"N'; EXEC xp_cmdshell 'dir'; --",
+	"]; SHUTDOWN WITH NOWAIT; --",
+	"/* comment */ -- line",
+	"pässwörd ключ 密码",
+}
+
+// token is a piece of a statement: either a bare keyword or symbol, or the decoded value of a quoted name or literal
+type token struct {
+	kind  string
+	value string
+}
+
+// lex splits a statement the way SQL Server reads it, so that a value escaping its quotes shows up as extra tokens
+func lex(t *testing.T, statement string) []token {
+	t.Helper()
+	var tokens []token
+	for i := 0; i < len(statement); {
+		switch c := statement[i]; {
+		case c == ' ':
+			i++
+		case c == ',' || c == '=':
+			tokens = append(tokens, token{"word", string(c)})
+			i++
+		case c == '[':
+			value, end := readQuoted(t, statement, i+1, ']')
+			tokens = append(tokens, token{"name", value})
+			i = end
+		case c == 'N' && i+1 < len(statement) && statement[i+1] == '\'':
+			value, end := readQuoted(t, statement, i+2, '\'')
+			tokens = append(tokens, token{"literal", value})
+			i = end
+		default:
+			end := strings.IndexAny(statement[i:], " ,=")
+			if end < 0 {
+				end = len(statement) - i
+			}
+			word := statement[i : i+end]
+			if strings.ContainsAny(word, "'[];-/*") {
+				t.Fatalf("unexpected characters outside quotes %q in %q", word, statement)
+			}
+			tokens = append(tokens, token{"word", word})
+			i += end
+		}
+	}
+	return tokens
+}
+
+func readQuoted(t *testing.T, statement string, start int, closing byte) (string, int) {
+	t.Helper()
+	var value strings.Builder
+	for i := start; i < len(statement); i++ {
+		if statement[i] != closing {
+			value.WriteByte(statement[i])
+			continue
+		}
+		if i+1 < len(statement) && statement[i+1] == closing {
+			value.WriteByte(closing)
+			i++
+			continue
+		}
+		return value.String(), i + 1
+	}
+	t.Fatalf("unterminated quote in %q", statement)
+	return "", 0
+}
+
+func words(s string) []token {
+	var tokens []token
+	for _, w := range strings.Fields(s) {
+		tokens = append(tokens, token{"word", w})
+	}
+	return tokens
+}
+
+func expectTokens(t *testing.T, statement string, want []token) {
+	t.Helper()
+	got := lex(t, statement)
+	if len(got) != len(want) {
+		t.Fatalf("statement %q has tokens %v, want %v", statement, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("statement %q token %d is %v, want %v", statement, i, got[i], want[i])
+		}
+	}
+}
+
+func TestQuoteName(t *testing.T) {
+	cases := map[string]string{
+		"app_user": "[app_user]",
+		"a]b":      "[a]]b]",
+		"]":        "[]]]",
+		"[x]":      "[[x]]]",
+	}
+	for in, want := range cases {
+		if got := QuoteName(in); got != want {
+			t.Errorf("QuoteName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	cases := map[string]string{
+		"secret": "N'secret'",
+		"'":      "N''''",
+		"a'b''c": "N'a''b''''c'",
+	}
+	for in, want := range cases {
+		if got := QuoteLiteral(in); got != want {
+			t.Errorf("QuoteLiteral(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBuildSetPasswordHostileInputs(t *testing.T) {
+	for _, name := range hostileNames {
+		for _, password := range hostilePasswords {
+			statement, err := BuildSetPassword(name, password, PasswordOptions{})
+			if err != nil {
+				t.Fatalf("BuildSetPassword(%q, %q) failed: %v", name, password, err)
+			}
+			expectTokens(t, statement, append(append(append(append(words("ALTER LOGIN"), token{"name", name}),
+				words("WITH PASSWORD =")...), token{"literal", password}), words(", CHECK_POLICY = OFF , CHECK_EXPIRATION = OFF")...))
+		}
+	}
+}
+
+func TestBuildSetPasswordOldPassword(t *testing.T) {
+	for _, old := range hostilePasswords {
+		statement, err := BuildSetPassword("app_user", "New'Secret1", PasswordOptions{OldPassword: old, CheckPolicy: true, CheckExpiration: true})
+		if err != nil {
+			t.Fatalf("BuildSetPassword with old password %q failed: %v", old, err)
+		}
+		expectTokens(t, statement, append(append(append(append(append(words("ALTER LOGIN"), token{"name", "app_user"}),
+			words("WITH PASSWORD =")...), token{"literal", "New'Secret1"}), append(words("OLD_PASSWORD ="), token{"literal", old})...),
+			words(", CHECK_POLICY = ON , CHECK_EXPIRATION = ON")...))
+	}
+}
+
+func TestBuildSetPasswordOptions(t *testing.T) {
+	cases := []struct {
+		opts PasswordOptions
+		want string
+		err  error
+	}{
+		{PasswordOptions{}, "ALTER LOGIN [u] WITH PASSWORD = N'p', CHECK_POLICY = OFF, CHECK_EXPIRATION = OFF", nil},
+		{PasswordOptions{CheckPolicy: true}, "ALTER LOGIN [u] WITH PASSWORD = N'p', CHECK_POLICY = ON, CHECK_EXPIRATION = OFF", nil},
+		{PasswordOptions{CheckPolicy: true, CheckExpiration: true}, "ALTER LOGIN [u] WITH PASSWORD = N'p', CHECK_POLICY = ON, CHECK_EXPIRATION = ON", nil},
+		{PasswordOptions{CheckExpiration: true}, "", ErrInvalidOptions},
+	}
+	for _, c := range cases {
+		got, err := BuildSetPassword("u", "p", c.opts)
+		if !errors.Is(err, c.err) {
+			t.Fatalf("options %+v: error %v, want %v", c.opts, err, c.err)
+		}
+		if got != c.want {
+			t.Errorf("options %+v: got %q, want %q", c.opts, got, c.want)
+		}
+	}
+}
+
+func TestBuildSetPasswordRejectsInvalidInput(t *testing.T) {
+	cases := []struct {
+		name     string
+		login    string
+		password string
+		opts     PasswordOptions
+		err      error
+	}{
+		{"empty login", "", "p", PasswordOptions{}, ErrInvalidLoginName},
+		{"login too long", strings.Repeat("a", maxNameLength+1), "p", PasswordOptions{}, ErrInvalidLoginName},
+		{"login with NUL", "sa\x00x", "p", PasswordOptions{}, ErrInvalidLoginName},
+		{"login with newline", "x]\nGO\nDROP LOGIN sa", "p", PasswordOptions{}, ErrInvalidLoginName},
+		{"login with trailing space", "sa ", "p", PasswordOptions{}, ErrInvalidLoginName},
+		{"login with leading space", " sa", "p", PasswordOptions{}, ErrInvalidLoginName},
+		{"login not UTF-8", "sa\xff", "p", PasswordOptions{}, ErrInvalidLoginName},
+		{"empty password", "u", "", PasswordOptions{}, ErrInvalidPassword},
+		{"password too long", "u", strings.Repeat("p", maxPasswordLength+1), PasswordOptions{}, ErrInvalidPassword},
+		{"password with NUL", "u", "p\x00'; DROP LOGIN sa; --", PasswordOptions{}, ErrInvalidPassword},
+		{"password with newline", "u", "p\nGO", PasswordOptions{}, ErrInvalidPassword},
+		{"password not UTF-8", "u", "p\xc3", PasswordOptions{}, ErrInvalidPassword},
+		{"old password with NUL", "u", "p", PasswordOptions{OldPassword: "o\x00"}, ErrInvalidPassword},
+	}
+	for _, c := range cases {
+		statement, err := BuildSetPassword(c.login, c.password, c.opts)
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s: error %v, want %v", c.name, err, c.err)
+		}
+		if statement != "" {
+			t.Errorf("%s: returned statement %q", c.name, statement)
+		}
+	}
+}
+
+func TestBuildSetPasswordLengthLimits(t *testing.T) {
+	login := strings.Repeat("л", maxNameLength)
+	password := strings.Repeat("'", maxPasswordLength)
+	if _, err := BuildSetPassword(login, password, PasswordOptions{}); err != nil {
+		t.Fatalf("names and passwords of the maximum length must be accepted: %v", err)
+	}
+}
+
+type recordingExecer struct {
+	statements []string
+	args       [][]interface{}
+	failAt     int
+}
+
+func (r *recordingExecer) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.statements = append(r.statements, query)
+	r.args = append(r.args, args)
+	if len(r.statements) == r.failAt {
+		return nil, errors.New("exec failed")
+	}
+	return nil, nil
+}
+
+func TestSetPassword(t *testing.T) {
+	db := &recordingExecer{}
+	if err := SetPassword(context.Background(), db, "x]; DROP LOGIN sa; --", "'; --", PasswordOptions{CheckPolicy: true, CheckExpiration: true}); err != nil {
+		t.Fatalf("SetPassword failed: %v", err)
+	}
+	want := []string{
+		"ALTER LOGIN [x]]; DROP LOGIN sa; --] WITH PASSWORD = N'''; --', CHECK_POLICY = ON, CHECK_EXPIRATION = ON",
+	}
+	if strings.Join(db.statements, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("executed %q, want %q", db.statements, want)
+	}
+	for _, args := range db.args {
+		if len(args) != 0 {
+			t.Fatalf("statements must not be run with arguments, got %v", args)
+		}
+	}
+}
+
+func TestSetPasswordReturnsError(t *testing.T) {
+	db := &recordingExecer{failAt: 1}
+	if err := SetPassword(context.Background(), db, "u", "p", PasswordOptions{}); err == nil {
+		t.Fatal("SetPassword must return the error of a failed statement")
+	}
+}
+
+func TestSetPasswordDoesNotExecuteInvalidInput(t *testing.T) {
+	db := &recordingExecer{}
+	if err := SetPassword(context.Background(), db, "sa\x00", "p", PasswordOptions{}); !errors.Is(err, ErrInvalidLoginName) {
+		t.Fatalf("SetPassword error %v, want %v", err, ErrInvalidLoginName)
+	}
+	if len(db.statements) != 0 {
+		t.Fatalf("SetPassword executed %q for an invalid login", db.statements)
+	}
+}
@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"go-backend/internals/config"
+	"go-backend/internals/pkg"
+
+	"github.com/rs/zerolog/log"
+)
+
+type contextKey string
+
+const sessionKey contextKey = "adminSession"
+
+// routes an admin can still use while a password change is pending
+var passwordChangeRoutes = map[string]bool{
+	"/admin-change-password": true,
+	"/admin-logout":          true,
+	"/admin-session":         true,
+}
+
+// routes an admin can still use until a TOTP authenticator is enrolled
+var mfaEnrollmentRoutes = map[string]bool{
+	"/admin-mfa/enroll": true,
+	"/admin-mfa/verify": true,
+	"/admin-logout":     true,
+	"/admin-session":    true,
+}
+
+// SessionLookup finds the admin session of a session cookie.
+type SessionLookup interface {
+	LookupSession(cookieValue string) (pkg.Session, error)
+}
+
+// RequireAdminSession rejects requests that do not carry a valid admin session cookie
+// and makes the session available to the handler through SessionFromContext. cfg tells whether
+// admins have to enroll a TOTP authenticator and how the session cookie is cleared.
+func RequireAdminSession(sessions SessionLookup, cfg *config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cookie, err := r.Cookie(pkg.SessionCookieName)
+			if err != nil {
+				pkg.SendErrorResponse(w, "Admin login required", http.StatusUnauthorized)
+				return
+			}
+
+			session, err := sessions.LookupSession(cookie.Value)
+			if errors.Is(err, pkg.ErrInvalidSession) {
+				pkg.ClearSessionCookie(w, cfg.Session)
+				pkg.SendErrorResponse(w, "Session is invalid or has expired", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to look up admin session")
+				pkg.SendErrorResponse(w, "Failed to validate session", http.StatusInternalServerError)
+				return
+			}
+
+			if session.MustChangePassword && !passwordChangeRoutes[r.URL.Path] {
+				pkg.SendErrorResponse(w, "Password change required", http.StatusForbidden)
+				return
+			}
+			if !session.MustChangePassword && cfg.Admin.MFARequired && !session.MFAEnabled && !mfaEnrollmentRoutes[r.URL.Path] {
+				pkg.SendErrorResponse(w, "Two-factor authentication enrollment required", http.StatusForbidden)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), sessionKey, session)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func SessionFromContext(ctx context.Context) (pkg.Session, bool) {
+	session, ok := ctx.Value(sessionKey).(pkg.Session)
+	return session, ok
+}
+
+// AdminUsername returns the username of the logged in admin, or "" outside of RequireAdminSession.
+func AdminUsername(ctx context.Context) string {
+	session, _ := SessionFromContext(ctx)
+	return session.Username
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"go-backend/internals/pkg"
+
+	"github.com/rs/zerolog/log"
+)
+
+// largest request body that is fingerprinted, the password update requests are far smaller
+const maxIdempotentBody = 1 << 20
+
+// IdempotencyStore keeps the idempotency keys and the responses stored under them.
+type IdempotencyStore interface {
+	ClaimIdempotencyKey(scope, key, fingerprint string) (bool, pkg.IdempotentResult, error)
+	CompleteIdempotencyKey(scope, key string, statusCode int, body []byte, location string) error
+	ReleaseIdempotencyKey(scope, key string) error
+}
+
+// Idempotency makes a handler safe to retry. A request with an Idempotency-Key header runs once; repeating
+// the key with the same request replays the stored status code and body, repeating it while the first
+// request is still running is rejected with 409. Requests without the header are passed through unchanged.
+func Idempotency(keys IdempotencyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(pkg.IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > 255 {
+				pkg.SendErrorResponse(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody))
+			if err != nil {
+				pkg.SendErrorResponse(w, "Failed to read request", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			logger := log.Ctx(r.Context())
+			scope := r.Method + " " + r.URL.Path
+			run, result, err := keys.ClaimIdempotencyKey(scope, key, pkg.RequestFingerprint(r.Method, r.URL.Path, body))
+			if errors.Is(err, pkg.ErrIdempotencyKeyReused) {
+				pkg.SendErrorResponse(w, "Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+				return
+			}
+			if err != nil {
+				logger.Error().Err(err).Msg("Failed to claim idempotency key")
+				pkg.SendErrorResponse(w, "Failed to process request", http.StatusInternalServerError)
+				return
+			}
+
+			if !run {
+				if result.Status != pkg.IdempotencyCompleted {
+					logger.Info().Msgf("Request with idempotency key %q is still in progress", key)
+					pkg.SendErrorResponse(w, "A request with this Idempotency-Key is still in progress", http.StatusConflict)
+					return
+				}
+				logger.Info().Msgf("Replaying response for idempotency key %q", key)
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Idempotent-Replayed", "true")
+				if result.Location != "" {
+					w.Header().Set("Location", result.Location)
+				}
+				w.WriteHeader(result.StatusCode)
+				w.Write(result.Body)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			// server errors and lockouts are not stored so that the client can retry with the same key
+			if recorder.statusCode >= http.StatusInternalServerError || recorder.statusCode == http.StatusTooManyRequests {
+				if err := keys.ReleaseIdempotencyKey(scope, key); err != nil {
+					logger.Error().Err(err).Msg("Failed to release idempotency key")
+				}
+				return
+			}
+			if err := keys.CompleteIdempotencyKey(scope, key, recorder.statusCode, recorder.body.Bytes(), w.Header().Get("Location")); err != nil {
+				logger.Error().Err(err).Msg("Failed to store idempotent response")
+			}
+		})
+	}
+}
+
+// responseRecorder passes the response through and keeps a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(statusCode int) {
+	if !rec.wroteHeader {
+		rec.statusCode = statusCode
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rec *responseRecorder) Write(data []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(data)
+	return rec.ResponseWriter.Write(data)
+}
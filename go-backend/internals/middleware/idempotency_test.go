@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-backend/internals/pkg"
+)
+
+// memoryKeys is an IdempotencyStore in memory, shared by the instances of a test like the PostgreSQL table.
+type memoryKeys struct {
+	mu   sync.Mutex
+	keys map[string]memoryKey
+}
+
+type memoryKey struct {
+	fingerprint string
+	result      pkg.IdempotentResult
+}
+
+func (m *memoryKeys) ClaimIdempotencyKey(scope, key, fingerprint string) (bool, pkg.IdempotentResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, found := m.keys[scope+" "+key]
+	if !found {
+		m.keys[scope+" "+key] = memoryKey{fingerprint: fingerprint, result: pkg.IdempotentResult{Status: pkg.IdempotencyInProgress}}
+		return true, pkg.IdempotentResult{Status: pkg.IdempotencyInProgress}, nil
+	}
+	if stored.fingerprint != fingerprint {
+		return false, pkg.IdempotentResult{}, pkg.ErrIdempotencyKeyReused
+	}
+	return false, stored.result, nil
+}
+
+func (m *memoryKeys) CompleteIdempotencyKey(scope, key string, statusCode int, body []byte, location string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored := m.keys[scope+" "+key]
+	stored.result = pkg.IdempotentResult{Status: pkg.IdempotencyCompleted, StatusCode: statusCode, Body: body, Location: location}
+	m.keys[scope+" "+key] = stored
+	return nil
+}
+
+func (m *memoryKeys) ReleaseIdempotencyKey(scope, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.keys, scope+" "+key)
+	return nil
+}
+
+func TestIdempotencyReplaysOnAnotherInstance(t *testing.T) {
+	keys := &memoryKeys{keys: map[string]memoryKey{}}
+	runs := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		runs++
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"jobId":"1"}`))
+	})
+	// two instances, or one before and after a restart, share nothing but the store
+	first, second := Idempotency(keys)(handler), Idempotency(keys)(handler)
+
+	send := func(instance http.Handler, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/update-password", strings.NewReader(body))
+		r.Header.Set(pkg.IdempotencyKeyHeader, "retry-1")
+		w := httptest.NewRecorder()
+		instance.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := send(first, `{"username":"app"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("first request = %d, want 202", w.Code)
+	}
+	w := send(second, `{"username":"app"}`)
+	if w.Code != http.StatusAccepted || w.Header().Get("Idempotent-Replayed") != "true" || w.Body.String() != `{"jobId":"1"}` {
+		t.Errorf("retry on another instance = %d %q replayed=%q, want the stored response", w.Code, w.Body.String(), w.Header().Get("Idempotent-Replayed"))
+	}
+	if runs != 1 {
+		t.Errorf("the handler ran %d times, want once", runs)
+	}
+	if w := send(second, `{"username":"other"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("the key with another body = %d, want 422", w.Code)
+	}
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	fingerprint := pkg.RequestFingerprint(http.MethodPut, "/update-password", []byte(`{"username":"app"}`))
+	if again := pkg.RequestFingerprint(http.MethodPut, "/update-password", []byte(`{"username":"app"}`)); again != fingerprint {
+		t.Errorf("the fingerprint of the same request changed from %s to %s", fingerprint, again)
+	}
+	for _, other := range []string{
+		pkg.RequestFingerprint(http.MethodPost, "/update-password", []byte(`{"username":"app"}`)),
+		pkg.RequestFingerprint(http.MethodPut, "/temporary-access", []byte(`{"username":"app"}`)),
+		pkg.RequestFingerprint(http.MethodPut, "/update-password", []byte(`{"username":"other"}`)),
+	} {
+		if other == fingerprint {
+			t.Errorf("a different request has the fingerprint %s", fingerprint)
+		}
+	}
+}
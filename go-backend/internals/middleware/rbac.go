@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"net/http"
+
+	"go-backend/internals/pkg"
+
+	"github.com/rs/zerolog/log"
+)
+
+type Permission string
+
+const (
+	PermViewLogs       Permission = "view_logs"
+	PermManageGrants   Permission = "manage_grants"
+	PermApproveAccess  Permission = "approve_access"
+	PermManageWorkflow Permission = "manage_workflow"
+	PermManageAdmins   Permission = "manage_admins"
+	PermManageServers  Permission = "manage_servers"
+)
+
+const (
+	RoleViewer     = "viewer"
+	RoleOperator   = "operator"
+	RoleApprover   = "approver"
+	RoleSuperadmin = "superadmin"
+)
+
+// permission matrix, every role can read the audit logs
+var rolePermissions = map[string]map[Permission]bool{
+	RoleViewer: {
+		PermViewLogs: true,
+	},
+	RoleOperator: {
+		PermViewLogs:     true,
+		PermManageGrants: true,
+	},
+	RoleApprover: {
+		PermViewLogs:      true,
+		PermApproveAccess: true,
+	},
+	RoleSuperadmin: {
+		PermViewLogs:       true,
+		PermManageGrants:   true,
+		PermApproveAccess:  true,
+		PermManageWorkflow: true,
+		PermManageAdmins:   true,
+		PermManageServers:  true,
+	},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func HasPermission(role string, perm Permission) bool {
+	return rolePermissions[role][perm]
+}
+
+// Permissions lists what the role is allowed to do, in a stable order.
+func Permissions(role string) []Permission {
+	perms := []Permission{}
+	for _, perm := range []Permission{PermViewLogs, PermManageGrants, PermApproveAccess, PermManageWorkflow, PermManageAdmins, PermManageServers} {
+		if HasPermission(role, perm) {
+			perms = append(perms, perm)
+		}
+	}
+	return perms
+}
+
+// RequirePermission must run inside RequireAdminSession; it rejects admins whose role lacks perm.
+func RequirePermission(perm Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session, ok := SessionFromContext(r.Context())
+			if !ok {
+				pkg.SendErrorResponse(w, "Admin login required", http.StatusUnauthorized)
+				return
+			}
+			if !HasPermission(session.Role, perm) {
+				log.Info().Msgf("Admin %s with role %s denied %s on %s", session.Username, session.Role, perm, r.URL.Path)
+				pkg.SendErrorResponse(w, "You do not have permission to perform this action", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
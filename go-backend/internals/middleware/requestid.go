@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+
+	"go-backend/internals/pkg"
+
+	"github.com/rs/zerolog/log"
+)
+
+// IDs sent by a client or proxy are only echoed when they are short and plain, since they end up in logs
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request a correlation ID generated by the server, so a client cannot make its
+// requests share an ID with someone else's audit rows. The ID is returned in the X-Request-ID header,
+// added to every line logged through log.Ctx and available to handlers through pkg.RequestIDFromContext.
+// An X-Request-ID sent by a client or proxy is only echoed in X-Client-Request-ID and logged as
+// client_request_id, next to the correlation ID.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := pkg.NewRequestID()
+		w.Header().Set(pkg.RequestIDHeader, requestID)
+
+		logContext := log.With().Str("request_id", requestID)
+		if clientRequestID := r.Header.Get(pkg.RequestIDHeader); validRequestID.MatchString(clientRequestID) {
+			w.Header().Set(pkg.ClientRequestIDHeader, clientRequestID)
+			logContext = logContext.Str("client_request_id", clientRequestID)
+		}
+		logger := logContext.Logger()
+		ctx := logger.WithContext(pkg.WithRequestID(r.Context(), requestID))
+		logger.Debug().Msgf("%s %s", r.Method, r.URL.Path)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
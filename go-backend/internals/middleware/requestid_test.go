@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go-backend/internals/pkg"
+)
+
+func TestRequestIDIgnoresClientID(t *testing.T) {
+	var seen string
+	handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = pkg.RequestIDFromContext(r.Context())
+	}))
+
+	for _, clientID := range []string{"", "req-1", "not a valid id"} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if clientID != "" {
+			r.Header.Set(pkg.RequestIDHeader, clientID)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if seen == "" || seen == clientID {
+			t.Errorf("client ID %q: correlation ID = %q, want one generated by the server", clientID, seen)
+		}
+		if got := w.Header().Get(pkg.RequestIDHeader); got != seen {
+			t.Errorf("client ID %q: X-Request-ID = %q, want %q", clientID, got, seen)
+		}
+		wantEcho := clientID
+		if clientID == "not a valid id" {
+			wantEcho = ""
+		}
+		if got := w.Header().Get(pkg.ClientRequestIDHeader); got != wantEcho {
+			t.Errorf("client ID %q: X-Client-Request-ID = %q, want %q", clientID, got, wantEcho)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package migrate
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Dialect holds the statements on schema_migrations for one kind of database. The statements take
+// the version, name and checksum as ordinal parameters, in the placeholder syntax of the driver: $1 for
+// lib/pq, ? for go-mssqldb registered as mssql.
+type Dialect struct {
+	Name          string
+	CreateTable   string
+	Lock          string
+	SelectApplied string
+	Insert        string
+	Delete        string
+	// BatchSeparator splits a script into batches that are executed one by one, e.g. GO for SQL Server
+	// where CREATE PROCEDURE has to be the first statement of a batch
+	BatchSeparator *regexp.Regexp
+}
+
+var Postgres = Dialect{
+	Name: "postgres",
+	CreateTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	Lock:          "LOCK TABLE schema_migrations IN EXCLUSIVE MODE",
+	SelectApplied: "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version",
+	Insert:        "INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)",
+	Delete:        "DELETE FROM schema_migrations WHERE version = $1",
+}
+
+var SQLServer = Dialect{
+	Name: "mssql",
+	CreateTable: `IF OBJECT_ID(N'dbo.schema_migrations', N'U') IS NULL
+		CREATE TABLE dbo.schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name NVARCHAR(255) NOT NULL,
+			checksum CHAR(64) NOT NULL,
+			applied_at DATETIME2 NOT NULL DEFAULT SYSUTCDATETIME()
+		)`,
+	Lock:           "SELECT COUNT(*) FROM dbo.schema_migrations WITH (TABLOCKX, HOLDLOCK)",
+	SelectApplied:  "SELECT version, name, checksum, applied_at FROM dbo.schema_migrations ORDER BY version",
+	Insert:         "INSERT INTO dbo.schema_migrations (version, name, checksum) VALUES (?, ?, ?)",
+	Delete:         "DELETE FROM dbo.schema_migrations WHERE version = ?",
+	BatchSeparator: regexp.MustCompile(`(?im)^\s*GO\s*$`),
+}
+
+// batches splits a script on the batch separator, dropping empty batches.
+func (d Dialect) batches(script string) []string {
+	parts := []string{script}
+	if d.BatchSeparator != nil {
+		parts = d.BatchSeparator.Split(script, -1)
+	}
+	batches := []string{}
+	for _, part := range parts {
+		if strings.TrimSpace(part) != "" {
+			batches = append(batches, part)
+		}
+	}
+	return batches
+}
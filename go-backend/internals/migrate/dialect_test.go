@@ -0,0 +1,27 @@
+package migrate
+
+import (
+	"testing"
+	_ "unsafe"
+
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+// mssqlParseParams is the parser the mssql driver runs over every query to count its parameters.
+// database/sql rejects a call whose arguments do not match that count.
+//
+//go:linkname mssqlParseParams github.com/microsoft/go-mssqldb/internal/querytext.ParseParams
+func mssqlParseParams(query string) (string, int)
+
+func TestSQLServerParameters(t *testing.T) {
+	for query, want := range map[string]int{
+		SQLServer.Insert:        3,
+		SQLServer.Delete:        1,
+		SQLServer.SelectApplied: 0,
+		SQLServer.Lock:          0,
+	} {
+		if _, got := mssqlParseParams(query); got != want {
+			t.Errorf("the mssql driver counts %d parameters in %q, want %d", got, query, want)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package migrate applies versioned SQL migrations and records them in a schema_migrations table.
+// A migration is a pair of files named NNNN_name.up.sql and NNNN_name.down.sql, the down file is
+// optional. The checksum of every applied up file is stored, so a migration that was edited after it
+// ran is reported instead of silently diverging from the database.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration no longer matches its file.
+	ErrChecksumMismatch = errors.New("migration changed after it was applied")
+	// ErrUnknownMigration is returned when the database has a migration this build does not know about.
+	ErrUnknownMigration = errors.New("database has a migration that is not part of this build")
+	// ErrNoDownMigration is returned when a migration to roll back has no down file.
+	ErrNoDownMigration = errors.New("migration has no down file")
+)
+
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// Applied is a row of schema_migrations.
+type Applied struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Status states of a migration
+const (
+	StatusApplied = "applied"
+	StatusPending = "pending"
+	StatusChanged = "changed"
+	StatusUnknown = "unknown"
+)
+
+type Status struct {
+	Version   int
+	Name      string
+	State     string
+	AppliedAt time.Time
+}
+
+// Load reads the migrations of a directory in version order.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s: migration files are named NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version == 0 {
+			return nil, fmt.Errorf("%s: migration versions start at 1", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			m.Checksum = checksum(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Migrator applies a set of migrations to one database.
+type Migrator struct {
+	DB         *sql.DB
+	Dialect    Dialect
+	Migrations []Migration
+}
+
+func New(db *sql.DB, dialect Dialect, fsys fs.FS, dir string) (*Migrator, error) {
+	migrations, err := Load(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Dialect: dialect, Migrations: migrations}, nil
+}
+
+// Up applies every pending migration and returns the ones it applied. Nothing is applied while an
+// applied migration fails verification.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.inTx(ctx, func(tx *sql.Tx, applied map[int]Applied) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for _, migration := range m.Migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := m.exec(ctx, tx, migration.Up); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, m.Dialect.Insert, migration.Version, migration.Name, migration.Checksum); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// Down rolls back the applied migrations newer than target, newest first, and returns them.
+func (m *Migrator) Down(ctx context.Context, target int) ([]Migration, error) {
+	var undone []Migration
+	err := m.inTx(ctx, func(tx *sql.Tx, applied map[int]Applied) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for i := len(m.Migrations) - 1; i >= 0; i-- {
+			migration := m.Migrations[i]
+			if migration.Version <= target {
+				break
+			}
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, ErrNoDownMigration)
+			}
+			if err := m.exec(ctx, tx, migration.Down); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := tx.ExecContext(ctx, m.Dialect.Delete, migration.Version); err != nil {
+				return err
+			}
+			undone = append(undone, migration)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return undone, nil
+}
+
+// Status lists every known migration and every applied one this build does not know about.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	if _, err := m.DB.ExecContext(ctx, m.Dialect.CreateTable); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx, m.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []Status{}
+	for _, migration := range m.Migrations {
+		status := Status{Version: migration.Version, Name: migration.Name, State: StatusPending}
+		if row, ok := applied[migration.Version]; ok {
+			status.AppliedAt = row.AppliedAt
+			status.State = StatusApplied
+			if row.Checksum != migration.Checksum {
+				status.State = StatusChanged
+			}
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, State: StatusUnknown, AppliedAt: row.AppliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// verify checks that every applied migration is known and unchanged.
+func (m *Migrator) verify(applied map[int]Applied) error {
+	known := map[int]Migration{}
+	for _, migration := range m.Migrations {
+		known[migration.Version] = migration
+	}
+	versions := make([]int, 0, len(applied))
+	for version := range applied {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	for _, version := range versions {
+		row := applied[version]
+		migration, ok := known[version]
+		if !ok {
+			return fmt.Errorf("migration %d_%s: %w", row.Version, row.Name, ErrUnknownMigration)
+		}
+		if row.Checksum != migration.Checksum {
+			return fmt.Errorf("migration %d_%s: %w", row.Version, row.Name, ErrChecksumMismatch)
+		}
+	}
+	return nil
+}
+
+// inTx runs fn in one transaction that holds the lock on schema_migrations, so that backends
+// starting at the same time do not apply a migration twice.
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sql.Tx, applied map[int]Applied) error) error {
+	if _, err := m.DB.ExecContext(ctx, m.Dialect.CreateTable); err != nil {
+		return err
+	}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, m.Dialect.Lock); err != nil {
+		return err
+	}
+	applied, err := m.applied(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx, applied); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func (m *Migrator) applied(ctx context.Context, q queryer) (map[int]Applied, error) {
+	rows, err := q.QueryContext(ctx, m.Dialect.SelectApplied)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]Applied{}
+	for rows.Next() {
+		var row Applied
+		if err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[row.Version] = row
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) exec(ctx context.Context, tx *sql.Tx, script string) error {
+	for _, batch := range m.Dialect.batches(script) {
+		if _, err := tx.ExecContext(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package migrate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/0002_add_index.up.sql":  {Data: []byte("CREATE INDEX i ON t (c);")},
+		"m/0001_baseline.up.sql":   {Data: []byte("CREATE TABLE t (c INT);")},
+		"m/0001_baseline.down.sql": {Data: []byte("DROP TABLE t;")},
+		"m/0010_later.up.sql":      {Data: []byte("SELECT 1;")},
+		"m/0010_later.down.sql":    {Data: []byte("SELECT 0;")},
+	}
+
+	migrations, err := Load(fsys, "m")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var got []string
+	for _, m := range migrations {
+		got = append(got, m.Name)
+	}
+	if strings.Join(got, ",") != "baseline,add_index,later" {
+		t.Fatalf("order = %v, want by version", got)
+	}
+	if migrations[0].Down != "DROP TABLE t;" || migrations[1].Down != "" {
+		t.Errorf("down files = %q, %q", migrations[0].Down, migrations[1].Down)
+	}
+	if len(migrations[0].Checksum) != 64 || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("checksums = %s, %s", migrations[0].Checksum, migrations[1].Checksum)
+	}
+}
+
+func TestLoadChecksumIgnoresDownFile(t *testing.T) {
+	load := func(down string) Migration {
+		migrations, err := Load(fstest.MapFS{
+			"m/0001_baseline.up.sql":   {Data: []byte("CREATE TABLE t (c INT);")},
+			"m/0001_baseline.down.sql": {Data: []byte(down)},
+		}, "m")
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		return migrations[0]
+	}
+	if load("DROP TABLE t;").Checksum != load("DROP TABLE IF EXISTS t;").Checksum {
+		t.Error("editing a down file changed the checksum of the migration")
+	}
+}
+
+func TestLoadRejectsInvalidSets(t *testing.T) {
+	for name, fsys := range map[string]fstest.MapFS{
+		"bad name":     {"m/baseline.sql": {}},
+		"version zero": {"m/0000_baseline.up.sql": {Data: []byte("SELECT 1;")}},
+		"no up file":   {"m/0001_baseline.down.sql": {Data: []byte("SELECT 1;")}},
+		"two names": {
+			"m/0001_baseline.up.sql": {Data: []byte("SELECT 1;")},
+			"m/0001_other.down.sql":  {Data: []byte("SELECT 1;")},
+		},
+	} {
+		if _, err := Load(fsys, "m"); err == nil {
+			t.Errorf("%s: Load accepted the migrations", name)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := &Migrator{Migrations: []Migration{{Version: 1, Name: "baseline", Checksum: "a"}, {Version: 2, Name: "next", Checksum: "b"}}}
+
+	if err := m.verify(map[int]Applied{1: {Version: 1, Name: "baseline", Checksum: "a"}}); err != nil {
+		t.Errorf("verify of an unchanged migration: %v", err)
+	}
+	if err := m.verify(map[int]Applied{1: {Version: 1, Name: "baseline", Checksum: "x"}}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("verify of a changed migration = %v, want ErrChecksumMismatch", err)
+	}
+	if err := m.verify(map[int]Applied{3: {Version: 3, Name: "newer", Checksum: "c"}}); !errors.Is(err, ErrUnknownMigration) {
+		t.Errorf("verify of an unknown migration = %v, want ErrUnknownMigration", err)
+	}
+}
+
+func TestBatches(t *testing.T) {
+	script := "CREATE PROCEDURE a AS SELECT 1\nGO\n\ngo  \nCREATE PROCEDURE b AS SELECT 'GO'\n  GO\n"
+
+	if got := SQLServer.batches(script); len(got) != 2 || !strings.Contains(got[1], "'GO'") {
+		t.Errorf("SQL Server batches = %q", got)
+	}
+	if got := Postgres.batches(script); len(got) != 1 || got[0] != script {
+		t.Errorf("PostgreSQL batches = %q, want the script as it is", got)
+	}
+}
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-backend/internals/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	setupTokenTTL          = 24 * time.Hour
+	MinAdminPasswordLength = 12
+)
+
+var (
+	ErrInvalidSetupToken = errors.New("invalid or expired setup token")
+	ErrAdminsExist       = errors.New("an active superadmin already exists")
+	ErrWeakAdminPassword = fmt.Errorf("admin passwords must be at least %d characters long", MinAdminPasswordLength)
+)
+
+// activeSuperadminExists is the condition for bootstrapping. Disabled admins and other roles do not count,
+// e.g. the seeded admin of postgres_init.sql that the migrations disable.
+const activeSuperadminExists = "SELECT EXISTS (SELECT 1 FROM admin WHERE role = 'superadmin' AND NOT disabled)"
+
+// BootstrapAdmin gives an installation without an active superadmin a way to get one.
+// The bootstrap username and password hash (a crypt() bcrypt hash) of c create it directly, or take over
+// the admin of that name, otherwise a one-time setup token is logged that can be exchanged through
+// POST /admin-setup.
+func BootstrapAdmin(db *sql.DB, c config.AdminConfig) error {
+	var exists bool
+	if err := db.QueryRow(activeSuperadminExists).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	username := c.BootstrapUsername
+	passwordHash := c.BootstrapPasswordHash
+	if username != "" && passwordHash != "" {
+		if !strings.HasPrefix(passwordHash, "$2a$") {
+			return errors.New("ADMIN_BOOTSTRAP_PASSWORD_HASH must be a bcrypt hash as produced by crypt(password, gen_salt('bf'))")
+		}
+		return bootstrapConfiguredAdmin(db, username, passwordHash)
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	_, err := db.Exec("INSERT INTO admin_setup_tokens (token_hash, created_at, expires_at) VALUES ($1, CURRENT_TIMESTAMP, $2)",
+		hashToken(token), time.Now().Add(setupTokenTTL))
+	if err != nil {
+		return err
+	}
+	log.Warn().Msgf("No active superadmin exists. Create one with POST /admin-setup using the setup token %s, valid for %s", token, setupTokenTTL)
+	return nil
+}
+
+// bootstrapConfiguredAdmin creates the superadmin of the configuration. An admin of the same name, such as
+// the seeded admin the migrations disabled, is enabled and promoted with the configured hash instead, since
+// the configuration is the way back in once no superadmin is left.
+func bootstrapConfiguredAdmin(db *sql.DB, username, passwordHash string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// serialize instances that start together
+	if _, err = tx.Exec("LOCK TABLE admin IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+	var exists bool
+	if err = tx.QueryRow(activeSuperadminExists).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	res, err := tx.Exec(`UPDATE admin SET password_hash = $2, role = 'superadmin', disabled = FALSE,
+		must_change_password = FALSE, password_last_updated = CURRENT_TIMESTAMP
+		WHERE username = $1`, username, passwordHash)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		// sessions signed in with the earlier password must not come back with the account
+		if _, err = tx.Exec("UPDATE admin_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE username = $1 AND revoked_at IS NULL", username); err != nil {
+			return err
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+		log.Warn().Msgf("Bootstrap admin %s already existed, it was enabled as a superadmin with the configured password", username)
+		return nil
+	}
+	if _, err = tx.Exec("SELECT insert_admin_with_hash($1, $2, $3)", username, passwordHash, "superadmin"); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	log.Info().Msgf("Bootstrap admin %s created from the configuration", username)
+	return nil
+}
+
+// CreateFirstAdmin exchanges a setup token for a superadmin account while no active superadmin exists.
+func CreateFirstAdmin(db *sql.DB, token, username, password string) error {
+	if err := ValidateAdminPassword(password); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// serialize concurrent setup attempts
+	if _, err = tx.Exec("LOCK TABLE admin IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+	var exists bool
+	if err = tx.QueryRow(activeSuperadminExists).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrAdminsExist
+	}
+
+	res, err := tx.Exec(`UPDATE admin_setup_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP`, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidSetupToken
+	}
+	if _, err = tx.Exec("SELECT insert_into_admin($1, $2, $3)", username, password, "superadmin"); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func ValidateAdminPassword(password string) error {
+	if len(password) < MinAdminPasswordLength {
+		return ErrWeakAdminPassword
+	}
+	return nil
+}
@@ -1,20 +1,35 @@
 package pkg
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
-	"os"
 
 	"github.com/rs/zerolog/log"
-
 )
-func Check_user_credentials(msdb *sql.DB, username, serverIP, emailID string) (bool, error) {
+
+// LoginCatalog answers what the central SQL Server knows about logins: who owns them, which are registered
+// for self service, whether they have expired and which servers share them through availability groups.
+type LoginCatalog interface {
+	ValidateUserCredentials(ctx context.Context, username, serverIP, email string) (bool, error)
+	ValidateAccessRequest(ctx context.Context, username string) (bool, error)
+	// FindRelatedServers returns the other members of the availability groups of the server, without the server itself
+	FindRelatedServers(ctx context.Context, serverIP string) ([]string, error)
+	// CheckLoginExpiration reports whether the login exists on the instance and is neither expired nor invalid
+	CheckLoginExpiration(ctx context.Context, loginName, sqlInstance string) (bool, error)
+}
+
+// SQLServerCatalog is the LoginCatalog of the central SQL Server, read through the procedures of the MSSQL migrations.
+type SQLServerCatalog struct {
+	DB *sql.DB
+}
+
+func (c SQLServerCatalog) ValidateUserCredentials(ctx context.Context, username, serverIP, emailID string) (bool, error) {
 	var isValidUser bool
 	query := `DECLARE @IsValid BIT;
               EXEC dbo.ValidateUserCredentials @Username = ?, @ServerIP = ?, @Email = ?, @IsValid = @IsValid OUTPUT;
               SELECT @IsValid;`
 
-	row := msdb.QueryRow(query, username, serverIP, emailID)
+	row := c.DB.QueryRowContext(ctx, query, username, serverIP, emailID)
 	if err := row.Scan(&isValidUser); err != nil {
 		return false, err
 	}
@@ -22,33 +37,25 @@ func Check_user_credentials(msdb *sql.DB, username, serverIP, emailID string) (b
 
 	return isValidUser, nil
 }
-func CheckOldPassword(msdb *sql.DB, username, serverIP, oldPassword, database string) (bool, error) {
-	msWithUserCredstr := fmt.Sprintf("server=%s;user id=%s;password=%s;port=%s;database=%s",
-		serverIP,
-		username,
-		oldPassword,
-		os.Getenv("MS_DB_PORT"),
-		database)
-
-	msWithUserCred, err := sql.Open("mssql", msWithUserCredstr)
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to connect to MS SQL Server with user credentials")
-		return false, err
-	}
-	defer msWithUserCred.Close()
 
-	if err = msWithUserCred.Ping(); err != nil {
-		log.Error().Err(err).Msgf("Failed to ping MS SQL Server: %v", err)
+func (c SQLServerCatalog) ValidateAccessRequest(ctx context.Context, username string) (bool, error) {
+	var isValidUser bool
+	query := `DECLARE @IsValid BIT;
+              EXEC dbo.ValidateAccessRequest @Username = ?, @IsValid = @IsValid OUTPUT;
+              SELECT @IsValid;`
+
+	row := c.DB.QueryRowContext(ctx, query, username)
+	if err := row.Scan(&isValidUser); err != nil {
 		return false, err
 	}
+	log.Info().Msg("Access request login " + username + " validated")
 
-	log.Info().Msg("Old Password is valid")
-	return true, nil
+	return isValidUser, nil
 }
 
-func FindRelatedServers(msdb *sql.DB, serverIP string) ([]string, error) {
+func (c SQLServerCatalog) FindRelatedServers(ctx context.Context, serverIP string) ([]string, error) {
 	query := "EXEC FindRelatedServers @ServerIP=?"
-	rows, err := msdb.Query(query, serverIP)
+	rows, err := c.DB.QueryContext(ctx, query, serverIP)
 	if err != nil {
 		return nil, err
 	}
@@ -69,7 +76,8 @@ func FindRelatedServers(msdb *sql.DB, serverIP string) ([]string, error) {
 
 	return serverReplicas, nil
 }
-func CheckLoginExpiration(msdb *sql.DB, loginName, sqlInstance string) (bool, error) {
+
+func (c SQLServerCatalog) CheckLoginExpiration(ctx context.Context, loginName, sqlInstance string) (bool, error) {
 	var isExpired sql.NullBool
 	var isValid sql.NullBool
 
@@ -78,7 +86,7 @@ func CheckLoginExpiration(msdb *sql.DB, loginName, sqlInstance string) (bool, er
 		EXEC dbo.CheckLoginExpiration @LoginName = ?, @SqlInstance = ?, @IsExpired = @IsExpired OUTPUT, @IsValid = @IsValid OUTPUT;
 		SELECT @IsExpired, @IsValid;
 	`
-	err := msdb.QueryRow(query, loginName, sqlInstance).Scan(&isExpired, &isValid)
+	err := c.DB.QueryRowContext(ctx, query, loginName, sqlInstance).Scan(&isExpired, &isValid)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			log.Error().Err(err).Msg("Login does not exist")
@@ -98,4 +106,4 @@ func CheckLoginExpiration(msdb *sql.DB, loginName, sqlInstance string) (bool, er
 	}
 	log.Info().Msg("Login is still valid")
 	return true, nil
-}
\ No newline at end of file
+}
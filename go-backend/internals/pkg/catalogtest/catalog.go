@@ -0,0 +1,100 @@
+// Package catalogtest provides an in-memory pkg.LoginCatalog for tests that cannot reach SQL Server.
+package catalogtest
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Catalog holds logins, their owners and the availability groups the way the procedures of the central
+// SQL Server report them. Names and hosts are compared without case, like SQL Server does.
+type Catalog struct {
+	mu         sync.Mutex
+	owners     map[string]string
+	expired    map[string]bool
+	registered map[string]bool
+	replicas   map[string][]string
+	// Err, if set, is returned by every call, as if the catalog could not be reached
+	Err error
+}
+
+func New() *Catalog {
+	return &Catalog{
+		owners:     map[string]string{},
+		expired:    map[string]bool{},
+		registered: map[string]bool{},
+		replicas:   map[string][]string{},
+	}
+}
+
+func loginKey(username, server string) string {
+	return strings.ToLower(username) + "@" + strings.ToLower(server)
+}
+
+// AddLogin adds a valid login on the server owned by email.
+func (c *Catalog) AddLogin(username, server, email string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.owners[loginKey(username, server)] = email
+}
+
+// Expire marks the login on the server as expired.
+func (c *Catalog) Expire(username, server string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expired[loginKey(username, server)] = true
+}
+
+// Register makes the login eligible for access requests.
+func (c *Catalog) Register(username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registered[strings.ToLower(username)] = true
+}
+
+// SetReplicas puts the server and the replicas in one availability group.
+func (c *Catalog) SetReplicas(server string, replicas ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.replicas[strings.ToLower(server)] = replicas
+}
+
+func (c *Catalog) ValidateUserCredentials(ctx context.Context, username, serverIP, email string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return false, c.Err
+	}
+	owner, ok := c.owners[loginKey(username, serverIP)]
+	return ok && strings.EqualFold(owner, email), nil
+}
+
+func (c *Catalog) ValidateAccessRequest(ctx context.Context, username string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return false, c.Err
+	}
+	return c.registered[strings.ToLower(username)], nil
+}
+
+func (c *Catalog) FindRelatedServers(ctx context.Context, serverIP string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return append([]string(nil), c.replicas[strings.ToLower(serverIP)]...), nil
+}
+
+func (c *Catalog) CheckLoginExpiration(ctx context.Context, loginName, sqlInstance string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Err != nil {
+		return false, c.Err
+	}
+	key := loginKey(loginName, sqlInstance)
+	_, exists := c.owners[key]
+	return exists && !c.expired[key], nil
+}
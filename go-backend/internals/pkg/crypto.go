@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+
+	"go-backend/internals/config"
+)
+
+// ErrNoPayloadKey means jobs.encryption_key is not set, config.Validate refuses to start without it.
+var ErrNoPayloadKey = errors.New("jobs.encryption_key is not configured")
+
+// key used to encrypt secrets that are stored in PostgreSQL for a short while, such as the passwords of a
+// queued password update. The job encryption key holds 32 base64 encoded bytes; there is no fallback,
+// since a derived or random key would leave jobs queued by other instances unreadable.
+func getPayloadKey(c config.JobsConfig) ([]byte, error) {
+	if c.EncryptionKey == "" {
+		return nil, ErrNoPayloadKey
+	}
+	key, err := base64.StdEncoding.DecodeString(c.EncryptionKey)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("the job encryption key must be 32 base64 encoded bytes")
+	}
+	return key, nil
+}
+
+// EncryptPayload seals the plaintext with AES-GCM under the job encryption key, the nonce is prepended
+// to the result.
+func EncryptPayload(c config.JobsConfig, plaintext []byte) ([]byte, error) {
+	gcm, err := payloadCipher(c)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func DecryptPayload(c config.JobsConfig, ciphertext []byte) ([]byte, error) {
+	gcm, err := payloadCipher(c)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errors.New("encrypted payload is too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
+
+func payloadCipher(c config.JobsConfig) (cipher.AEAD, error) {
+	key, err := getPayloadKey(c)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -3,16 +3,23 @@ package pkg
 import (
 	"fmt"
 	"net/smtp"
-	"os"
+	"strconv"
+
+	"go-backend/internals/config"
 
 	"github.com/rs/zerolog/log"
 )
 
-func SendConfirmationEmail(to, username string) error {
-	from := os.Getenv("SMTP_FROM")
-	password := os.Getenv("SMTP_PASSWORD")
-	smtpServer := os.Getenv("SMTP_SERVER")
-	smtpPort := os.Getenv("SMTP_PORT")
+// SMTPMailer sends the emails of the backend through the configured SMTP server.
+type SMTPMailer struct {
+	Config config.SMTPConfig
+}
+
+func (m SMTPMailer) SendConfirmation(to, username string) error {
+	from := m.Config.From
+	password := m.Config.Password
+	smtpServer := m.Config.Server
+	smtpPort := strconv.Itoa(m.Config.Port)
 
 	auth := smtp.PlainAuth("", from, password, smtpServer)
 
@@ -28,4 +35,4 @@ func SendConfirmationEmail(to, username string) error {
 
 	log.Info().Msg("Email sent successfully")
 	return nil
-}
\ No newline at end of file
+}
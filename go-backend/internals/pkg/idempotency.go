@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"go-backend/internals/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+const (
+	IdempotencyInProgress = "In Progress"
+	IdempotencyCompleted  = "Completed"
+)
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+// IdempotentResult is what was stored for an Idempotency-Key.
+type IdempotentResult struct {
+	Status     string
+	StatusCode int
+	Body       []byte
+	Location   string
+}
+
+// idempotencyPurgeInterval is how often expired keys are deleted
+const idempotencyPurgeInterval = 10 * time.Minute
+
+// RequestFingerprint identifies a request body without storing it. It depends on nothing but the request,
+// so a retry after a restart or against another instance matches the key it was first sent with.
+func RequestFingerprint(method, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ClaimIdempotencyKey reserves the key for the request. It returns true when the caller has to run the
+// request, otherwise the stored result, which is still in progress if a duplicate is running right now.
+// The reservation only lasts for the in progress lease of c, so a key whose request never completed, e.g.
+// because its instance crashed, can be retried after that instead of after the full TTL.
+func ClaimIdempotencyKey(db *sql.DB, c config.IdempotencyConfig, scope, key, fingerprint string) (bool, IdempotentResult, error) {
+	// an expired key may be used again
+	_, err := db.Exec("DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2 AND expires_at <= CURRENT_TIMESTAMP", scope, key)
+	if err != nil {
+		return false, IdempotentResult{}, err
+	}
+
+	res, err := db.Exec(`INSERT INTO idempotency_keys (scope, idempotency_key, fingerprint, key_status, expires_at)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT (scope, idempotency_key) DO NOTHING`,
+		scope, key, fingerprint, IdempotencyInProgress, time.Now().Add(c.InProgressTTL))
+	if err != nil {
+		return false, IdempotentResult{}, err
+	}
+	if inserted, _ := res.RowsAffected(); inserted == 1 {
+		return true, IdempotentResult{Status: IdempotencyInProgress}, nil
+	}
+
+	var result IdempotentResult
+	var storedFingerprint string
+	var statusCode sql.NullInt64
+	var location sql.NullString
+	err = db.QueryRow(`SELECT fingerprint, key_status, status_code, response_body, location FROM idempotency_keys
+		WHERE scope = $1 AND idempotency_key = $2`, scope, key).
+		Scan(&storedFingerprint, &result.Status, &statusCode, &result.Body, &location)
+	if err == sql.ErrNoRows {
+		// the key expired or was released in between, let the client try again
+		return false, IdempotentResult{Status: IdempotencyInProgress}, nil
+	}
+	if err != nil {
+		return false, IdempotentResult{}, err
+	}
+	if !hmac.Equal([]byte(storedFingerprint), []byte(fingerprint)) {
+		return false, IdempotentResult{}, ErrIdempotencyKeyReused
+	}
+	result.StatusCode = int(statusCode.Int64)
+	result.Location = location.String
+	return false, result, nil
+}
+
+// CompleteIdempotencyKey stores the response so that repeated requests with the key get it replayed
+// for the key TTL of c.
+func CompleteIdempotencyKey(db *sql.DB, c config.IdempotencyConfig, scope, key string, statusCode int, body []byte, location string) error {
+	_, err := db.Exec(`UPDATE idempotency_keys SET key_status = $3, status_code = $4, response_body = $5, location = NULLIF($6, ''),
+		expires_at = $7
+		WHERE scope = $1 AND idempotency_key = $2`, scope, key, IdempotencyCompleted, statusCode, body, location, time.Now().Add(c.KeyTTL))
+	return err
+}
+
+// ReleaseIdempotencyKey forgets the key, e.g. after a server error the request may simply be retried.
+func ReleaseIdempotencyKey(db *sql.DB, scope, key string) error {
+	_, err := db.Exec("DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2", scope, key)
+	return err
+}
+
+// PurgeExpiredIdempotencyKeys deletes every expired key with its stored response, which may hold
+// personal data, and returns how many there were.
+func PurgeExpiredIdempotencyKeys(db *sql.DB) (int64, error) {
+	res, err := db.Exec("DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// StartIdempotencyPurger purges expired idempotency keys until ctx is cancelled.
+func StartIdempotencyPurger(ctx context.Context, db *sql.DB) {
+	go func() {
+		ticker := time.NewTicker(idempotencyPurgeInterval)
+		defer ticker.Stop()
+		for {
+			if purged, err := PurgeExpiredIdempotencyKeys(db); err != nil {
+				log.Error().Err(err).Msg("Failed to purge expired idempotency keys")
+			} else if purged > 0 {
+				log.Info().Msgf("Purged %d expired idempotency keys", purged)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
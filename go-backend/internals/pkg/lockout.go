@@ -0,0 +1,194 @@
+package pkg
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/internals/config"
+	"go-backend/internals/events"
+
+	mssql "github.com/microsoft/go-mssqldb"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	ScopeAdminLogin     = "admin-login"
+	ScopePasswordUpdate = "password-update"
+)
+
+// PasswordLockoutKey is the username password updates are locked under. SQL Server logins are per instance,
+// so failures are counted per login and inventory entry, whichever name or host the user entered.
+func PasswordLockoutKey(username string, serverID int) string {
+	return username + "@server:" + strconv.Itoa(serverID)
+}
+
+// lockout policy: a username is locked after 5 failures and a client IP after 20,
+// starting at one minute and doubling up to an hour. Failures older than a day are forgotten.
+const (
+	usernameFailureThreshold = 5
+	clientIPFailureThreshold = 20
+	lockoutBaseSeconds       = 60
+	lockoutMaxSeconds        = 60 * 60
+	failureResetSeconds      = 24 * 60 * 60
+)
+
+// sqlLoginFailed is the SQL Server error number for a failed login
+const sqlLoginFailed = 18456
+
+// ClientIP returns the address of the caller. X-Forwarded-For is only trusted when
+// server.trust_proxy_headers is set, since anyone can set it otherwise.
+func ClientIP(r *http.Request, c config.ServerConfig) string {
+	if c.TrustProxyHeaders {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// CheckLockout returns how long the username or the client IP is still locked out for, zero if neither is.
+func CheckLockout(db *sql.DB, scope, username, clientIP string) (time.Duration, error) {
+	var remaining time.Duration
+	for keyType, key := range map[string]string{"username": username, "ip": clientIP} {
+		var lockedUntil sql.NullTime
+		if err := db.QueryRow("SELECT check_login_lockout($1, $2, $3)", scope, keyType, key).Scan(&lockedUntil); err != nil {
+			return 0, err
+		}
+		if lockedUntil.Valid {
+			if left := time.Until(lockedUntil.Time); left > remaining {
+				remaining = left
+			}
+		}
+	}
+	return remaining, nil
+}
+
+// RecordLoginFailure counts a failed attempt against the username and the client IP and writes
+// an audit row whenever either of them gets locked.
+func RecordLoginFailure(db *sql.DB, broker *events.Broker, requestID, scope, username, clientIP, serverIP string) {
+	for _, key := range []struct {
+		keyType   string
+		key       string
+		threshold int
+	}{
+		{"username", username, usernameFailureThreshold},
+		{"ip", clientIP, clientIPFailureThreshold},
+	} {
+		var lockedUntil sql.NullTime
+		err := db.QueryRow("SELECT record_login_failure($1, $2, $3, $4, $5, $6, $7)",
+			scope, key.keyType, key.key, key.threshold, lockoutBaseSeconds, lockoutMaxSeconds, failureResetSeconds).Scan(&lockedUntil)
+		if err != nil {
+			log.Error().Err(err).Str("request_id", requestID).Msgf("Failed to record login failure for %s %s", key.keyType, key.key)
+			continue
+		}
+		if lockedUntil.Valid {
+			message := fmt.Sprintf("%s %s locked out of %s until %s after repeated failures (client IP %s)",
+				key.keyType, key.key, scope, lockedUntil.Time.Format(time.RFC3339), clientIP)
+			log.Warn().Str("request_id", requestID).Msg(message)
+			LogPasswordUpdate(db, broker, requestID, username, serverIP, "Lockout", "Locked", message)
+		}
+	}
+}
+
+// lockoutKey is one of the keys an attempt is counted against
+type lockoutKey struct {
+	keyType   string
+	key       string
+	threshold int
+}
+
+func lockoutKeys(username, clientIP string) []lockoutKey {
+	return []lockoutKey{
+		{"username", username, usernameFailureThreshold},
+		{"ip", clientIP, clientIPFailureThreshold},
+	}
+}
+
+// ClaimLoginAttempt checks the lockout of the username and the client IP and counts the attempt as a failure
+// against both in the same statement, so concurrent attempts cannot all pass the check. It returns how long
+// the attempt is locked out for, zero if it may go ahead. A successful attempt gives its claim back with
+// ReleaseLoginAttempt, a failed one keeps it counted.
+func ClaimLoginAttempt(db *sql.DB, broker *events.Broker, requestID, scope, username, clientIP, serverIP string) (time.Duration, error) {
+	var claimed []lockoutKey
+	for _, key := range lockoutKeys(username, clientIP) {
+		var lockedUntil sql.NullTime
+		var locksKey bool
+		err := db.QueryRow("SELECT locked_until, locks_key FROM claim_login_attempt($1, $2, $3, $4, $5, $6, $7)",
+			scope, key.keyType, key.key, key.threshold, lockoutBaseSeconds, lockoutMaxSeconds, failureResetSeconds).Scan(&lockedUntil, &locksKey)
+		if err == nil && lockedUntil.Valid {
+			// the attempt does not go ahead, so the keys claimed before are not counted either
+			releaseLoginAttempt(db, scope, claimed)
+			return time.Until(lockedUntil.Time), nil
+		}
+		if err != nil {
+			releaseLoginAttempt(db, scope, claimed)
+			return 0, err
+		}
+		claimed = append(claimed, key)
+		if locksKey {
+			message := fmt.Sprintf("%s %s locked out of %s after repeated failures (client IP %s)", key.keyType, key.key, scope, clientIP)
+			log.Warn().Str("request_id", requestID).Msg(message)
+			LogPasswordUpdate(db, broker, requestID, username, serverIP, "Lockout", "Locked", message)
+		}
+	}
+	return 0, nil
+}
+
+// ReleaseLoginAttempt gives back the claim of a successful attempt. The username is cleared like with
+// ClearLoginFailures, an empty one is left counted. The client IP only loses the failure of this attempt.
+func ReleaseLoginAttempt(db *sql.DB, scope, username, clientIP string) {
+	if username != "" {
+		ClearLoginFailures(db, scope, username)
+	}
+	releaseLoginAttempt(db, scope, []lockoutKey{{"ip", clientIP, clientIPFailureThreshold}})
+}
+
+func releaseLoginAttempt(db *sql.DB, scope string, keys []lockoutKey) {
+	for _, key := range keys {
+		if _, err := db.Exec("CALL release_login_attempt($1, $2, $3, $4)", scope, key.keyType, key.key, key.threshold); err != nil {
+			log.Error().Err(err).Msgf("Failed to release login attempt for %s %s", key.keyType, key.key)
+		}
+	}
+}
+
+// ClearLoginFailures forgets the failures of the username after a successful login. The client IP
+// keeps its count so that one valid login cannot be used to keep guessing other accounts.
+func ClearLoginFailures(db *sql.DB, scope, username string) {
+	if _, err := db.Exec("CALL clear_login_failures($1, $2, $3)", scope, "username", username); err != nil {
+		log.Error().Err(err).Msgf("Failed to clear login failures for %s", username)
+	}
+}
+
+// SendLockoutResponse answers with 429 and a Retry-After header.
+func SendLockoutResponse(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := int(retryAfter.Round(time.Second).Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	SendErrorResponse(w, fmt.Sprintf("Too many failed attempts, try again in %s", (time.Duration(seconds)*time.Second).String()), http.StatusTooManyRequests)
+}
+
+// IsLoginFailed reports whether SQL Server rejected the credentials of a login.
+func IsLoginFailed(err error) bool {
+	var sqlErr mssql.Error
+	if errors.As(err, &sqlErr) {
+		return sqlErr.Number == sqlLoginFailed
+	}
+	var sqlErrPtr *mssql.Error
+	if errors.As(err, &sqlErrPtr) {
+		return sqlErrPtr.Number == sqlLoginFailed
+	}
+	return false
+}
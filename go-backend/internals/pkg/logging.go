@@ -2,20 +2,51 @@ package pkg
 
 import (
 	"database/sql"
+	"time"
+
+	"go-backend/internals/events"
+
 	"github.com/rs/zerolog/log"
 )
 
-func LogPasswordUpdate(db *sql.DB, username, serverIP, requestType, requestStatus, message string) {
-	_, err := db.Exec("CALL log_updates($1, $2, $3, $4, $5)", username, serverIP, requestType, requestStatus, message)
+// LogPasswordUpdate writes a new audit row and publishes it to the admin event streams through broker.
+// requestID is the correlation ID of the HTTP request that caused it, or "" for background work.
+func LogPasswordUpdate(db *sql.DB, broker *events.Broker, requestID, username, serverIP, requestType, requestStatus, message string) {
+	_, err := db.Exec("CALL log_updates($1, $2, $3, $4, $5, $6)", username, serverIP, requestType, requestStatus, message, requestID)
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed to log password update: %v ", err)
-	}	
+		log.Error().Err(err).Str("request_id", requestID).Msgf("Failed to log password update: %v ", err)
+		return
+	}
+	broker.Publish(events.Event{Topic: events.TopicLogs, Type: "log", Data: map[string]string{
+		"correlationID": requestID,
+		"username":      username,
+		"serverIP":      serverIP,
+		"requestType":   requestType,
+		"requestStatus": requestStatus,
+		"message":       message,
+	}})
 }
-func LogStatus(db *sql.DB, username, serverIP, requestType, requestStatus, message string) {
-	_, err := db.Exec("CALL update_pass_reset_logs($1, $2, $3, $4, $5)", username, serverIP, requestType, requestStatus, message)
+
+// LogStatus updates the audit row written by LogPasswordUpdate for the same request.
+func LogStatus(db *sql.DB, broker *events.Broker, requestID, requestType, requestStatus, message string) {
+	_, err := db.Exec("CALL update_pass_reset_logs($1, $2, $3, $4)", requestID, requestType, requestStatus, message)
 	if err != nil {
-		log.Error().Err(err).Msgf("Failed to update status: %v", err)
+		log.Error().Err(err).Str("request_id", requestID).Msgf("Failed to update status: %v", err)
+		return
 	}
+	broker.Publish(events.Event{Topic: events.TopicLogs, Type: "log-status", Data: map[string]string{
+		"correlationID": requestID,
+		"requestType":   requestType,
+		"requestStatus": requestStatus,
+		"message":       message,
+	}})
 }
 
-
+// LogServerAttempt records the outcome of a password update on a single server of the request.
+func LogServerAttempt(db *sql.DB, requestID, server string, isPrimary bool, operation string, attempt int, status, errMsg string, duration time.Duration) {
+	_, err := db.Exec("CALL log_pass_reset_server_attempt($1, $2, $3, $4, $5, $6, $7, $8)",
+		requestID, server, isPrimary, operation, attempt, status, errMsg, duration.Milliseconds())
+	if err != nil {
+		log.Error().Err(err).Str("request_id", requestID).Msgf("Failed to log attempt on server %s: %v", server, err)
+	}
+}
@@ -0,0 +1,235 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	MFAIssuer         = "DBA Self Service"
+	mfaChallengeTTL   = 5 * time.Minute
+	maxMFAAttempts    = 5
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrInvalidMFAChallenge = errors.New("login step expired, please log in again")
+	ErrInvalidMFACode      = errors.New("invalid authentication code")
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled      = errors.New("no two-factor enrollment in progress")
+)
+
+func IsMFAEnabled(db *sql.DB, username string) (bool, error) {
+	var enabled bool
+	err := db.QueryRow("SELECT totp_enabled FROM admin WHERE username = $1", username).Scan(&enabled)
+	return enabled, err
+}
+
+// StartMFAEnrollment stores a new pending secret for the admin. It only becomes active once
+// ConfirmMFAEnrollment sees a valid code generated from it.
+func StartMFAEnrollment(db *sql.DB, username string) (string, string, error) {
+	enabled, err := IsMFAEnabled(db, username)
+	if err != nil {
+		return "", "", err
+	}
+	if enabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	if _, err = db.Exec("UPDATE admin SET totp_pending_secret = $2 WHERE username = $1", username, secret); err != nil {
+		return "", "", err
+	}
+	return secret, TOTPProvisioningURI(MFAIssuer, username, secret), nil
+}
+
+// ConfirmMFAEnrollment activates the pending secret and returns a fresh set of recovery codes,
+// which are only ever shown this once.
+func ConfirmMFAEnrollment(db *sql.DB, username, code string) ([]string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var pending sql.NullString
+	err = tx.QueryRow("SELECT totp_pending_secret FROM admin WHERE username = $1 FOR UPDATE", username).Scan(&pending)
+	if err != nil {
+		return nil, err
+	}
+	if !pending.Valid {
+		return nil, ErrMFANotEnrolled
+	}
+	step, ok := ValidateTOTP(pending.String, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	_, err = tx.Exec(`UPDATE admin SET totp_secret = totp_pending_secret, totp_pending_secret = NULL,
+		totp_enabled = TRUE, totp_last_step = $2 WHERE username = $1`, username, step)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = tx.Exec("DELETE FROM admin_recovery_codes WHERE username = $1", username); err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec("INSERT INTO admin_recovery_codes (username, code_hash) VALUES ($1, crypt($2, gen_salt('bf')))",
+			username, normalizeRecoveryCode(code))
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, tx.Commit()
+}
+
+// DisableMFA removes the authenticator and recovery codes of the admin.
+func DisableMFA(db *sql.DB, username string) error {
+	_, err := db.Exec(`UPDATE admin SET totp_secret = NULL, totp_pending_secret = NULL, totp_enabled = FALSE,
+		totp_last_step = 0 WHERE username = $1`, username)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("DELETE FROM admin_recovery_codes WHERE username = $1", username)
+	return err
+}
+
+// VerifyMFACode checks a TOTP code of an enrolled admin. A code is accepted only once.
+func VerifyMFACode(db *sql.DB, username, code string) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	ok, err := verifyTOTPTx(tx, username, code)
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// CreateMFAChallenge is issued once the password is verified and must be completed with
+// CompleteMFAChallenge before a session is created.
+func CreateMFAChallenge(db *sql.DB, username string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	_, err := db.Exec("INSERT INTO admin_mfa_challenges (token_hash, username, expires_at) VALUES ($1, $2, $3)",
+		hashToken(token), username, time.Now().Add(mfaChallengeTTL))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// MFAChallengeUsername returns the admin an open challenge was issued for, so that the attempt to
+// complete it can be counted against the admin before the code is checked.
+func MFAChallengeUsername(db *sql.DB, token string) (string, error) {
+	var username string
+	err := db.QueryRow(`SELECT username FROM admin_mfa_challenges
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP`, hashToken(token)).Scan(&username)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidMFAChallenge
+	}
+	return username, err
+}
+
+// CompleteMFAChallenge verifies either a TOTP code or a recovery code for the challenge and
+// returns the admin it was issued for, also when the code is wrong so the failure can be counted.
+func CompleteMFAChallenge(db *sql.DB, token, code, recoveryCode string) (string, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var username string
+	var attempts int
+	err = tx.QueryRow(`SELECT username, attempts FROM admin_mfa_challenges
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP FOR UPDATE`, hashToken(token)).
+		Scan(&username, &attempts)
+	if err == sql.ErrNoRows {
+		return "", ErrInvalidMFAChallenge
+	}
+	if err != nil {
+		return "", err
+	}
+	if attempts >= maxMFAAttempts {
+		return "", ErrInvalidMFAChallenge
+	}
+
+	var ok bool
+	if recoveryCode != "" {
+		err = tx.QueryRow("SELECT use_admin_recovery_code($1, $2)", username, normalizeRecoveryCode(recoveryCode)).Scan(&ok)
+	} else {
+		ok, err = verifyTOTPTx(tx, username, code)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		if _, err = tx.Exec("UPDATE admin_mfa_challenges SET attempts = attempts + 1 WHERE token_hash = $1", hashToken(token)); err != nil {
+			return "", err
+		}
+		if err = tx.Commit(); err != nil {
+			return "", err
+		}
+		return username, ErrInvalidMFACode
+	}
+
+	if _, err = tx.Exec("UPDATE admin_mfa_challenges SET used_at = CURRENT_TIMESTAMP WHERE token_hash = $1", hashToken(token)); err != nil {
+		return "", err
+	}
+	return username, tx.Commit()
+}
+
+func verifyTOTPTx(tx *sql.Tx, username, code string) (bool, error) {
+	var secret sql.NullString
+	var lastStep int64
+	err := tx.QueryRow("SELECT totp_secret, totp_last_step FROM admin WHERE username = $1 AND totp_enabled FOR UPDATE", username).
+		Scan(&secret, &lastStep)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	step, ok := acceptTOTP(secret.String, code, lastStep, time.Now())
+	if !ok {
+		return false, nil
+	}
+	_, err = tx.Exec("UPDATE admin SET totp_last_step = $2 WHERE username = $1", username, step)
+	return err == nil, err
+}
+
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(totpEncoding.EncodeToString(raw))
+	return code[0:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:16], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
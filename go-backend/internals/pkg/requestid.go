@@ -0,0 +1,34 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIDHeader carries the correlation ID the server generated for a request. ClientRequestIDHeader
+// echoes the ID a client sent in X-Request-ID.
+const (
+	RequestIDHeader       = "X-Request-ID"
+	ClientRequestIDHeader = "X-Client-Request-ID"
+)
+
+type requestIDKey struct{}
+
+func NewRequestID() string {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(raw)
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the correlation ID set by the request ID middleware, or "" outside a request.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
@@ -18,11 +18,11 @@ func SendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
 	if statusCode >= 400 {
 		w.WriteHeader(statusCode)
 	}
-	response := map[string]string{"error": message}
+	response := errorBody(w, message)
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		log.Error().Msgf("Failed to send error response: %v", err)
 	}
-	log.Info().Msgf("Error response sent: %v with status code %d", message, statusCode)
+	log.Info().Str("request_id", response["requestID"]).Msgf("Error response sent: %v with status code %d", message, statusCode)
 }
 
 func SendJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
@@ -34,6 +34,15 @@ func SendJSONResponse(w http.ResponseWriter, data interface{}, statusCode int) {
 }
 
 func SendErrorResponse2(w http.ResponseWriter, message string, statusCode int) {
-	response := map[string]string{"error": message}
+	response := errorBody(w, message)
 	SendJSONResponse(w, response, statusCode)
-}
\ No newline at end of file
+}
+
+// errorBody includes the correlation ID set by the request ID middleware, so users can quote it
+func errorBody(w http.ResponseWriter, message string) map[string]string {
+	response := map[string]string{"error": message}
+	if requestID := w.Header().Get(RequestIDHeader); requestID != "" {
+		response["requestID"] = requestID
+	}
+	return response
+}
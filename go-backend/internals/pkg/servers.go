@@ -0,0 +1,220 @@
+package pkg
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"go-backend/internals/secrets"
+	"go-backend/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrUnknownServer   = errors.New("server is not in the inventory")
+	ErrServerDisabled  = errors.New("server is disabled in the inventory")
+	ErrAmbiguousServer = errors.New("several servers use this host, use the server name instead")
+	ErrInvalidServer   = errors.New("invalid server")
+	ErrDuplicateServer = errors.New("a server with this name or host and port already exists")
+	ErrServerNotFound  = errors.New("server not found")
+)
+
+// default ports of the engines, used when a server is added without one
+var defaultServerPorts = map[string]int{
+	"mssql":    1433,
+	"postgres": 5432,
+	"mysql":    3306,
+}
+
+const serverColumns = `id, name, host, port, engine, environment, availability_group, credential_ref, enabled, created_at, updated_at`
+
+func scanServer(row interface{ Scan(...interface{}) error }) (models.DatabaseServer, error) {
+	var server models.DatabaseServer
+	var availabilityGroup, credentialRef sql.NullString
+	var createdAt, updatedAt pq.NullTime
+	err := row.Scan(&server.ID, &server.Name, &server.Host, &server.Port, &server.Engine, &server.Environment,
+		&availabilityGroup, &credentialRef, &server.Enabled, &createdAt, &updatedAt)
+	server.AvailabilityGroup = availabilityGroup.String
+	server.CredentialRef = credentialRef.String
+	if createdAt.Valid {
+		server.CreatedAt = createdAt.Time.Format("2006-01-02 15:04:05")
+	}
+	if updatedAt.Valid {
+		server.UpdatedAt = updatedAt.Time.Format("2006-01-02 15:04:05")
+	}
+	return server, err
+}
+
+func GetAllServers(db *sql.DB) ([]models.DatabaseServer, error) {
+	rows, err := db.Query("SELECT " + serverColumns + " FROM database_servers ORDER BY environment, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	servers := []models.DatabaseServer{}
+	for rows.Next() {
+		server, err := scanServer(rows)
+		if err != nil {
+			return nil, err
+		}
+		servers = append(servers, server)
+	}
+	return servers, rows.Err()
+}
+
+func GetServer(db *sql.DB, id int) (models.DatabaseServer, error) {
+	server, err := scanServer(db.QueryRow("SELECT "+serverColumns+" FROM database_servers WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return models.DatabaseServer{}, ErrServerNotFound
+	}
+	return server, err
+}
+
+// FindServer resolves what a user entered as the server, either the name of an inventory entry or its
+// host. A host that is listed with several ports has to be picked by name. Disabled servers are refused.
+func FindServer(db *sql.DB, server string) (models.DatabaseServer, error) {
+	rows, err := db.Query("SELECT "+serverColumns+` FROM database_servers WHERE name = $1 OR host = $1
+		ORDER BY name = $1 DESC LIMIT 2`, server)
+	if err != nil {
+		return models.DatabaseServer{}, err
+	}
+	defer rows.Close()
+
+	var found []models.DatabaseServer
+	for rows.Next() {
+		entry, err := scanServer(rows)
+		if err != nil {
+			return models.DatabaseServer{}, err
+		}
+		found = append(found, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return models.DatabaseServer{}, err
+	}
+	switch {
+	case len(found) == 0:
+		return models.DatabaseServer{}, ErrUnknownServer
+	case found[0].Name != server && len(found) > 1:
+		return models.DatabaseServer{}, ErrAmbiguousServer
+	case !found[0].Enabled:
+		return models.DatabaseServer{}, ErrServerDisabled
+	}
+	return found[0], nil
+}
+
+// FindServerByHost returns the inventory entry of a host, such as a replica reported by the engine.
+// Hosts that are not listed exactly once and disabled servers are refused like in FindServer.
+func FindServerByHost(db *sql.DB, host string) (models.DatabaseServer, error) {
+	rows, err := db.Query("SELECT "+serverColumns+" FROM database_servers WHERE host = $1 LIMIT 2", host)
+	if err != nil {
+		return models.DatabaseServer{}, err
+	}
+	defer rows.Close()
+
+	var found []models.DatabaseServer
+	for rows.Next() {
+		entry, err := scanServer(rows)
+		if err != nil {
+			return models.DatabaseServer{}, err
+		}
+		found = append(found, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return models.DatabaseServer{}, err
+	}
+	switch {
+	case len(found) == 0:
+		return models.DatabaseServer{}, ErrUnknownServer
+	case len(found) > 1:
+		return models.DatabaseServer{}, ErrAmbiguousServer
+	case !found[0].Enabled:
+		return models.DatabaseServer{}, ErrServerDisabled
+	}
+	return found[0], nil
+}
+
+// ValidateServer trims the entry, fills in the default port of the engine and checks the fields. The
+// credential reference is checked against the providers of refs.
+func ValidateServer(server *models.DatabaseServer, refs *secrets.Resolver) error {
+	server.Name = strings.TrimSpace(server.Name)
+	server.Host = strings.TrimSpace(server.Host)
+	server.Engine = strings.ToLower(strings.TrimSpace(server.Engine))
+	server.Environment = strings.TrimSpace(server.Environment)
+	server.AvailabilityGroup = strings.TrimSpace(server.AvailabilityGroup)
+	server.CredentialRef = strings.TrimSpace(server.CredentialRef)
+
+	defaultPort, ok := defaultServerPorts[server.Engine]
+	switch {
+	case server.Name == "" || server.Host == "":
+		return invalidServer("name and host are required")
+	case strings.ContainsAny(server.Host, " ;/\\'\"") || strings.ContainsAny(server.Name, "\x00\r\n"):
+		return invalidServer("host must be a host name or IP address")
+	case !ok:
+		return invalidServer("engine must be one of mssql, postgres or mysql")
+	case server.Port < 0 || server.Port > 65535:
+		return invalidServer("port must be between 1 and 65535")
+	case server.CredentialRef != "" && refs.ValidateRef(server.CredentialRef) != nil:
+		return invalidServer(secrets.ErrInvalidRef.Error())
+	}
+	if server.Port == 0 {
+		server.Port = defaultPort
+	}
+	if server.Environment == "" {
+		server.Environment = "production"
+	}
+	return nil
+}
+
+func invalidServer(message string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidServer, message)
+}
+
+func CreateServer(db *sql.DB, refs *secrets.Resolver, server models.DatabaseServer) (models.DatabaseServer, error) {
+	if err := ValidateServer(&server, refs); err != nil {
+		return models.DatabaseServer{}, err
+	}
+	created, err := scanServer(db.QueryRow(`INSERT INTO database_servers
+		(name, host, port, engine, environment, availability_group, credential_ref, enabled)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), NULLIF($7, ''), $8)
+		RETURNING `+serverColumns,
+		server.Name, server.Host, server.Port, server.Engine, server.Environment, server.AvailabilityGroup, server.CredentialRef, server.Enabled))
+	return created, serverWriteError(err)
+}
+
+func UpdateServer(db *sql.DB, refs *secrets.Resolver, server models.DatabaseServer) (models.DatabaseServer, error) {
+	if err := ValidateServer(&server, refs); err != nil {
+		return models.DatabaseServer{}, err
+	}
+	updated, err := scanServer(db.QueryRow(`UPDATE database_servers SET name = $2, host = $3, port = $4, engine = $5,
+		environment = $6, availability_group = NULLIF($7, ''), credential_ref = NULLIF($8, ''), enabled = $9,
+		updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+serverColumns,
+		server.ID, server.Name, server.Host, server.Port, server.Engine, server.Environment, server.AvailabilityGroup, server.CredentialRef, server.Enabled))
+	return updated, serverWriteError(err)
+}
+
+func DeleteServer(db *sql.DB, id int) error {
+	result, err := db.Exec("DELETE FROM database_servers WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrServerNotFound
+	}
+	return nil
+}
+
+func serverWriteError(err error) error {
+	var pqErr *pq.Error
+	switch {
+	case err == sql.ErrNoRows:
+		return ErrServerNotFound
+	case errors.As(err, &pqErr) && pqErr.Code == "23505":
+		return ErrDuplicateServer
+	}
+	return err
+}
@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-backend/internals/config"
+)
+
+const SessionCookieName = "dba_admin_session"
+
+var ErrInvalidSession = errors.New("invalid or expired session")
+
+type Session struct {
+	Username           string
+	Role               string
+	MustChangePassword bool
+	MFAEnabled         bool
+	ExpiresAt          time.Time
+	AbsoluteExpiresAt  time.Time
+	token              string
+}
+
+// CreateSession starts a new session for the admin and returns it with its signed token.
+func CreateSession(db *sql.DB, c config.SessionConfig, username string) (Session, error) {
+	var role string
+	var mustChangePassword, mfaEnabled bool
+	err := db.QueryRow("SELECT role, must_change_password, totp_enabled FROM admin WHERE username = $1", username).
+		Scan(&role, &mustChangePassword, &mfaEnabled)
+	if err != nil {
+		return Session{}, err
+	}
+	session, err := insertSession(db, c, username, time.Now().Add(c.MaxAge))
+	session.Role = role
+	session.MustChangePassword = mustChangePassword
+	session.MFAEnabled = mfaEnabled
+	return session, err
+}
+
+func insertSession(db *sql.DB, c config.SessionConfig, username string, absoluteExpiry time.Time) (Session, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return Session{}, err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	expiresAt := time.Now().Add(c.TTL)
+	if expiresAt.After(absoluteExpiry) {
+		expiresAt = absoluteExpiry
+	}
+	_, err := db.Exec(`INSERT INTO admin_sessions (token_hash, username, created_at, expires_at, absolute_expires_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP, $3, $4)`, hashToken(token), username, expiresAt, absoluteExpiry)
+	if err != nil {
+		return Session{}, err
+	}
+	return Session{Username: username, ExpiresAt: expiresAt, AbsoluteExpiresAt: absoluteExpiry, token: token}, nil
+}
+
+// LookupSession verifies the signed cookie value and returns the live session it refers to.
+func LookupSession(db *sql.DB, c config.SessionConfig, cookieValue string) (Session, error) {
+	token, ok := verifyToken(c, cookieValue)
+	if !ok {
+		return Session{}, ErrInvalidSession
+	}
+
+	// the admin row is read on every request so that role changes and disabling apply to live sessions
+	session := Session{token: token}
+	err := db.QueryRow(`SELECT s.username, a.role, a.must_change_password, a.totp_enabled, s.expires_at, s.absolute_expires_at
+		FROM admin_sessions s
+		JOIN admin a ON a.username = s.username
+		WHERE s.token_hash = $1 AND s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP
+		AND NOT a.disabled`, hashToken(token)).
+		Scan(&session.Username, &session.Role, &session.MustChangePassword, &session.MFAEnabled, &session.ExpiresAt, &session.AbsoluteExpiresAt)
+	if err == sql.ErrNoRows {
+		return Session{}, ErrInvalidSession
+	}
+	if err != nil {
+		return Session{}, err
+	}
+	return session, nil
+}
+
+// RefreshSession rotates the token of a live session and extends its idle expiry,
+// never past the absolute lifetime of the original login.
+func RefreshSession(db *sql.DB, c config.SessionConfig, session Session) (Session, error) {
+	if !time.Now().Before(session.AbsoluteExpiresAt) {
+		return Session{}, ErrInvalidSession
+	}
+	if err := RevokeSession(db, session); err != nil {
+		return Session{}, err
+	}
+	refreshed, err := insertSession(db, c, session.Username, session.AbsoluteExpiresAt)
+	refreshed.Role = session.Role
+	refreshed.MustChangePassword = session.MustChangePassword
+	refreshed.MFAEnabled = session.MFAEnabled
+	return refreshed, err
+}
+
+func RevokeSession(db *sql.DB, session Session) error {
+	_, err := db.Exec("UPDATE admin_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE token_hash = $1", hashToken(session.token))
+	return err
+}
+
+// RevokeAllSessions ends every session of the admin, e.g. after a password change.
+func RevokeAllSessions(db *sql.DB, username string) error {
+	_, err := db.Exec("UPDATE admin_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE username = $1 AND revoked_at IS NULL", username)
+	return err
+}
+
+func SetSessionCookie(w http.ResponseWriter, c config.SessionConfig, session Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    signToken(c, session.token),
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   c.CookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func ClearSessionCookie(w http.ResponseWriter, c config.SessionConfig) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   c.CookieSecure,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+func signToken(c config.SessionConfig, token string) string {
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	mac.Write([]byte(token))
+	return token + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func verifyToken(c config.SessionConfig, value string) (string, bool) {
+	token, _, found := strings.Cut(value, ".")
+	if !found || token == "" {
+		return "", false
+	}
+	expected := signToken(c, token)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(value)) != 1 {
+		return "", false
+	}
+	return token, true
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// the secret of the test vectors in RFC 4226 and RFC 6238
+var rfcSecret = []byte("12345678901234567890")
+
+func TestHOTPVectors(t *testing.T) {
+	// RFC 4226, appendix D
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range want {
+		if got := hotp(rfcSecret, uint64(counter), 6); got != code {
+			t.Errorf("HOTP of counter %d = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTPVectors(t *testing.T) {
+	// RFC 6238, appendix B, SHA-1 with 8 digits
+	for _, tc := range []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	} {
+		if got := hotp(rfcSecret, uint64(tc.unix/totpPeriod), 8); got != tc.code {
+			t.Errorf("TOTP at %d = %s, want %s", tc.unix, got, tc.code)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfcSecret)
+	// the code of step 1, 30s to 59s after the epoch
+	code := hotp(rfcSecret, 1, totpDigits)
+
+	for _, tc := range []struct {
+		unix int64
+		ok   bool
+	}{
+		{0, true},   // one step early
+		{45, true},  // on time
+		{89, true},  // one step late
+		{90, false}, // two steps late
+	} {
+		step, ok := ValidateTOTP(secret, code, time.Unix(tc.unix, 0))
+		if ok != tc.ok || (ok && step != 1) {
+			t.Errorf("ValidateTOTP at %ds = %d, %v, want step 1 %v", tc.unix, step, ok, tc.ok)
+		}
+	}
+
+	// lower case secrets with spaces are accepted, codes of the wrong length are not
+	if _, ok := ValidateTOTP(" "+strings.ToLower(secret)+" ", code, time.Unix(45, 0)); !ok {
+		t.Error("a lower case secret was refused")
+	}
+	if _, ok := ValidateTOTP(secret, "0"+code, time.Unix(45, 0)); ok {
+		t.Error("a code with too many digits was accepted")
+	}
+}
+
+func TestAcceptTOTPRejectsReplay(t *testing.T) {
+	secret := totpEncoding.EncodeToString(rfcSecret)
+	now := time.Unix(75, 0) // step 2
+	code := hotp(rfcSecret, 2, totpDigits)
+
+	step, ok := acceptTOTP(secret, code, 0, now)
+	if !ok || step != 2 {
+		t.Fatalf("acceptTOTP = %d, %v, want step 2", step, ok)
+	}
+	// step is stored as totp_last_step, the same code is refused from then on
+	if _, ok := acceptTOTP(secret, code, step, now); ok {
+		t.Error("the code was accepted a second time")
+	}
+	// a code of an earlier step within the skew window is refused as well
+	if _, ok := acceptTOTP(secret, hotp(rfcSecret, 1, totpDigits), step, now); ok {
+		t.Error("the code of an earlier step was accepted after a later one")
+	}
+	if next, ok := acceptTOTP(secret, hotp(rfcSecret, 3, totpDigits), step, now); !ok || next != 3 {
+		t.Errorf("the code of the next step = %d, %v, want step 3", next, ok)
+	}
+}
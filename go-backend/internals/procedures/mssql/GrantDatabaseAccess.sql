@@ -0,0 +1,42 @@
+CREATE OR ALTER PROCEDURE dbo.GrantDatabaseAccess
+    @LoginName SYSNAME,
+    @DatabaseName SYSNAME,
+    @RoleName SYSNAME
+AS
+BEGIN
+    SET NOCOUNT ON;
+
+    IF SUSER_ID(@LoginName) IS NULL
+    BEGIN
+        THROW 50001, 'Login does not exist on this server.', 1;
+    END
+
+    IF DB_ID(@DatabaseName) IS NULL
+    BEGIN
+        THROW 50002, 'Database does not exist on this server.', 1;
+    END
+
+    -- readable secondaries receive the change from the primary through the availability group
+    IF DATABASEPROPERTYEX(@DatabaseName, 'Updateability') = 'READ_ONLY'
+    BEGIN
+        PRINT 'Database [' + @DatabaseName + '] is read-only on this replica, skipping.';
+        RETURN;
+    END
+
+    DECLARE @SQL NVARCHAR(MAX);
+
+    SET @SQL = N'USE ' + QUOTENAME(@DatabaseName) + N';
+        IF DATABASE_PRINCIPAL_ID(@Login) IS NULL
+            CREATE USER ' + QUOTENAME(@LoginName) + N' FOR LOGIN ' + QUOTENAME(@LoginName) + N';
+        ALTER ROLE ' + QUOTENAME(@RoleName) + N' ADD MEMBER ' + QUOTENAME(@LoginName) + N';';
+
+    BEGIN TRY
+        EXEC sp_executesql @SQL, N'@Login SYSNAME', @Login = @LoginName;
+    END TRY
+    BEGIN CATCH
+        PRINT 'Granting role [' + @RoleName + '] on [' + @DatabaseName + '] to [' + @LoginName + '] has failed.';
+        THROW;
+    END CATCH;
+
+    PRINT 'Granted role [' + @RoleName + '] on [' + @DatabaseName + '] to [' + @LoginName + '].';
+END;
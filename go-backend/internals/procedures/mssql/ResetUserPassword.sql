@@ -0,0 +1,54 @@
+CREATE OR ALTER PROCEDURE dbo.ResetUserPassword
+    @LoginName SYSNAME,
+    @NewPassword NVARCHAR(128),
+    @OldPassword NVARCHAR(128) = NULL,
+    @DisablePolicy BIT = 0,
+    @DisableExpiration BIT = 0
+AS
+BEGIN
+    SET NOCOUNT ON;
+
+    IF SUSER_ID(@LoginName) IS NULL
+    BEGIN
+        THROW 50001, 'Login does not exist on this server.', 1;
+    END
+
+    IF @NewPassword IS NULL OR @NewPassword = N''
+    BEGIN
+        THROW 50003, 'The new password must not be empty.', 1;
+    END
+
+    IF @DisablePolicy = 1 AND @DisableExpiration = 0
+    BEGIN
+        THROW 50004, 'CHECK_EXPIRATION cannot stay enabled while CHECK_POLICY is disabled.', 1;
+    END
+
+    -- ALTER LOGIN takes no parameters, so the name is quoted with QUOTENAME and the
+    -- passwords are written as N'' literals with every single quote doubled
+    DECLARE @Login NVARCHAR(258) = QUOTENAME(@LoginName);
+    DECLARE @Options NVARCHAR(MAX) = N'ALTER LOGIN ' + @Login
+        + N' WITH CHECK_POLICY = ' + CASE WHEN @DisablePolicy = 1 THEN N'OFF' ELSE N'ON' END
+        + N', CHECK_EXPIRATION = ' + CASE WHEN @DisableExpiration = 1 THEN N'OFF' ELSE N'ON' END + N';';
+    DECLARE @SQL NVARCHAR(MAX) = N'ALTER LOGIN ' + @Login
+        + N' WITH PASSWORD = N''' + REPLACE(@NewPassword, N'''', N'''''') + N'''';
+
+    IF @OldPassword IS NOT NULL
+        SET @SQL = @SQL + N' OLD_PASSWORD = N''' + REPLACE(@OldPassword, N'''', N'''''') + N'''';
+    SET @SQL = @SQL + N';';
+
+    -- the policy is turned off before the password is set and turned on afterwards
+    IF @DisablePolicy = 1
+        SET @SQL = @Options + @SQL;
+    ELSE
+        SET @SQL = @SQL + @Options;
+
+    BEGIN TRY
+        EXEC sp_executesql @SQL;
+    END TRY
+    BEGIN CATCH
+        PRINT 'Password reset for login ' + @Login + ' has failed.';
+        THROW;
+    END CATCH;
+
+    PRINT 'Password reset for login ' + @Login + ' has been completed.';
+END;
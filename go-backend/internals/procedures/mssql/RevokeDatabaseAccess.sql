@@ -0,0 +1,38 @@
+CREATE OR ALTER PROCEDURE dbo.RevokeDatabaseAccess
+    @LoginName SYSNAME,
+    @DatabaseName SYSNAME,
+    @RoleName SYSNAME
+AS
+BEGIN
+    SET NOCOUNT ON;
+
+    -- nothing to revoke if the database is gone
+    IF DB_ID(@DatabaseName) IS NULL
+    BEGIN
+        PRINT 'Database [' + @DatabaseName + '] does not exist, nothing to revoke.';
+        RETURN;
+    END
+
+    -- readable secondaries receive the change from the primary through the availability group
+    IF DATABASEPROPERTYEX(@DatabaseName, 'Updateability') = 'READ_ONLY'
+    BEGIN
+        PRINT 'Database [' + @DatabaseName + '] is read-only on this replica, skipping.';
+        RETURN;
+    END
+
+    DECLARE @SQL NVARCHAR(MAX);
+
+    SET @SQL = N'USE ' + QUOTENAME(@DatabaseName) + N';
+        IF IS_ROLEMEMBER(@Role, @Login) = 1
+            ALTER ROLE ' + QUOTENAME(@RoleName) + N' DROP MEMBER ' + QUOTENAME(@LoginName) + N';';
+
+    BEGIN TRY
+        EXEC sp_executesql @SQL, N'@Login SYSNAME, @Role SYSNAME', @Login = @LoginName, @Role = @RoleName;
+    END TRY
+    BEGIN CATCH
+        PRINT 'Revoking role [' + @RoleName + '] on [' + @DatabaseName + '] from [' + @LoginName + '] has failed.';
+        THROW;
+    END CATCH;
+
+    PRINT 'Revoked role [' + @RoleName + '] on [' + @DatabaseName + '] from [' + @LoginName + '].';
+END;
@@ -0,0 +1,247 @@
+// Package procedures deploys the stored procedures the backend runs on every SQL Server of the inventory,
+// GrantDatabaseAccess and RevokeDatabaseAccess for temporary grants and ResetUserPassword for DBAs. The
+// scripts are part of the binary. Every deployment is recorded per server in PostgreSQL, so a procedure
+// that was changed on the server afterwards shows up as drift.
+package procedures
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-backend/internals/engine"
+	"go-backend/models"
+
+	"github.com/lib/pq"
+)
+
+//go:embed mssql/*.sql
+var scripts embed.FS
+
+const (
+	// StatusCurrent is a procedure that matches the built-in script
+	StatusCurrent = "current"
+	StatusMissing = "missing"
+	// StatusOutdated is an earlier version deployed by the backend
+	StatusOutdated = "outdated"
+	// StatusDrifted is a version the backend never deployed, e.g. one changed by hand
+	StatusDrifted = "drifted"
+)
+
+var ErrNotMSSQL = errors.New("stored procedures are only deployed to SQL Server")
+
+// Procedure is a built-in script, Name is the schema qualified name of the procedure it creates.
+type Procedure struct {
+	Name     string
+	Script   string
+	Checksum string
+}
+
+var (
+	setOnce sync.Once
+	set     []Procedure
+	setErr  error
+)
+
+// Set returns the built-in procedures ordered by name.
+func Set() ([]Procedure, error) {
+	setOnce.Do(func() {
+		set, setErr = load(scripts, "mssql")
+	})
+	return set, setErr
+}
+
+func load(fsys fs.FS, dir string) ([]Procedure, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	procedures := []Procedure{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		script, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		procedures = append(procedures, Procedure{
+			Name:     "dbo." + strings.TrimSuffix(entry.Name(), ".sql"),
+			Script:   string(script),
+			Checksum: checksum(string(script)),
+		})
+	}
+	sort.Slice(procedures, func(i, j int) bool { return procedures[i].Name < procedures[j].Name })
+	return procedures, nil
+}
+
+// checksum hashes a procedure definition. SQL Server may hand the definition back with other line endings
+// or surrounding whitespace, which do not count as a change.
+func checksum(definition string) string {
+	normalized := strings.TrimSpace(strings.ReplaceAll(definition, "\r\n", "\n"))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Version identifies a set of procedures by the checksums of its scripts, in the order of Set.
+func Version(checksums []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(checksums, "\n")))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// classify compares the checksum of the definition on the server with the built-in script and with the
+// script the backend last deployed there.
+func classify(expected, installed, deployed string) string {
+	switch {
+	case installed == "":
+		return StatusMissing
+	case installed == expected:
+		return StatusCurrent
+	case installed == deployed:
+		return StatusOutdated
+	}
+	return StatusDrifted
+}
+
+type deployment struct {
+	checksum   string
+	deployedBy string
+	deployedAt pq.NullTime
+}
+
+// deployments returns what the backend deployed to the inventory entry with the id, by procedure name.
+func deployments(ctx context.Context, db *sql.DB, serverID int) (map[string]deployment, error) {
+	rows, err := db.QueryContext(ctx, "SELECT procedure_name, checksum, deployed_by, deployed_at FROM server_procedures WHERE server_id = $1", serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deployed := map[string]deployment{}
+	for rows.Next() {
+		var name string
+		var d deployment
+		if err := rows.Scan(&name, &d.checksum, &d.deployedBy, &d.deployedAt); err != nil {
+			return nil, err
+		}
+		deployed[name] = d
+	}
+	return deployed, rows.Err()
+}
+
+// Verify compares the procedures on the server with the built-in set.
+func Verify(ctx context.Context, db *sql.DB, connector *engine.Connector, server engine.Server) (models.ProcedureReport, error) {
+	if server.Engine != engine.MSSQL {
+		return models.ProcedureReport{}, ErrNotMSSQL
+	}
+	conn, err := connector.ConnectMSSQLServer(ctx, server)
+	if err != nil {
+		return models.ProcedureReport{}, err
+	}
+	defer conn.Close()
+	return verify(ctx, db, conn, server)
+}
+
+// Deploy creates or alters every procedure of the set on the server, records the deployment and returns
+// the state of the server afterwards.
+func Deploy(ctx context.Context, db *sql.DB, connector *engine.Connector, server engine.Server, deployedBy string) (models.ProcedureReport, error) {
+	if server.Engine != engine.MSSQL {
+		return models.ProcedureReport{}, ErrNotMSSQL
+	}
+	procedures, err := Set()
+	if err != nil {
+		return models.ProcedureReport{}, err
+	}
+	conn, err := connector.ConnectMSSQLServer(ctx, server)
+	if err != nil {
+		return models.ProcedureReport{}, err
+	}
+	defer conn.Close()
+
+	for _, procedure := range procedures {
+		if _, err := conn.ExecContext(ctx, procedure.Script); err != nil {
+			return models.ProcedureReport{}, fmt.Errorf("deploying %s to %s: %w", procedure.Name, server.Name, err)
+		}
+		_, err := db.ExecContext(ctx, `INSERT INTO server_procedures (server_id, procedure_name, checksum, deployed_by, deployed_at)
+			VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+			ON CONFLICT (server_id, procedure_name) DO UPDATE SET
+				checksum = EXCLUDED.checksum, deployed_by = EXCLUDED.deployed_by, deployed_at = EXCLUDED.deployed_at`,
+			server.ID, procedure.Name, procedure.Checksum, deployedBy)
+		if err != nil {
+			return models.ProcedureReport{}, err
+		}
+	}
+	return verify(ctx, db, conn, server)
+}
+
+// Install creates or alters every procedure of the set on the server behind conn without recording it,
+// it is how the central SQL Server gets them.
+func Install(ctx context.Context, conn *sql.DB) error {
+	procedures, err := Set()
+	if err != nil {
+		return err
+	}
+	for _, procedure := range procedures {
+		if _, err := conn.ExecContext(ctx, procedure.Script); err != nil {
+			return fmt.Errorf("installing %s: %w", procedure.Name, err)
+		}
+	}
+	return nil
+}
+
+func verify(ctx context.Context, db *sql.DB, conn *sql.DB, server engine.Server) (models.ProcedureReport, error) {
+	procedures, err := Set()
+	if err != nil {
+		return models.ProcedureReport{}, err
+	}
+	deployed, err := deployments(ctx, db, server.ID)
+	if err != nil {
+		return models.ProcedureReport{}, err
+	}
+
+	report := models.ProcedureReport{Server: server.Name, Host: server.Host, Procedures: []models.ProcedureStatus{}}
+	var expected, installed []string
+	complete := true
+	for _, procedure := range procedures {
+		var definition sql.NullString
+		if err := conn.QueryRowContext(ctx, "SELECT OBJECT_DEFINITION(OBJECT_ID(?))", procedure.Name).Scan(&definition); err != nil {
+			return models.ProcedureReport{}, fmt.Errorf("reading %s on %s: %w", procedure.Name, server.Name, err)
+		}
+
+		status := models.ProcedureStatus{Name: procedure.Name, Checksum: procedure.Checksum}
+		if definition.Valid {
+			status.InstalledChecksum = checksum(definition.String)
+		}
+		d, ok := deployed[procedure.Name]
+		if ok {
+			status.DeployedBy = d.deployedBy
+			if d.deployedAt.Valid {
+				status.DeployedAt = d.deployedAt.Time.Format("2006-01-02 15:04:05")
+			}
+			installed = append(installed, d.checksum)
+		} else {
+			complete = false
+		}
+		status.Status = classify(procedure.Checksum, status.InstalledChecksum, d.checksum)
+		report.Drift = report.Drift || status.Status != StatusCurrent
+		report.Procedures = append(report.Procedures, status)
+		expected = append(expected, procedure.Checksum)
+	}
+
+	report.Version = Version(expected)
+	if complete {
+		report.InstalledVersion = Version(installed)
+	}
+	report.CheckedAt = time.Now().Format("2006-01-02 15:04:05")
+	return report, nil
+}
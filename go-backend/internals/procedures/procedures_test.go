@@ -0,0 +1,61 @@
+package procedures
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	procedures, err := Set()
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	var names []string
+	for _, procedure := range procedures {
+		names = append(names, procedure.Name)
+		// each script is sent as one batch, which CREATE PROCEDURE has to start
+		if !strings.HasPrefix(procedure.Script, "CREATE OR ALTER PROCEDURE "+procedure.Name) {
+			t.Errorf("%s does not start with CREATE OR ALTER PROCEDURE %s", procedure.Name, procedure.Name)
+		}
+	}
+	want := "dbo.GrantDatabaseAccess,dbo.ResetUserPassword,dbo.RevokeDatabaseAccess"
+	if strings.Join(names, ",") != want {
+		t.Errorf("procedures = %v, want %s", names, want)
+	}
+}
+
+func TestChecksumIgnoresLineEndings(t *testing.T) {
+	script := "CREATE OR ALTER PROCEDURE dbo.P\nAS\nBEGIN\n    SELECT 1;\nEND;\n"
+	if checksum(script) != checksum("\r\n"+strings.ReplaceAll(script, "\n", "\r\n")) {
+		t.Error("the checksum changed with the line endings")
+	}
+	if checksum(script) == checksum(strings.Replace(script, "SELECT 1", "SELECT 2", 1)) {
+		t.Error("the checksum did not change with the definition")
+	}
+}
+
+func TestClassify(t *testing.T) {
+	for _, tc := range []struct {
+		installed, deployed string
+		want                string
+	}{
+		{"", "", StatusMissing},
+		{"", "old", StatusMissing},
+		{"new", "", StatusCurrent},
+		{"new", "old", StatusCurrent},
+		{"old", "old", StatusOutdated},
+		{"manual", "old", StatusDrifted},
+		{"manual", "", StatusDrifted},
+	} {
+		if got := classify("new", tc.installed, tc.deployed); got != tc.want {
+			t.Errorf("classify(new, %q, %q) = %s, want %s", tc.installed, tc.deployed, got, tc.want)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	a, b := Version([]string{"x", "y"}), Version([]string{"y", "x"})
+	if len(a) != 12 || a == b {
+		t.Errorf("Version = %s, %s, want 12 digits that depend on the order", a, b)
+	}
+}
@@ -0,0 +1,28 @@
+package secrets
+
+import (
+	"context"
+	"os"
+	"regexp"
+)
+
+// EnvPrefix is put in front of every variable the env provider reads, so a credential reference can only
+// reach variables that were set up as credentials and not e.g. DB_PASSWORD or SESSION_SECRET.
+const EnvPrefix = "DBA_SECRET_"
+
+var envName = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_]{0,63}$`)
+
+// EnvProvider reads the credentials NAME from DBA_SECRET_NAME_USER and DBA_SECRET_NAME_PASSWORD. Names are
+// upper case letters, digits and underscores.
+type EnvProvider struct{}
+
+func (EnvProvider) Lookup(ctx context.Context, name string) (Credentials, error) {
+	if !envName.MatchString(name) {
+		return Credentials{}, ErrInvalidRef
+	}
+	credentials := Credentials{Username: os.Getenv(EnvPrefix + name + "_USER"), Password: os.Getenv(EnvPrefix + name + "_PASSWORD")}
+	if credentials.Username == "" && credentials.Password == "" {
+		return Credentials{}, ErrNotFound
+	}
+	return credentials, nil
+}
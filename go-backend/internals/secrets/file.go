@@ -0,0 +1,87 @@
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+)
+
+// additional data of the AES-GCM seal, so that other payloads encrypted with the same key are not accepted
+var fileAAD = []byte("dba-self-service secrets v1")
+
+// FileProvider reads credentials from a JSON object of name to {"username", "password"} sealed with
+// AES-GCM, see cmd/secrets. The file is read on every lookup so it can be replaced while running.
+type FileProvider struct {
+	Path string
+	Key  []byte
+}
+
+func (p *FileProvider) Lookup(ctx context.Context, name string) (Credentials, error) {
+	sealed, err := os.ReadFile(p.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	plaintext, err := OpenFile(p.Key, sealed)
+	if err != nil {
+		return Credentials{}, err
+	}
+	var entries map[string]Credentials
+	if err := json.Unmarshal(plaintext, &entries); err != nil {
+		return Credentials{}, errors.New("secrets file does not hold a JSON object of credentials")
+	}
+	credentials, ok := entries[name]
+	if !ok {
+		return Credentials{}, ErrNotFound
+	}
+	return credentials, nil
+}
+
+// DecodeKey decodes a base64 encoded 32 byte key.
+func DecodeKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("the key must be 32 base64 encoded bytes")
+	}
+	return key, nil
+}
+
+// SealFile encrypts the contents of a secrets file, the nonce is prepended to the result.
+func SealFile(key, plaintext []byte) ([]byte, error) {
+	gcm, err := fileCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, fileAAD), nil
+}
+
+func OpenFile(key, sealed []byte) ([]byte, error) {
+	gcm, err := fileCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("secrets file is too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], fileAAD)
+	if err != nil {
+		return nil, errors.New("secrets file cannot be decrypted with this key")
+	}
+	return plaintext, nil
+}
+
+func fileCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
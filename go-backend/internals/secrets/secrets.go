@@ -0,0 +1,147 @@
+// Package secrets resolves credential references to the credentials of the admin connections. A reference
+// is "provider:name", such as "vault:dba/sales-admin", or just a name for the configured default provider
+// (secrets.provider, env unless set). References are resolved whenever a connection is opened, so rotated secrets apply at once.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"go-backend/internals/config"
+)
+
+const (
+	ProviderEnv   = "env"
+	ProviderFile  = "file"
+	ProviderVault = "vault"
+)
+
+var (
+	ErrNotFound            = errors.New("secret not found")
+	ErrInvalidRef          = errors.New("credential reference must be [env:|file:|vault:]name with letters, digits, _ . / and -, env names with upper case letters, digits and _ only")
+	ErrProviderUnavailable = errors.New("secret provider is not configured")
+)
+
+var refFormat = regexp.MustCompile(`^(?:(env|file|vault):)?([A-Za-z0-9_][A-Za-z0-9_./-]{0,127})$`)
+
+type Credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// SecretProvider looks up the credentials stored under a name.
+type SecretProvider interface {
+	Lookup(ctx context.Context, name string) (Credentials, error)
+}
+
+// Resolver resolves credential references with the providers built from the secrets settings. Providers
+// are set up on first use, so only the configured ones need their settings.
+type Resolver struct {
+	config    config.SecretsConfig
+	mu        sync.Mutex
+	providers map[string]SecretProvider
+}
+
+func NewResolver(c config.SecretsConfig) *Resolver {
+	return &Resolver{config: c, providers: map[string]SecretProvider{}}
+}
+
+// Register replaces the provider used for references with the given prefix, nil goes back to the
+// provider built from the configuration.
+func (r *Resolver) Register(name string, provider SecretProvider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if provider == nil {
+		delete(r.providers, name)
+		return
+	}
+	r.providers[name] = provider
+}
+
+// ParseRef splits a reference into its provider and the name within the provider.
+func (r *Resolver) ParseRef(ref string) (string, string, error) {
+	match := refFormat.FindStringSubmatch(ref)
+	if match == nil || strings.Contains(match[2], "..") || strings.Contains(match[2], "//") || strings.HasSuffix(match[2], "/") {
+		return "", "", ErrInvalidRef
+	}
+	provider := match[1]
+	if provider == "" {
+		provider = r.defaultProvider()
+	}
+	return provider, match[2], nil
+}
+
+// ValidateRef checks the reference and, for the env provider, that the name can be an environment variable.
+func (r *Resolver) ValidateRef(ref string) error {
+	provider, name, err := r.ParseRef(ref)
+	if err == nil && provider == ProviderEnv && !envName.MatchString(name) {
+		return ErrInvalidRef
+	}
+	return err
+}
+
+// Resolve returns the credentials a reference points to.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (Credentials, error) {
+	providerName, name, err := r.ParseRef(ref)
+	if err != nil {
+		return Credentials{}, err
+	}
+	provider, err := r.getProvider(providerName)
+	if err != nil {
+		return Credentials{}, err
+	}
+	credentials, err := provider.Lookup(ctx, name)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("credential reference %s: %w", ref, err)
+	}
+	if credentials.Username == "" || credentials.Password == "" {
+		return Credentials{}, fmt.Errorf("credential reference %s: username or password is empty", ref)
+	}
+	return credentials, nil
+}
+
+func (r *Resolver) defaultProvider() string {
+	if r.config.Provider != "" {
+		return r.config.Provider
+	}
+	return ProviderEnv
+}
+
+// getProvider returns the registered provider or sets it up from the configuration on first use.
+func (r *Resolver) getProvider(name string) (SecretProvider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if provider, ok := r.providers[name]; ok {
+		return provider, nil
+	}
+
+	var provider SecretProvider
+	switch name {
+	case ProviderEnv:
+		provider = EnvProvider{}
+	case ProviderFile:
+		path, encodedKey := r.config.File, r.config.FileKey
+		if path == "" || encodedKey == "" {
+			return nil, fmt.Errorf("%w: %s needs secrets.file and secrets.file_key", ErrProviderUnavailable, name)
+		}
+		key, err := DecodeKey(encodedKey)
+		if err != nil {
+			return nil, fmt.Errorf("secrets.file_key: %w", err)
+		}
+		provider = &FileProvider{Path: path, Key: key}
+	case ProviderVault:
+		addr, token := r.config.Vault.Addr, r.config.Vault.Token
+		if addr == "" || token == "" {
+			return nil, fmt.Errorf("%w: %s needs secrets.vault.addr and secrets.vault.token", ErrProviderUnavailable, name)
+		}
+		provider = NewVaultProvider(addr, token, r.config.Vault.Mount, r.config.Vault.Namespace)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrProviderUnavailable, name)
+	}
+	r.providers[name] = provider
+	return provider, nil
+}
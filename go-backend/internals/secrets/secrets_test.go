@@ -0,0 +1,208 @@
+package secrets
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go-backend/internals/config"
+)
+
+// newResolver returns a resolver for the default settings changed by change.
+func newResolver(change func(*config.SecretsConfig)) *Resolver {
+	c := config.Default().Secrets
+	change(&c)
+	return NewResolver(c)
+}
+
+func TestParseRef(t *testing.T) {
+	cases := []struct {
+		ref      string
+		provider string
+		name     string
+		err      error
+	}{
+		{"SALES_ADMIN", ProviderEnv, "SALES_ADMIN", nil},
+		{"env:SALES_ADMIN", ProviderEnv, "SALES_ADMIN", nil},
+		{"file:sales-admin", ProviderFile, "sales-admin", nil},
+		{"vault:dba/sales/admin", ProviderVault, "dba/sales/admin", nil},
+		{"", "", "", ErrInvalidRef},
+		{"vault:../sys/policy", "", "", ErrInvalidRef},
+		{"vault:dba/../sys", "", "", ErrInvalidRef},
+		{"vault:/dba", "", "", ErrInvalidRef},
+		{"vault:dba//sales", "", "", ErrInvalidRef},
+		{"vault:dba/", "", "", ErrInvalidRef},
+		{"aws:sales", "", "", ErrInvalidRef},
+		{"vault:dba?version=1", "", "", ErrInvalidRef},
+	}
+	resolver := NewResolver(config.Default().Secrets)
+	for _, c := range cases {
+		provider, name, err := resolver.ParseRef(c.ref)
+		if !errors.Is(err, c.err) || provider != c.provider || name != c.name {
+			t.Errorf("ParseRef(%q) = %q, %q, %v, want %q, %q, %v", c.ref, provider, name, err, c.provider, c.name, c.err)
+		}
+	}
+}
+
+func TestValidateRef(t *testing.T) {
+	resolver := NewResolver(config.Default().Secrets)
+	for ref, want := range map[string]error{
+		"SALES_ADMIN":        nil,
+		"env:SALES_ADMIN":    nil,
+		"file:sales-admin":   nil,
+		"env:sales-admin":    ErrInvalidRef,
+		"sales-admin":        ErrInvalidRef,
+		"vault:dba/../sys":   ErrInvalidRef,
+		"vault:dba/sales-01": nil,
+	} {
+		if err := resolver.ValidateRef(ref); !errors.Is(err, want) {
+			t.Errorf("ValidateRef(%q) = %v, want %v", ref, err, want)
+		}
+	}
+}
+
+func TestParseRefDefaultProvider(t *testing.T) {
+	resolver := newResolver(func(c *config.SecretsConfig) { c.Provider = ProviderVault })
+	provider, name, err := resolver.ParseRef("dba/sales")
+	if err != nil || provider != ProviderVault || name != "dba/sales" {
+		t.Fatalf("ParseRef with the vault provider as default = %q, %q, %v", provider, name, err)
+	}
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("DBA_SECRET_SALES_ADMIN_USER", "sa_sales")
+	t.Setenv("DBA_SECRET_SALES_ADMIN_PASSWORD", "pw")
+	credentials, err := EnvProvider{}.Lookup(context.Background(), "SALES_ADMIN")
+	if err != nil || credentials != (Credentials{Username: "sa_sales", Password: "pw"}) {
+		t.Fatalf("Lookup = %+v, %v", credentials, err)
+	}
+	if _, err := (EnvProvider{}).Lookup(context.Background(), "MISSING_ADMIN"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup of a missing name = %v, want %v", err, ErrNotFound)
+	}
+}
+
+// Only variables with the prefix can be read, whatever an admin puts in the credential reference.
+func TestEnvProviderOnlyReadsPrefixedVariables(t *testing.T) {
+	t.Setenv("DB_USER", "dba")
+	t.Setenv("DB_PASSWORD", "central-password")
+	t.Setenv("SALES_ADMIN_USER", "sa_sales")
+	t.Setenv("SALES_ADMIN_PASSWORD", "pw")
+	for _, name := range []string{"DB", "SALES_ADMIN"} {
+		if credentials, err := (EnvProvider{}).Lookup(context.Background(), name); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Lookup(%q) = %+v, %v, want %v", name, credentials, err, ErrNotFound)
+		}
+	}
+	for _, name := range []string{"", "sales_admin", "_SALES", "SALES.ADMIN", "SALES/ADMIN", "SALES-ADMIN", "A" + strings.Repeat("B", 64)} {
+		if _, err := (EnvProvider{}).Lookup(context.Background(), name); !errors.Is(err, ErrInvalidRef) {
+			t.Errorf("Lookup(%q) = %v, want %v", name, err, ErrInvalidRef)
+		}
+	}
+}
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestFileProvider(t *testing.T) {
+	key := newKey(t)
+	sealed, err := SealFile(key, []byte(`{"sales-admin": {"username": "sa_sales", "password": "p'w;d"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	if err := os.WriteFile(path, sealed, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &FileProvider{Path: path, Key: key}
+	credentials, err := provider.Lookup(context.Background(), "sales-admin")
+	if err != nil || credentials != (Credentials{Username: "sa_sales", Password: "p'w;d"}) {
+		t.Fatalf("Lookup = %+v, %v", credentials, err)
+	}
+	if _, err := provider.Lookup(context.Background(), "other"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup of a missing name = %v, want %v", err, ErrNotFound)
+	}
+
+	wrongKey := &FileProvider{Path: path, Key: newKey(t)}
+	if _, err := wrongKey.Lookup(context.Background(), "sales-admin"); err == nil {
+		t.Fatal("Lookup with the wrong key must fail")
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := OpenFile(key, sealed); err == nil {
+		t.Fatal("OpenFile must reject a modified file")
+	}
+}
+
+func TestVaultProvider(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		if r.Header.Get("X-Vault-Namespace") != "dba" {
+			t.Errorf("namespace header is %q", r.Header.Get("X-Vault-Namespace"))
+		}
+		switch r.URL.EscapedPath() {
+		case "/v1/kv/data/sql/sales%20admin":
+			w.Write([]byte(`{"data": {"data": {"username": "sa_sales", "password": "pw"}, "metadata": {"version": 3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors": []}`))
+		}
+	}))
+	defer vault.Close()
+
+	provider := NewVaultProvider(vault.URL+"/", "root", "/kv/", "dba")
+	credentials, err := provider.Lookup(context.Background(), "sql/sales admin")
+	if err != nil || credentials != (Credentials{Username: "sa_sales", Password: "pw"}) {
+		t.Fatalf("Lookup = %+v, %v", credentials, err)
+	}
+	if _, err := provider.Lookup(context.Background(), "sql/missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup of a missing secret = %v, want %v", err, ErrNotFound)
+	}
+
+	denied := NewVaultProvider(vault.URL, "wrong", "kv", "dba")
+	if _, err := denied.Lookup(context.Background(), "sql/sales admin"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("Lookup with a rejected token = %v, want a permission error", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	resolver := newResolver(func(c *config.SecretsConfig) { c.Provider = "test" })
+	resolver.Register("test", providerFunc(func(ctx context.Context, name string) (Credentials, error) {
+		if name == "empty" {
+			return Credentials{Username: "sa"}, nil
+		}
+		return Credentials{Username: "sa", Password: name}, nil
+	}))
+
+	credentials, err := resolver.Resolve(context.Background(), "sales")
+	if err != nil || credentials.Password != "sales" {
+		t.Fatalf("Resolve = %+v, %v", credentials, err)
+	}
+	if _, err := resolver.Resolve(context.Background(), "empty"); err == nil {
+		t.Fatal("Resolve must reject credentials without a password")
+	}
+	if _, err := resolver.Resolve(context.Background(), "vault:sales"); !errors.Is(err, ErrProviderUnavailable) {
+		t.Fatalf("Resolve without Vault configured = %v, want %v", err, ErrProviderUnavailable)
+	}
+}
+
+type providerFunc func(ctx context.Context, name string) (Credentials, error)
+
+func (f providerFunc) Lookup(ctx context.Context, name string) (Credentials, error) {
+	return f(ctx, name)
+}
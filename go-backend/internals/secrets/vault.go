@@ -0,0 +1,79 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const defaultVaultMount = "secret"
+
+// VaultProvider reads credentials from the KV version 2 secrets engine of HashiCorp Vault. The secret
+// at <mount>/<name> must have the keys username and password.
+type VaultProvider struct {
+	Addr      string
+	Token     string
+	Mount     string
+	Namespace string
+	Client    *http.Client
+}
+
+func NewVaultProvider(addr, token, mount, namespace string) *VaultProvider {
+	if mount == "" {
+		mount = defaultVaultMount
+	}
+	return &VaultProvider{
+		Addr:      strings.TrimRight(addr, "/"),
+		Token:     token,
+		Mount:     strings.Trim(mount, "/"),
+		Namespace: namespace,
+		Client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *VaultProvider) Lookup(ctx context.Context, name string) (Credentials, error) {
+	segments := strings.Split(name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		p.Addr+"/v1/"+p.Mount+"/data/"+strings.Join(segments, "/"), nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("X-Vault-Token", p.Token)
+	if p.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.Namespace)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return Credentials{}, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	var secret struct {
+		Errors []string `json:"errors"`
+		Data   struct {
+			Data Credentials `json:"data"`
+		} `json:"data"`
+	}
+	// the body of a 404 is empty or lists no errors, both mean the secret does not exist
+	_ = json.Unmarshal(body, &secret)
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return Credentials{}, ErrNotFound
+	case resp.StatusCode != http.StatusOK:
+		return Credentials{}, fmt.Errorf("vault answered %s: %s", resp.Status, strings.Join(secret.Errors, "; "))
+	}
+	return secret.Data.Data, nil
+}
@@ -0,0 +1,548 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go-backend/internals/config"
+	"go-backend/internals/engine"
+	"go-backend/internals/events"
+	"go-backend/internals/pkg"
+	"go-backend/internals/topology"
+	"go-backend/models"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	GrantStatusGranting     = "Granting"
+	GrantStatusActive       = "Active"
+	GrantStatusGrantFailed  = "Grant Failed"
+	GrantStatusRevokeFailed = "Revoke Failed"
+	GrantStatusRevoked      = "Revoked"
+)
+
+const (
+	revokeRetryBase = 30 * time.Second
+	revokeRetryMax  = 15 * time.Minute
+)
+
+var (
+	ErrInvalidTemporaryRole         = errors.New("role cannot be granted temporarily")
+	ErrInvalidDuration              = errors.New("invalid access duration")
+	ErrTemporaryGrantFailed         = errors.New("granting temporary access failed, any partial grants will be revoked")
+	ErrTemporaryGrantNotFound       = errors.New("temporary access request not found")
+	ErrTemporaryRequestNotPending   = errors.New("temporary access request is not pending")
+	ErrTemporaryRequestSelfApproval = errors.New("approver cannot decide their own temporary access request")
+	ErrTemporaryAccessEngine        = errors.New("temporary access can only be granted on SQL Server")
+)
+
+// roles that may be requested for a time-boxed window
+var temporaryRoles = map[string]bool{
+	"db_datareader": true,
+	"db_datawriter": true,
+}
+
+// MaxTemporaryAccessDuration is the longest window that can be requested, grants.max_duration_hours.
+func MaxTemporaryAccessDuration(c config.GrantsConfig) time.Duration {
+	return time.Duration(c.MaxDurationHours) * time.Hour
+}
+
+// RequestTemporaryAccess records a request for a time-boxed role. Nothing is granted until an admin
+// approves it, see ApproveTemporaryAccess.
+func RequestTemporaryAccess(db *sql.DB, broker *events.Broker, c config.GrantsConfig, req models.TemporaryAccessRequest) (int, error) {
+	if !temporaryRoles[req.Role] {
+		return 0, ErrInvalidTemporaryRole
+	}
+	duration := time.Duration(req.DurationHours) * time.Hour
+	if duration <= 0 || duration > MaxTemporaryAccessDuration(c) {
+		return 0, ErrInvalidDuration
+	}
+
+	var requestID int
+	err := db.QueryRow(`INSERT INTO temporary_access_requests
+		(username, email, serverIP, database_name, role_name, duration_seconds, request_status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)
+		RETURNING id`,
+		req.Username, req.Email, req.ServerIP, req.Database, req.Role, int64(duration.Seconds()), StatusPending).Scan(&requestID)
+	if err != nil {
+		return 0, err
+	}
+	pkg.LogPasswordUpdate(db, broker, "", req.Username, req.ServerIP, "Temporary Access", StatusPending,
+		fmt.Sprintf("Request %d for %s on %s for %d hours", requestID, req.Role, req.Database, req.DurationHours))
+	return requestID, nil
+}
+
+// RejectTemporaryAccess closes a pending request without granting anything.
+func RejectTemporaryAccess(db *sql.DB, broker *events.Broker, requestID int, approver, comment string) error {
+	req, _, _, err := decideTemporaryAccess(db, requestID, approver, StatusRejected, comment, nil)
+	if err != nil {
+		return err
+	}
+	pkg.LogPasswordUpdate(db, broker, "", req.Username, req.ServerIP, "Temporary Access", StatusRejected,
+		fmt.Sprintf("Request %d rejected by %s", requestID, approver))
+	return nil
+}
+
+// ApproveTemporaryAccess approves a pending request and grants the requested role on the target instance
+// and every replica of it. A grant row is persisted per server together with the approval, before anything
+// touches SQL Server, so a crash half way through still leaves the revoker enough information to clean up.
+// The server and its replicas are looked up through gateway and replicas like a password update does, the
+// servers are reached through connector, each within the server timeout of c, and every step is published
+// through broker.
+func ApproveTemporaryAccess(db *sql.DB, broker *events.Broker, gateway Gateway, replicas ReplicaSource, connector *engine.Connector, c config.GrantsConfig, requestID int, approver, comment string) (time.Time, error) {
+	var serverIP string
+	err := db.QueryRow("SELECT serverIP FROM temporary_access_requests WHERE id = $1", requestID).Scan(&serverIP)
+	if err == sql.ErrNoRows {
+		return time.Time{}, ErrTemporaryGrantNotFound
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.ServerTimeout)
+	servers, err := temporaryAccessTargets(ctx, gateway, replicas, serverIP)
+	cancel()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	req, expiresAt, grantIDs, err := decideTemporaryAccess(db, requestID, approver, StatusApproved, comment, servers)
+	if err != nil {
+		return expiresAt, err
+	}
+	pkg.LogPasswordUpdate(db, broker, "", req.Username, req.ServerIP, "Temporary Access", StatusApproved,
+		fmt.Sprintf("Request %d approved by %s", requestID, approver))
+
+	failed := false
+	for _, server := range servers {
+		alreadyMember, err := grantOnServer(db, connector, c, server, req)
+		if err != nil {
+			failed = true
+			log.Error().Err(err).Msgf("Failed to grant %s on %s to %s on server %s", req.Role, req.Database, req.Username, server)
+			updateGrant(db, grantIDs[server], GrantStatusGrantFailed, err.Error(), false)
+			pkg.LogPasswordUpdate(db, broker, "", req.Username, server, "Temporary Access", GrantStatusGrantFailed, err.Error())
+			continue
+		}
+		updateGrant(db, grantIDs[server], GrantStatusActive, "", alreadyMember)
+		message := fmt.Sprintf("Granted %s on %s until %s", req.Role, req.Database, expiresAt.Format(time.RFC3339))
+		if alreadyMember {
+			message = fmt.Sprintf("%s on %s was already held, it is kept after %s", req.Role, req.Database, expiresAt.Format(time.RFC3339))
+		}
+		pkg.LogPasswordUpdate(db, broker, "", req.Username, server, "Temporary Access", GrantStatusActive, message)
+	}
+
+	if failed {
+		// expire the whole request so the revoker removes whatever was granted
+		if err := ExpireTemporaryAccess(db, requestID); err != nil {
+			log.Error().Err(err).Msgf("Failed to expire temporary access request %d", requestID)
+		}
+		return expiresAt, ErrTemporaryGrantFailed
+	}
+	return expiresAt, nil
+}
+
+// temporaryAccessTargets returns the hosts a temporary grant on the server is made on: the server itself and
+// the replicas of its topology, with the pinned replicas added and the excluded ones left out. A server or
+// replica the inventory cannot resolve is refused before anything is granted.
+func temporaryAccessTargets(ctx context.Context, gateway Gateway, replicas ReplicaSource, serverIP string) ([]string, error) {
+	eng, server, err := gateway.ResolveServer(ctx, serverIP)
+	if err != nil {
+		return nil, err
+	}
+	if server.Engine != engine.MSSQL {
+		return nil, fmt.Errorf("%s: %w", server.Name, ErrTemporaryAccessEngine)
+	}
+	serverTopology, err := replicas.Replicas(ctx, eng, server)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the replicas of %s: %w", server.Name, err)
+	}
+	serverReplicas, err := gateway.ResolveReplicas(server, topology.Targets(serverTopology))
+	if err != nil {
+		return nil, err
+	}
+	hosts := []string{server.Host}
+	for _, replica := range serverReplicas {
+		if !containsFold(hosts, replica.Host) {
+			hosts = append(hosts, replica.Host)
+		}
+	}
+	return hosts, nil
+}
+
+// decideTemporaryAccess moves a pending request to Approved or Rejected. The window of an approved request
+// starts with the approval, and a grant row is added for each of the servers.
+func decideTemporaryAccess(db *sql.DB, requestID int, approver, decision, comment string, servers []string) (models.TemporaryAccessRequest, time.Time, map[string]int, error) {
+	var req models.TemporaryAccessRequest
+	var expiresAt time.Time
+	tx, err := db.Begin()
+	if err != nil {
+		return req, expiresAt, nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRow(`SELECT username, email, serverIP, database_name, role_name, request_status
+		FROM temporary_access_requests WHERE id = $1 FOR UPDATE`, requestID).
+		Scan(&req.Username, &req.Email, &req.ServerIP, &req.Database, &req.Role, &status)
+	if err == sql.ErrNoRows {
+		return req, expiresAt, nil, ErrTemporaryGrantNotFound
+	}
+	if err != nil {
+		return req, expiresAt, nil, err
+	}
+	if status != StatusPending {
+		return req, expiresAt, nil, ErrTemporaryRequestNotPending
+	}
+	if strings.EqualFold(approver, req.Username) {
+		return req, expiresAt, nil, ErrTemporaryRequestSelfApproval
+	}
+
+	var expires pq.NullTime
+	err = tx.QueryRow(`UPDATE temporary_access_requests SET request_status = $2, reviewed_by = $3, message = NULLIF($4, ''),
+		reviewed_at = CURRENT_TIMESTAMP,
+		expires_at = CASE WHEN $2 = 'Approved' THEN CURRENT_TIMESTAMP + duration_seconds * INTERVAL '1 second' END
+		WHERE id = $1
+		RETURNING expires_at`, requestID, decision, approver, comment).Scan(&expires)
+	if err != nil {
+		return req, expiresAt, nil, err
+	}
+	expiresAt = expires.Time
+
+	grantIDs := make(map[string]int, len(servers))
+	for _, server := range servers {
+		var grantID int
+		err = tx.QueryRow("INSERT INTO temporary_grants (request_id, server, grant_status) VALUES ($1, $2, $3) RETURNING id",
+			requestID, server, GrantStatusGranting).Scan(&grantID)
+		if err != nil {
+			return req, expiresAt, nil, err
+		}
+		grantIDs[server] = grantID
+	}
+	return req, expiresAt, grantIDs, tx.Commit()
+}
+
+// ExpireTemporaryAccess ends a temporary access window immediately; the revoker picks it up on its next run.
+func ExpireTemporaryAccess(db *sql.DB, requestID int) error {
+	res, err := db.Exec("UPDATE temporary_access_requests SET expires_at = CURRENT_TIMESTAMP WHERE id = $1 AND expires_at > CURRENT_TIMESTAMP", requestID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		var exists bool
+		if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM temporary_access_requests WHERE id = $1)", requestID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return ErrTemporaryGrantNotFound
+		}
+	}
+	_, err = db.Exec("UPDATE temporary_grants SET next_attempt_at = CURRENT_TIMESTAMP WHERE request_id = $1 AND grant_status <> $2",
+		requestID, GrantStatusRevoked)
+	return err
+}
+
+// StartGrantRevoker revokes expired temporary grants every revoke interval of c until ctx is cancelled.
+// Rows are claimed with SKIP LOCKED so several backend instances can run it side by side.
+func StartGrantRevoker(ctx context.Context, db *sql.DB, broker *events.Broker, connector *engine.Connector, c config.GrantsConfig) {
+	go func() {
+		log.Info().Msgf("Temporary grant revoker started, checking every %s", c.RevokeInterval)
+		ticker := time.NewTicker(c.RevokeInterval)
+		defer ticker.Stop()
+		for {
+			RevokeExpiredGrants(db, broker, connector, c)
+			select {
+			case <-ctx.Done():
+				log.Info().Msg("Temporary grant revoker stopped")
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// RevokeExpiredGrants revokes every expired grant that is due for an attempt.
+func RevokeExpiredGrants(db *sql.DB, broker *events.Broker, connector *engine.Connector, c config.GrantsConfig) {
+	for {
+		processed, err := revokeNextExpiredGrant(db, broker, connector, c)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to process expired temporary grants")
+			return
+		}
+		if !processed {
+			return
+		}
+	}
+}
+
+// expiredGrant is a grant the revoker claimed
+type expiredGrant struct {
+	id            int
+	attempts      int
+	server        string
+	username      string
+	database      string
+	role          string
+	alreadyMember bool
+}
+
+func revokeNextExpiredGrant(db *sql.DB, broker *events.Broker, connector *engine.Connector, c config.GrantsConfig) (bool, error) {
+	// connecting and revoking are both bounded by the server timeout
+	grant, found, err := claimExpiredGrant(db, 2*c.ServerTimeout)
+	if err != nil || !found {
+		return false, err
+	}
+
+	// the role stays when the login held it before the grant, or when another grant still needs it
+	if grant.alreadyMember {
+		return true, keepRole(db, broker, grant, "it was held before the grant")
+	}
+	handedOver, err := handOverRole(db, grant)
+	if err != nil {
+		return false, err
+	}
+	if handedOver {
+		return true, keepRole(db, broker, grant, "another temporary grant still needs it")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.ServerTimeout)
+	revokeErr := execOnServer(ctx, db, connector, grant.server, "EXEC dbo.RevokeDatabaseAccess @LoginName=?, @DatabaseName=?, @RoleName=?",
+		grant.username, grant.database, grant.role)
+	cancel()
+	if revokeErr == nil {
+		_, err = db.Exec(`UPDATE temporary_grants SET grant_status = $2, revoked_at = CURRENT_TIMESTAMP, last_error = NULL
+			WHERE id = $1`, grant.id, GrantStatusRevoked)
+		if err != nil {
+			return false, err
+		}
+		log.Info().Msgf("Revoked %s on %s from %s on server %s", grant.role, grant.database, grant.username, grant.server)
+		pkg.LogPasswordUpdate(db, broker, "", grant.username, grant.server, "Temporary Access", GrantStatusRevoked,
+			fmt.Sprintf("Revoked %s on %s", grant.role, grant.database))
+		return true, nil
+	}
+
+	attempts := grant.attempts + 1
+	retryIn := revokeBackoff(attempts)
+	_, err = db.Exec(`UPDATE temporary_grants SET grant_status = $2, revoke_attempts = $3, last_error = $4,
+		next_attempt_at = CURRENT_TIMESTAMP + $5 * INTERVAL '1 second'
+		WHERE id = $1`, grant.id, GrantStatusRevokeFailed, attempts, revokeErr.Error(), int64(retryIn.Seconds()))
+	if err != nil {
+		return false, err
+	}
+	log.Error().Err(revokeErr).Msgf("Attempt %d to revoke %s on %s from %s on server %s failed, retrying in %s",
+		attempts, grant.role, grant.database, grant.username, grant.server, retryIn)
+	pkg.LogPasswordUpdate(db, broker, "", grant.username, grant.server, "Temporary Access", GrantStatusRevokeFailed,
+		fmt.Sprintf("Attempt %d to revoke %s on %s failed: %v", attempts, grant.role, grant.database, revokeErr))
+	return true, nil
+}
+
+// claimExpiredGrant picks the next expired grant that is due and moves its next attempt past lease, the time
+// a revoke may take. The claim is committed before SQL Server is called, so no transaction stays open
+// meanwhile and other revokers skip the grant.
+func claimExpiredGrant(db *sql.DB, lease time.Duration) (expiredGrant, bool, error) {
+	var grant expiredGrant
+	tx, err := db.Begin()
+	if err != nil {
+		return grant, false, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(`SELECT g.id, g.server, g.revoke_attempts, g.already_member, r.username, r.database_name, r.role_name
+		FROM temporary_grants g
+		JOIN temporary_access_requests r ON r.id = g.request_id
+		WHERE g.grant_status <> $1
+		AND r.expires_at <= CURRENT_TIMESTAMP
+		AND g.next_attempt_at <= CURRENT_TIMESTAMP
+		ORDER BY r.expires_at
+		LIMIT 1
+		FOR UPDATE OF g SKIP LOCKED`, GrantStatusRevoked).
+		Scan(&grant.id, &grant.server, &grant.attempts, &grant.alreadyMember, &grant.username, &grant.database, &grant.role)
+	if err == sql.ErrNoRows {
+		return grant, false, nil
+	}
+	if err != nil {
+		return grant, false, err
+	}
+
+	_, err = tx.Exec("UPDATE temporary_grants SET next_attempt_at = CURRENT_TIMESTAMP + $2 * INTERVAL '1 second' WHERE id = $1",
+		grant.id, int64(lease.Seconds()))
+	if err != nil {
+		return grant, false, err
+	}
+	return grant, true, tx.Commit()
+}
+
+// handOverRole passes the role to the active grants of the same login, database and role on the server whose
+// window is still open. They found the role already held when they were granted, so without the hand over
+// nobody would remove it.
+func handOverRole(db *sql.DB, grant expiredGrant) (bool, error) {
+	res, err := db.Exec(`UPDATE temporary_grants g SET already_member = FALSE
+		FROM temporary_access_requests r
+		WHERE r.id = g.request_id
+		AND g.id <> $1
+		AND LOWER(g.server) = LOWER($2)
+		AND r.username = $3 AND r.database_name = $4 AND r.role_name = $5
+		AND g.grant_status = $6
+		AND r.expires_at > CURRENT_TIMESTAMP`,
+		grant.id, grant.server, grant.username, grant.database, grant.role, GrantStatusActive)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// keepRole ends a grant without removing the role from the login.
+func keepRole(db *sql.DB, broker *events.Broker, grant expiredGrant, reason string) error {
+	_, err := db.Exec(`UPDATE temporary_grants SET grant_status = $2, revoked_at = CURRENT_TIMESTAMP, last_error = NULL
+		WHERE id = $1`, grant.id, GrantStatusRevoked)
+	if err != nil {
+		return err
+	}
+	log.Info().Msgf("Kept %s on %s for %s on server %s, %s", grant.role, grant.database, grant.username, grant.server, reason)
+	pkg.LogPasswordUpdate(db, broker, "", grant.username, grant.server, "Temporary Access", GrantStatusRevoked,
+		fmt.Sprintf("Kept %s on %s, %s", grant.role, grant.database, reason))
+	return nil
+}
+
+func revokeBackoff(attempts int) time.Duration {
+	backoff := revokeRetryBase
+	for i := 1; i < attempts && backoff < revokeRetryMax; i++ {
+		backoff *= 2
+	}
+	if backoff > revokeRetryMax {
+		backoff = revokeRetryMax
+	}
+	return backoff
+}
+
+// roleMemberQuery reports whether a login is a member of a role in a database. Databases the admin cannot
+// open, e.g. on a secondary that is not readable, report no membership.
+const roleMemberQuery = `DECLARE @LoginName SYSNAME = ?, @DatabaseName SYSNAME = ?, @RoleName SYSNAME = ?, @Member BIT = 0;
+IF HAS_DBACCESS(@DatabaseName) = 1
+BEGIN
+	DECLARE @SQL NVARCHAR(MAX) = N'USE ' + QUOTENAME(@DatabaseName) + N';
+		SELECT @Member = CASE WHEN EXISTS (
+			SELECT 1 FROM sys.database_role_members rm
+			JOIN sys.database_principals r ON r.principal_id = rm.role_principal_id
+			JOIN sys.database_principals m ON m.principal_id = rm.member_principal_id
+			WHERE r.name = @Role AND m.sid = SUSER_SID(@Login)) THEN 1 ELSE 0 END;';
+	EXEC sp_executesql @SQL, N'@Login SYSNAME, @Role SYSNAME, @Member BIT OUTPUT',
+		@Login = @LoginName, @Role = @RoleName, @Member = @Member OUTPUT;
+END
+SELECT @Member;`
+
+// grantOnServer grants the requested role on one server unless the login already holds it there,
+// it reports whether the login did.
+func grantOnServer(db *sql.DB, connector *engine.Connector, c config.GrantsConfig, server string, req models.TemporaryAccessRequest) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.ServerTimeout)
+	defer cancel()
+	conn, err := connector.ConnectMSSQLAdmin(ctx, db, server)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var alreadyMember bool
+	if err := conn.QueryRowContext(ctx, roleMemberQuery, req.Username, req.Database, req.Role).Scan(&alreadyMember); err != nil {
+		return false, err
+	}
+	if alreadyMember {
+		return true, nil
+	}
+	_, err = conn.ExecContext(ctx, "EXEC dbo.GrantDatabaseAccess @LoginName=?, @DatabaseName=?, @RoleName=?", req.Username, req.Database, req.Role)
+	return false, err
+}
+
+func execOnServer(ctx context.Context, db *sql.DB, connector *engine.Connector, server, query string, args ...interface{}) error {
+	conn, err := connector.ConnectMSSQLAdmin(ctx, db, server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, query, args...)
+	return err
+}
+
+func updateGrant(db *sql.DB, grantID int, status, lastError string, alreadyMember bool) {
+	_, err := db.Exec(`UPDATE temporary_grants SET grant_status = $2, last_error = NULLIF($3, ''), already_member = $4,
+		granted_at = CASE WHEN $2 = 'Active' THEN CURRENT_TIMESTAMP ELSE granted_at END
+		WHERE id = $1`, grantID, status, lastError, alreadyMember)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to update temporary grant %d", grantID)
+	}
+}
+
+func GetAllTemporaryAccessRequests(db *sql.DB) ([]models.TemporaryAccessRecord, error) {
+	rows, err := db.Query("SELECT * FROM get_all_temporary_access_requests()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []models.TemporaryAccessRecord{}
+	for rows.Next() {
+		var request models.TemporaryAccessRecord
+		var durationSeconds sql.NullInt64
+		var reviewedBy, message sql.NullString
+		var createdAt, reviewedAt, expiresAt pq.NullTime
+		if err := rows.Scan(&request.RequestID, &request.Username, &request.ServerIP, &request.Database, &request.Role,
+			&durationSeconds, &request.RequestStatus, &reviewedBy, &message, &createdAt, &reviewedAt, &expiresAt); err != nil {
+			return nil, err
+		}
+		request.DurationHours = int(durationSeconds.Int64 / 3600)
+		request.ReviewedBy = reviewedBy.String
+		request.Message = message.String
+		request.RequestTime = formatTime(createdAt)
+		request.ReviewTime = formatTime(reviewedAt)
+		request.ExpiresAt = formatTime(expiresAt)
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+func GetAllTemporaryGrants(db *sql.DB) ([]models.TemporaryGrant, error) {
+	rows, err := db.Query("SELECT * FROM get_all_temporary_grants()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	grants := []models.TemporaryGrant{}
+	for rows.Next() {
+		var grant models.TemporaryGrant
+		var grantedAt, expiresAt, revokedAt pq.NullTime
+		var lastError sql.NullString
+		if err := rows.Scan(&grant.GrantID, &grant.RequestID, &grant.Username, &grant.Database, &grant.Role, &grant.Server,
+			&grant.GrantStatus, &grant.AlreadyMember, &grantedAt, &expiresAt, &revokedAt, &grant.RevokeAttempts, &lastError); err != nil {
+			return nil, err
+		}
+		grant.GrantedAt = formatTime(grantedAt)
+		grant.ExpiresAt = formatTime(expiresAt)
+		grant.RevokedAt = formatTime(revokedAt)
+		grant.LastError = lastError.String
+		grants = append(grants, grant)
+	}
+	return grants, rows.Err()
+}
+
+func formatTime(t pq.NullTime) string {
+	if t.Valid {
+		return t.Time.Format("2006-01-02 15:04:05")
+	}
+	return ""
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	_ "unsafe"
+
+	"go-backend/internals/engine"
+	"go-backend/internals/pkg"
+	"go-backend/models"
+
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+// mssqlParseParams is the parser the mssql driver runs over every query to count its parameters.
+//
+//go:linkname mssqlParseParams github.com/microsoft/go-mssqldb/internal/querytext.ParseParams
+func mssqlParseParams(query string) (string, int)
+
+func TestRoleMemberQueryParameters(t *testing.T) {
+	// login, database and role
+	if _, got := mssqlParseParams(roleMemberQuery); got != 3 {
+		t.Errorf("the mssql driver counts %d parameters in the role member query, want 3", got)
+	}
+}
+
+// inventoryGateway resolves the enabled servers of the inventory by host, it has no engines.
+type inventoryGateway struct {
+	Gateway
+	servers map[string]engine.Server
+}
+
+func (g inventoryGateway) ResolveServer(ctx context.Context, name string) (engine.Engine, engine.Server, error) {
+	server, ok := g.servers[name]
+	if !ok {
+		return nil, engine.Server{}, pkg.ErrUnknownServer
+	}
+	return nil, server, nil
+}
+
+func (g inventoryGateway) ResolveReplicas(primary engine.Server, hosts []string) ([]engine.Server, error) {
+	var replicas []engine.Server
+	for _, host := range hosts {
+		replica, ok := g.servers[host]
+		if !ok {
+			return nil, pkg.ErrUnknownServer
+		}
+		replicas = append(replicas, replica)
+	}
+	return replicas, nil
+}
+
+// staticTopology returns the same replicas for every server.
+type staticTopology []models.TopologyNode
+
+func (t staticTopology) Replicas(ctx context.Context, eng engine.Engine, server engine.Server) (models.ServerTopology, error) {
+	return models.ServerTopology{Server: server.Name, Host: server.Host, Replicas: t}, nil
+}
+
+func TestTemporaryAccessTargets(t *testing.T) {
+	gateway := inventoryGateway{servers: map[string]engine.Server{
+		"10.0.0.11":   {Name: "sales-db-01", Host: "10.0.0.11", Engine: engine.MSSQL},
+		"10.0.0.12":   {Name: "sales-db-02", Host: "10.0.0.12", Engine: engine.MSSQL},
+		"10.0.0.13":   {Name: "sales-dr", Host: "10.0.0.13", Engine: engine.MSSQL},
+		"sales-db-01": {Name: "sales-db-01", Host: "10.0.0.11", Engine: engine.MSSQL},
+		"billing-pg":  {Name: "billing-pg", Host: "10.0.1.5", Engine: engine.Postgres},
+	}}
+	// 10.0.0.99 is reported by the availability group but excluded and not in the inventory
+	replicas := staticTopology{
+		{Host: "10.0.0.12", Source: "discovered"},
+		{Host: "10.0.0.99", Source: "discovered", Excluded: true},
+		{Host: "10.0.0.13", Source: "pinned"},
+	}
+
+	hosts, err := temporaryAccessTargets(context.Background(), gateway, replicas, "sales-db-01")
+	if err != nil {
+		t.Fatalf("temporaryAccessTargets: %v", err)
+	}
+	if want := []string{"10.0.0.11", "10.0.0.12", "10.0.0.13"}; !reflect.DeepEqual(hosts, want) {
+		t.Errorf("targets = %v, want %v", hosts, want)
+	}
+
+	unlisted := append(staticTopology{{Host: "10.0.0.98", Source: "discovered"}}, replicas...)
+	if _, err := temporaryAccessTargets(context.Background(), gateway, unlisted, "sales-db-01"); !errors.Is(err, pkg.ErrUnknownServer) {
+		t.Errorf("a replica that is not in the inventory = %v, want ErrUnknownServer", err)
+	}
+	if _, err := temporaryAccessTargets(context.Background(), gateway, replicas, "billing-pg"); !errors.Is(err, ErrTemporaryAccessEngine) {
+		t.Errorf("a PostgreSQL server = %v, want ErrTemporaryAccessEngine", err)
+	}
+}
@@ -0,0 +1,448 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go-backend/internals/config"
+	"go-backend/internals/engine"
+	"go-backend/internals/events"
+	"go-backend/internals/pkg"
+	"go-backend/internals/topology"
+	"go-backend/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	JobStatusQueued    = "Queued"
+	JobStatusRunning   = "Running"
+	JobStatusSucceeded = "Succeeded"
+	JobStatusFailed    = "Failed"
+)
+
+const (
+	StepPending        = "Pending"
+	StepRunning        = "Running"
+	StepSucceeded      = "Succeeded"
+	StepFailed         = "Failed"
+	StepSkipped        = "Skipped"
+	StepRolledBack     = "Rolled Back"
+	StepRollbackFailed = "Rollback Failed"
+)
+
+const (
+	stepValidation   = "Validate credentials"
+	stepExpiryCheck  = "Check login expiry"
+	stepOldPassword  = "Check old password"
+	stepFindReplicas = "Find replicas"
+	stepEmail        = "Send confirmation email"
+)
+
+// step_order of the fixed steps, the per-server steps are numbered in between
+var jobStepOrder = map[string]int{
+	stepValidation:   1,
+	stepExpiryCheck:  2,
+	stepOldPassword:  3,
+	stepFindReplicas: 4,
+	stepEmail:        10000,
+}
+
+// running jobs that have not finished after this long were cut off by a crash or restart
+const staleJobAfter = time.Hour
+
+var ErrJobNotFound = errors.New("job not found")
+
+// PasswordJobStore persists the password job queue and the progress of every job.
+type PasswordJobStore interface {
+	InsertPasswordJob(job QueuedPasswordJob, steps []JobStep) error
+	// ClaimPasswordJob marks the oldest queued job as running, false means the queue is empty
+	ClaimPasswordJob() (QueuedPasswordJob, bool, error)
+	GetPasswordJob(jobID string) (models.PasswordJob, error)
+	FailStalePasswordJobs(startedBefore time.Time, message string) error
+	AddPasswordJobSteps(jobID string, steps []JobStep) error
+	SetPasswordJobStep(jobID, step, status, message string) error
+	SkipPendingPasswordJobSteps(jobID string) error
+	// FinishPasswordJob records the final state and drops the encrypted passwords
+	FinishPasswordJob(jobID, status, message string) error
+}
+
+// AuditLog keeps the pass_reset_logs row of a request up to date.
+type AuditLog interface {
+	AttemptLog
+	LogStatus(requestID, requestType, requestStatus, message string)
+}
+
+// LoginFailures counts failed password checks towards the lockout of a login.
+type LoginFailures interface {
+	RecordLoginFailure(requestID, scope, username, clientIP, serverIP string)
+	// ClaimLoginAttempt checks the lockout and counts the attempt as a failure in one statement,
+	// ReleaseLoginAttempt gives the claim back once the attempt succeeded
+	ClaimLoginAttempt(requestID, scope, username, clientIP, serverIP string) (time.Duration, error)
+	ReleaseLoginAttempt(scope, username, clientIP string)
+}
+
+// ReplicaSource returns the replicas of a server with the admin overrides applied.
+type ReplicaSource interface {
+	Replicas(ctx context.Context, eng engine.Engine, server engine.Server) (models.ServerTopology, error)
+}
+
+// Gateway reaches the servers of the inventory and the login catalog on the central SQL Server.
+type Gateway interface {
+	ResolveServer(ctx context.Context, name string) (engine.Engine, engine.Server, error)
+	ResolveReplicas(primary engine.Server, hosts []string) ([]engine.Server, error)
+	// ValidateLoginOwner checks that the email owns the login on the server in login_email_mapping
+	ValidateLoginOwner(ctx context.Context, username, serverIP, email string) (bool, error)
+	// ValidateAccessRequestLogin checks that the login is registered for self service
+	ValidateAccessRequestLogin(ctx context.Context, username string) (bool, error)
+}
+
+type Mailer interface {
+	SendConfirmation(to, username string) error
+}
+
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the wall clock.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now() }
+
+// QueuedPasswordJob is a password update as stored in the queue, the passwords only in the encrypted payload.
+type QueuedPasswordJob struct {
+	ID        string
+	RequestID string
+	ClientIP  string
+	Request   models.UpdatePasswordRequest
+	Payload   []byte
+}
+
+type JobStep struct {
+	Order int
+	Name  string
+}
+
+type jobPayload struct {
+	OldPassword string `json:"oldPassword"`
+	NewPassword string `json:"newPassword"`
+}
+
+type passwordJob struct {
+	id        string
+	requestID string
+	clientIP  string
+	request   models.UpdatePasswordRequest
+}
+
+func serverStep(server string) string {
+	return "Update password on " + server
+}
+
+// EnqueuePasswordJob queues a password update and returns its job ID. The passwords are stored encrypted
+// with the job encryption key of c.
+func EnqueuePasswordJob(jobs PasswordJobStore, c config.JobsConfig, requestID, clientIP string, req models.UpdatePasswordRequest) (string, error) {
+	plaintext, err := json.Marshal(jobPayload{OldPassword: req.OldPassword, NewPassword: req.NewPassword})
+	if err != nil {
+		return "", err
+	}
+	payload, err := pkg.EncryptPayload(c, plaintext)
+	if err != nil {
+		return "", err
+	}
+	// the job ID is all a user needs to follow the job, so it has to be unguessable
+	jobID := pkg.NewRequestID()
+	if jobID == "" {
+		return "", errors.New("failed to generate job ID")
+	}
+
+	req.OldPassword, req.NewPassword = "", ""
+	var steps []JobStep
+	for _, step := range []string{stepValidation, stepExpiryCheck, stepOldPassword, stepFindReplicas, stepEmail} {
+		steps = append(steps, JobStep{Order: jobStepOrder[step], Name: step})
+	}
+	job := QueuedPasswordJob{ID: jobID, RequestID: requestID, ClientIP: clientIP, Request: req, Payload: payload}
+	if err := jobs.InsertPasswordJob(job, steps); err != nil {
+		return "", err
+	}
+	return jobID, nil
+}
+
+// PasswordJobs runs the queued password updates: it checks the login on the server, updates the primary
+// and its replicas and mails the owner, recording every step in Jobs. Config holds the job encryption key
+// and how the password is synced to the replicas, Events streams the progress of every job.
+type PasswordJobs struct {
+	Config   *config.Config
+	Events   *events.Broker
+	Jobs     PasswordJobStore
+	Audit    AuditLog
+	Lockouts LoginFailures
+	Replicas ReplicaSource
+	Gateway  Gateway
+	Mailer   Mailer
+	Clock    Clock
+}
+
+// Start runs the given number of workers that take queued password jobs until ctx is cancelled. Jobs are
+// claimed with SKIP LOCKED so several backend instances can share the queue. A job that has started is
+// finished even after ctx is cancelled; the returned function waits for that.
+func (p *PasswordJobs) Start(ctx context.Context, workers int, interval time.Duration) func() {
+	var wg sync.WaitGroup
+	log.Info().Msgf("Starting %d password job workers, polling every %s", workers, interval)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				p.failStaleJobs()
+				for ctx.Err() == nil {
+					processed, err := p.RunNext(context.WithoutCancel(ctx))
+					if err != nil {
+						log.Error().Err(err).Msg("Failed to process password jobs")
+					}
+					if err != nil || !processed {
+						break
+					}
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+	return func() {
+		wg.Wait()
+		log.Info().Msg("Password job workers stopped")
+	}
+}
+
+func (p *PasswordJobs) failStaleJobs() {
+	err := p.Jobs.FailStalePasswordJobs(p.Clock.Now().Add(-staleJobAfter), "The job was interrupted, check the per-server results before retrying")
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to fail stale password jobs")
+	}
+}
+
+// RunNext runs the oldest queued job, if there is one, and reports whether it found one.
+func (p *PasswordJobs) RunNext(ctx context.Context) (bool, error) {
+	queued, found, err := p.Jobs.ClaimPasswordJob()
+	if err != nil || !found {
+		return false, err
+	}
+	job := passwordJob{id: queued.ID, requestID: queued.RequestID, clientIP: queued.ClientIP, request: queued.Request}
+
+	var secrets jobPayload
+	plaintext, err := pkg.DecryptPayload(p.Config.Jobs, queued.Payload)
+	if err == nil {
+		err = json.Unmarshal(plaintext, &secrets)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("request_id", job.requestID).Msgf("Failed to decrypt password job %s", job.id)
+		p.failJob(job, stepValidation, "The job could not be read, please submit the request again")
+		return true, nil
+	}
+	job.request.OldPassword = secrets.OldPassword
+	job.request.NewPassword = secrets.NewPassword
+
+	p.run(ctx, job)
+	return true, nil
+}
+
+// run performs the checks and the update that UpdatePassword used to do inline.
+func (p *PasswordJobs) run(ctx context.Context, job passwordJob) {
+	logger := log.With().Str("request_id", job.requestID).Str("job_id", job.id).Logger()
+	req := job.request
+	logger.Info().Msgf("Running password job for user: %s, serverIP: %s", req.Username, req.ServerIP)
+
+	// validate the user credentials
+	p.setJobStep(job.id, stepValidation, StepRunning, "")
+	eng, server, err := p.Gateway.ResolveServer(ctx, req.ServerIP)
+	if errors.Is(err, pkg.ErrUnknownServer) || errors.Is(err, pkg.ErrServerDisabled) || errors.Is(err, pkg.ErrAmbiguousServer) {
+		p.failJob(job, stepValidation, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Msgf("Failed to look up %s in the inventory", req.ServerIP)
+		p.failJob(job, stepValidation, "Failed to look up the server")
+		return
+	}
+	lockoutKey := pkg.PasswordLockoutKey(req.Username, server.ID)
+	isValidUser, err := eng.ValidateOwner(ctx, server, req.Username, req.Email)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to validate user credentials")
+		p.failJob(job, stepValidation, "Failed to validate user credentials")
+		return
+	}
+	if !isValidUser {
+		p.Lockouts.RecordLoginFailure(job.requestID, pkg.ScopePasswordUpdate, lockoutKey, job.clientIP, req.ServerIP)
+		p.failJob(job, stepValidation, "Invalid user credentials")
+		return
+	}
+	p.setJobStep(job.id, stepValidation, StepSucceeded, "")
+
+	p.setJobStep(job.id, stepExpiryCheck, StepRunning, "")
+	isValid, err := eng.CheckExpiry(ctx, server, req.Username)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error checking login expiration")
+		p.failJob(job, stepExpiryCheck, "Failed to check login existence")
+		return
+	}
+	if !isValid {
+		p.failJob(job, stepExpiryCheck, "Login is invalid or expired")
+		return
+	}
+	p.setJobStep(job.id, stepExpiryCheck, StepSucceeded, "")
+
+	// check if the old password is still valid. Jobs queued before the login got locked are refused here, and
+	// the attempt is counted before the check so that jobs running side by side cannot all pass the lockout
+	p.setJobStep(job.id, stepOldPassword, StepRunning, "")
+	retryAfter, err := p.Lockouts.ClaimLoginAttempt(job.requestID, pkg.ScopePasswordUpdate, lockoutKey, job.clientIP, req.ServerIP)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to check the lockout")
+		p.failJob(job, stepOldPassword, "Failed to check old password")
+		return
+	}
+	if retryAfter > 0 {
+		logger.Info().Msgf("Password job for %s from %s rejected, locked out", lockoutKey, job.clientIP)
+		p.failJob(job, stepOldPassword, fmt.Sprintf("Too many failed attempts, try again in %s", retryAfter.Round(time.Second)))
+		return
+	}
+	isValidOldPassword, err := eng.VerifyPassword(ctx, server, req.Username, req.OldPassword, req.Database)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to check old password")
+		p.failJob(job, stepOldPassword, "Failed to check old password")
+		return
+	}
+	if !isValidOldPassword {
+		p.failJob(job, stepOldPassword, "Old password is invalid")
+		return
+	}
+	p.Lockouts.ReleaseLoginAttempt(pkg.ScopePasswordUpdate, lockoutKey, job.clientIP)
+	p.setJobStep(job.id, stepOldPassword, StepSucceeded, "")
+
+	p.setJobStep(job.id, stepFindReplicas, StepRunning, "")
+	// the same replicas GET /servers/{server}/replicas showed the user, overrides included
+	serverTopology, err := p.Replicas.Replicas(ctx, eng, server)
+	replicaHosts := topology.Targets(serverTopology)
+	var serverReplicas []engine.Server
+	if err == nil {
+		serverReplicas, err = p.Gateway.ResolveReplicas(server, replicaHosts)
+	}
+	if errors.Is(err, pkg.ErrUnknownServer) || errors.Is(err, pkg.ErrServerDisabled) || errors.Is(err, pkg.ErrAmbiguousServer) {
+		// nothing was changed yet, a replica that cannot be reached like the inventory says is not guessed at
+		p.failJob(job, stepFindReplicas, err.Error())
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to find related servers")
+		p.failJob(job, stepFindReplicas, "Failed to find related servers")
+		return
+	}
+	p.setJobStep(job.id, stepFindReplicas, StepSucceeded, fmt.Sprintf("%d replicas found", len(serverReplicas)))
+	var serverSteps []JobStep
+	for i, host := range append([]string{server.Host}, replicaHosts...) {
+		serverSteps = append(serverSteps, JobStep{Order: jobStepOrder[stepFindReplicas] + i + 1, Name: serverStep(host)})
+	}
+	if err := p.Jobs.AddPasswordJobSteps(job.id, serverSteps); err != nil {
+		logger.Error().Err(err).Msg("Failed to add server steps")
+	}
+
+	result := ApplyPasswordUpdate(ctx, p.Audit, PasswordUpdate{
+		RequestID:   job.requestID,
+		Engine:      eng,
+		Username:    req.Username,
+		OldPassword: req.OldPassword,
+		NewPassword: req.NewPassword,
+		Primary:     server,
+		Replicas:    serverReplicas,
+		Policy:      p.Config.PasswordSync,
+		OnAttempt: func(attempt models.ServerAttempt) {
+			p.setJobStep(job.id, serverStep(attempt.Server), serverStepStatus(attempt), attemptMessage(attempt))
+		},
+	})
+	if result.Status != PasswordStatusSuccess {
+		logger.Error().Msgf("Password update ended as %s: %s", result.Status, result.Message)
+		p.skipPendingSteps(job.id)
+		p.finishJob(job, JobStatusFailed, result.Message)
+		p.Audit.LogStatus(job.requestID, "Password Update", result.Status, result.Message)
+		return
+	}
+	p.Audit.LogStatus(job.requestID, "Password Update", "Success", "Password updated successfully")
+
+	// send email to the user once the password is updated
+	p.setJobStep(job.id, stepEmail, StepRunning, "")
+	if err := p.Mailer.SendConfirmation(req.Email, req.Username); err != nil {
+		logger.Error().Err(err).Msg("Failed to send confirmation email")
+		p.setJobStep(job.id, stepEmail, StepFailed, "The password was updated but the confirmation email could not be sent")
+	} else {
+		p.setJobStep(job.id, stepEmail, StepSucceeded, "")
+	}
+	p.finishJob(job, JobStatusSucceeded, "Password updated successfully")
+	logger.Info().Msg("Password updated successfully for the user: " + req.Username)
+}
+
+func serverStepStatus(attempt models.ServerAttempt) string {
+	switch attempt.Status {
+	case attemptSuccess:
+		return StepSucceeded
+	case attemptRolledBack:
+		return StepRolledBack
+	case attemptRollbackFailed:
+		return StepRollbackFailed
+	}
+	return StepFailed
+}
+
+func attemptMessage(attempt models.ServerAttempt) string {
+	if attempt.Error == "" {
+		return fmt.Sprintf("%s attempt %d", attempt.Operation, attempt.Attempt)
+	}
+	return fmt.Sprintf("%s attempt %d: %s", attempt.Operation, attempt.Attempt, attempt.Error)
+}
+
+func (p *PasswordJobs) setJobStep(jobID, step, status, message string) {
+	if err := p.Jobs.SetPasswordJobStep(jobID, step, status, message); err != nil {
+		log.Error().Err(err).Msgf("Failed to update step %q of password job %s", step, jobID)
+		return
+	}
+	p.Events.Publish(events.Event{Topic: events.JobTopic(jobID), Type: "step", Data: models.PasswordJobStep{
+		Name:    step,
+		Status:  status,
+		Message: message,
+	}})
+}
+
+// failJob marks the step and the job as failed and skips the steps that did not run.
+func (p *PasswordJobs) failJob(job passwordJob, step, message string) {
+	log.Info().Str("request_id", job.requestID).Str("job_id", job.id).Msgf("Password job failed at %q: %s", step, message)
+	p.setJobStep(job.id, step, StepFailed, message)
+	p.skipPendingSteps(job.id)
+	p.finishJob(job, JobStatusFailed, message)
+	p.Audit.LogStatus(job.requestID, "Password Update", "Failed", message)
+}
+
+func (p *PasswordJobs) skipPendingSteps(jobID string) {
+	if err := p.Jobs.SkipPendingPasswordJobSteps(jobID); err != nil {
+		log.Error().Err(err).Msgf("Failed to skip the remaining steps of password job %s", jobID)
+	}
+}
+
+func (p *PasswordJobs) finishJob(job passwordJob, status, message string) {
+	if err := p.Jobs.FinishPasswordJob(job.id, status, message); err != nil {
+		log.Error().Err(err).Str("request_id", job.requestID).Msgf("Failed to finish password job %s", job.id)
+	}
+	// subscribers load the final state of the job when they see this
+	p.Events.Publish(events.Event{Topic: events.JobTopic(job.id), Type: "finished", Data: map[string]string{
+		"jobID":  job.id,
+		"status": status,
+	}})
+}
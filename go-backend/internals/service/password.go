@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-backend/internals/config"
+	"go-backend/internals/engine"
+	"go-backend/models"
+
+	"github.com/rs/zerolog/log"
+)
+
+// final states of a password update across the availability group
+const (
+	PasswordStatusSuccess      = "Success"
+	PasswordStatusFailed       = "Failed"
+	PasswordStatusRolledBack   = "Rolled Back"
+	PasswordStatusInconsistent = "Inconsistent"
+)
+
+// outcomes of a single attempt on one server
+const (
+	attemptSuccess        = "Success"
+	attemptFailed         = "Failed"
+	attemptRolledBack     = "Rolled Back"
+	attemptRollbackFailed = "Rollback Failed"
+)
+
+const (
+	operationUpdate   = "Update"
+	operationRollback = "Rollback"
+)
+
+// PasswordUpdate is a password change of one login on the primary and the replicas returned by the engine.
+type PasswordUpdate struct {
+	RequestID   string
+	Engine      engine.Engine
+	Username    string
+	OldPassword string
+	NewPassword string
+	Primary     engine.Server
+	Replicas    []engine.Server
+	// Policy is how often a server is tried, the first retry delay which doubles with every retry, how many
+	// servers are updated at once and how long a single server may take
+	Policy config.PasswordSyncConfig
+	// OnAttempt, if set, is called after every attempt, possibly from several goroutines at once
+	OnAttempt func(models.ServerAttempt)
+}
+
+// AttemptLog records every attempt of a password update on a single server.
+type AttemptLog interface {
+	LogServerAttempt(requestID string, attempt models.ServerAttempt)
+}
+
+type PasswordUpdateResult struct {
+	Status  string
+	Message string
+	// Servers holds the last attempt on every server, primary first
+	Servers []models.ServerAttempt
+}
+
+type passwordTarget struct {
+	server    string
+	address   engine.Server
+	isPrimary bool
+}
+
+// ApplyPasswordUpdate sets the new password on the primary and then on every replica. Replicas are updated
+// concurrently, at most Policy.Concurrency at a time and each within Policy.ServerTimeout.
+// Replicas that fail are retried with a growing delay. If they still have not converged, or ctx is cancelled
+// because the client went away, every server that already has the new password is set back to the old one,
+// so that the login keeps a single password across the group. The rollback is not bound to ctx.
+// Every attempt is recorded in attempts under the request ID.
+func ApplyPasswordUpdate(ctx context.Context, attempts AttemptLog, update PasswordUpdate) PasswordUpdateResult {
+	logger := log.With().Str("request_id", update.RequestID).Logger()
+	var mu sync.Mutex
+	last := map[string]models.ServerAttempt{}
+	record := func(target passwordTarget, operation string, attempt int, status string, err error, duration time.Duration) {
+		result := models.ServerAttempt{
+			Server:      target.server,
+			IsPrimary:   target.isPrimary,
+			Operation:   operation,
+			Attempt:     attempt,
+			Status:      status,
+			DurationMs:  int(duration.Milliseconds()),
+			AttemptedAt: time.Now().Format("2006-01-02 15:04:05"),
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		mu.Lock()
+		last[target.server] = result
+		mu.Unlock()
+		attempts.LogServerAttempt(update.RequestID, result)
+		if update.OnAttempt != nil {
+			update.OnAttempt(result)
+		}
+	}
+
+	targets := []passwordTarget{{server: update.Primary.Host, address: update.Primary, isPrimary: true}}
+	for _, replica := range update.Replicas {
+		targets = append(targets, passwordTarget{server: replica.Host, address: replica})
+	}
+	result := func(status, message string) PasswordUpdateResult {
+		servers := make([]models.ServerAttempt, 0, len(targets))
+		for _, target := range targets {
+			if attempt, ok := last[target.server]; ok {
+				servers = append(servers, attempt)
+			}
+		}
+		return PasswordUpdateResult{Status: status, Message: message, Servers: servers}
+	}
+	policy := update.Policy
+
+	// nothing has changed yet when the primary fails, so there is nothing to compensate
+	started := time.Now()
+	if err := setLoginPassword(ctx, update.Engine, targets[0], update.Username, update.NewPassword, policy.ServerTimeout); err != nil {
+		record(targets[0], operationUpdate, 1, attemptFailed, err, time.Since(started))
+		logger.Error().Err(err).Msgf("Failed to update password on the primary %s", update.Primary.Host)
+		return result(PasswordStatusFailed, "Failed to update password on the server: "+err.Error())
+	}
+	record(targets[0], operationUpdate, 1, attemptSuccess, nil, time.Since(started))
+	applied := []passwordTarget{targets[0]}
+
+	pending := targets[1:]
+	delay := policy.RetryDelay
+	for attempt := 1; attempt <= policy.Attempts && len(pending) > 0 && ctx.Err() == nil; attempt++ {
+		if attempt > 1 {
+			logger.Info().Msgf("Retrying password update on %d replicas in %s", len(pending), delay)
+			if !sleepContext(ctx, delay) {
+				break
+			}
+			delay *= 2
+		}
+		succeeded, failed := fanOut(pending, policy.Concurrency, func(target passwordTarget) error {
+			started := time.Now()
+			err := setLoginPassword(ctx, update.Engine, target, update.Username, update.NewPassword, policy.ServerTimeout)
+			if err != nil {
+				record(target, operationUpdate, attempt, attemptFailed, err, time.Since(started))
+				logger.Error().Err(err).Msgf("Attempt %d to update password on the replica %s failed", attempt, target.server)
+				return err
+			}
+			record(target, operationUpdate, attempt, attemptSuccess, nil, time.Since(started))
+			logger.Info().Msgf("Password updated successfully on the replica %s", target.server)
+			return nil
+		})
+		applied = append(applied, succeeded...)
+		pending = failed
+	}
+	if len(pending) == 0 {
+		return result(PasswordStatusSuccess, fmt.Sprintf("Password updated on all %d servers", len(targets)))
+	}
+
+	// the replicas did not converge, put the old password back wherever the new one was set.
+	// This has to finish even if the client has gone away.
+	unreachable := serverNames(pending)
+	if ctx.Err() != nil {
+		logger.Warn().Err(ctx.Err()).Msg("Password update cancelled before all replicas were updated")
+	}
+	logger.Warn().Msgf("Replicas %s did not accept the new password, rolling back %s", unreachable, serverNames(applied))
+	rollbackCtx := context.WithoutCancel(ctx)
+	_, rollbackFailed := fanOut(applied, policy.Concurrency, func(target passwordTarget) error {
+		var err error
+		retryDelay := policy.RetryDelay
+		for attempt := 1; attempt <= policy.Attempts; attempt++ {
+			if attempt > 1 {
+				time.Sleep(retryDelay)
+				retryDelay *= 2
+			}
+			started := time.Now()
+			err = setLoginPassword(rollbackCtx, update.Engine, target, update.Username, update.OldPassword, policy.ServerTimeout)
+			if err == nil {
+				record(target, operationRollback, attempt, attemptRolledBack, nil, time.Since(started))
+				return nil
+			}
+			record(target, operationRollback, attempt, attemptRollbackFailed, err, time.Since(started))
+			logger.Error().Err(err).Msgf("Attempt %d to roll back the password on %s failed", attempt, target.server)
+		}
+		return err
+	})
+
+	if len(rollbackFailed) == 0 {
+		return result(PasswordStatusRolledBack, fmt.Sprintf(
+			"Password could not be updated on %s, the change was rolled back and the old password is still valid", unreachable))
+	}
+	return result(PasswordStatusInconsistent, fmt.Sprintf(
+		"Password could not be updated on %s and rolling back failed on %s, the login has different passwords on the servers and needs manual repair",
+		unreachable, serverNames(rollbackFailed)))
+}
+
+// fanOut runs fn for every target with at most limit running at once and splits the targets by outcome.
+func fanOut(targets []passwordTarget, limit int, fn func(passwordTarget) error) (succeeded, failed []passwordTarget) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, limit)
+	for _, target := range targets {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(target passwordTarget) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			err := fn(target)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, target)
+				return
+			}
+			succeeded = append(succeeded, target)
+		}(target)
+	}
+	wg.Wait()
+	return succeeded, failed
+}
+
+// setLoginPassword sets the password of the login on one server, giving up after timeout.
+func setLoginPassword(ctx context.Context, eng engine.Engine, target passwordTarget, username, password string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	return eng.ChangePassword(ctx, target.address, username, password)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func serverNames(targets []passwordTarget) string {
+	names := make([]string, 0, len(targets))
+	for _, target := range targets {
+		names = append(names, target.server)
+	}
+	return strings.Join(names, ", ")
+}
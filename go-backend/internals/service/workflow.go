@@ -0,0 +1,306 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"go-backend/internals/events"
+	"go-backend/internals/pkg"
+	"go-backend/models"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	StatusPending     = "Pending"
+	StatusApproved    = "Approved"
+	StatusRejected    = "Rejected"
+	StatusGranted     = "Granted"
+	StatusGrantFailed = "Grant Failed"
+)
+
+var (
+	ErrRequestNotFound   = errors.New("access request not found")
+	ErrRequestNotPending = errors.New("access request is not pending")
+	ErrNotApprover       = errors.New("approver is not allowed to decide this stage")
+	ErrAlreadyDecided    = errors.New("approver has already decided an earlier stage of this request")
+	ErrNoStages          = errors.New("no approval stages configured for this access level")
+	ErrGrantFailed       = errors.New("access request approved but granting access failed")
+)
+
+// roles granted on the target database once the final stage approves a request
+var accessLevelRoles = map[string][]string{
+	"read":  {"db_datareader"},
+	"write": {"db_datareader", "db_datawriter"},
+	"admin": {"db_owner"},
+}
+
+type accessRequest struct {
+	// server is the central SQL Server the grant is made on, every transition is logged against it
+	server       string
+	id           int
+	username     string
+	database     string
+	accessLevel  string
+	status       string
+	currentStage int
+}
+
+// DecideAccessRequest records the approver's decision on the current stage of an access request and
+// moves the request forward. The grant on SQL Server is only executed once the final stage approves.
+// msServer names the central SQL Server behind msdb in the audit log, which is published through broker.
+func DecideAccessRequest(db, msdb *sql.DB, broker *events.Broker, msServer string, requestID int, approver, decision, comment string) (models.AccessDecisionResult, error) {
+	result := models.AccessDecisionResult{RequestID: requestID}
+	if decision != StatusApproved && decision != StatusRejected {
+		return result, fmt.Errorf("invalid decision %q", decision)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return result, err
+	}
+	defer tx.Rollback()
+
+	req := accessRequest{server: msServer}
+	err = tx.QueryRow(`SELECT id, username, database_name, access_level, request_status, current_stage
+		FROM access_requests WHERE id = $1 FOR UPDATE`, requestID).
+		Scan(&req.id, &req.username, &req.database, &req.accessLevel, &req.status, &req.currentStage)
+	if err == sql.ErrNoRows {
+		return result, ErrRequestNotFound
+	}
+	if err != nil {
+		return result, err
+	}
+	if req.status != StatusPending {
+		return result, ErrRequestNotPending
+	}
+
+	stages, err := loadStages(tx, req.accessLevel)
+	if err != nil {
+		return result, err
+	}
+	if len(stages) == 0 {
+		return result, ErrNoStages
+	}
+
+	// the stage configuration may have changed since the request was raised,
+	// so pick the first stage that has not been passed yet
+	idx := -1
+	for i, stage := range stages {
+		if stage.StageOrder >= req.currentStage {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return result, ErrNoStages
+	}
+	stage := stages[idx]
+	result.StageName = stage.StageName
+
+	if approver == req.username {
+		return result, ErrNotApprover
+	}
+	var isMember bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM approver_group_members WHERE group_name = $1 AND approver = $2)",
+		stage.ApproverGroup, approver).Scan(&isMember)
+	if err != nil {
+		return result, err
+	}
+	if !isMember {
+		return result, ErrNotApprover
+	}
+	var decidedBefore bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM access_request_decisions WHERE request_id = $1 AND approver = $2)",
+		req.id, approver).Scan(&decidedBefore)
+	if err != nil {
+		return result, err
+	}
+	if decidedBefore {
+		return result, ErrAlreadyDecided
+	}
+
+	_, err = tx.Exec(`INSERT INTO access_request_decisions
+		(request_id, stage_order, stage_name, approver_group, approver, decision, comment, decided_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, CURRENT_TIMESTAMP)`,
+		req.id, stage.StageOrder, stage.StageName, stage.ApproverGroup, approver, decision, comment)
+	if err != nil {
+		return result, err
+	}
+
+	nextStage := req.currentStage
+	switch {
+	case decision == StatusRejected:
+		result.RequestStatus = StatusRejected
+		result.Message = fmt.Sprintf("Rejected at stage %s by %s", stage.StageName, approver)
+	case idx == len(stages)-1:
+		result.RequestStatus = StatusApproved
+		result.Message = fmt.Sprintf("Approved at final stage %s by %s", stage.StageName, approver)
+	default:
+		next := stages[idx+1]
+		nextStage = next.StageOrder
+		result.RequestStatus = StatusPending
+		result.NextStage = next.StageName
+		result.Message = fmt.Sprintf("Approved at stage %s by %s, awaiting %s", stage.StageName, approver, next.StageName)
+	}
+
+	_, err = tx.Exec(`UPDATE access_requests SET
+		request_status = $2, current_stage = $3, reviewed_by = $4, message = $5, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, req.id, result.RequestStatus, nextStage, approver, result.Message)
+	if err != nil {
+		return result, err
+	}
+	if err = tx.Commit(); err != nil {
+		return result, err
+	}
+	logTransition(db, broker, req, result.RequestStatus, result.Message)
+
+	if result.RequestStatus != StatusApproved {
+		return result, nil
+	}
+
+	if err := grantAccess(msdb, req); err != nil {
+		log.Error().Err(err).Msgf("Failed to grant access for request %d", req.id)
+		result.RequestStatus = StatusGrantFailed
+		result.Message = "Granting access failed: " + err.Error()
+		setRequestStatus(db, broker, req, result.RequestStatus, result.Message)
+		return result, ErrGrantFailed
+	}
+	result.RequestStatus = StatusGranted
+	result.Message = fmt.Sprintf("Granted %v on %s", accessLevelRoles[req.accessLevel], req.database)
+	setRequestStatus(db, broker, req, result.RequestStatus, result.Message)
+	return result, nil
+}
+
+func grantAccess(msdb *sql.DB, req accessRequest) error {
+	roles, ok := accessLevelRoles[req.accessLevel]
+	if !ok {
+		return fmt.Errorf("no roles mapped to access level %q", req.accessLevel)
+	}
+	for _, role := range roles {
+		_, err := msdb.Exec("EXEC dbo.GrantDatabaseAccess @LoginName=?, @DatabaseName=?, @RoleName=?", req.username, req.database, role)
+		if err != nil {
+			return err
+		}
+		log.Info().Msgf("Granted %s on %s to %s", role, req.database, req.username)
+	}
+	return nil
+}
+
+func setRequestStatus(db *sql.DB, broker *events.Broker, req accessRequest, status, message string) {
+	_, err := db.Exec("UPDATE access_requests SET request_status = $2, message = $3 WHERE id = $1", req.id, status, message)
+	if err != nil {
+		log.Error().Err(err).Msgf("Failed to update status of access request %d", req.id)
+	}
+	logTransition(db, broker, req, status, message)
+}
+
+func logTransition(db *sql.DB, broker *events.Broker, req accessRequest, status, message string) {
+	pkg.LogPasswordUpdate(db, broker, "", req.username, req.server, "Access Request", status,
+		fmt.Sprintf("Request %d (%s on %s): %s", req.id, req.accessLevel, req.database, message))
+}
+
+func loadStages(tx *sql.Tx, accessLevel string) ([]models.ApprovalStage, error) {
+	rows, err := tx.Query(`SELECT access_level, stage_order, stage_name, approver_group
+		FROM approval_stages WHERE access_level = $1 ORDER BY stage_order`, accessLevel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stages []models.ApprovalStage
+	for rows.Next() {
+		var stage models.ApprovalStage
+		if err := rows.Scan(&stage.AccessLevel, &stage.StageOrder, &stage.StageName, &stage.ApproverGroup); err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return stages, rows.Err()
+}
+
+func GetApprovalStages(db *sql.DB) ([]models.ApprovalStage, error) {
+	rows, err := db.Query("SELECT * FROM get_approval_stages()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stages := []models.ApprovalStage{}
+	for rows.Next() {
+		var stage models.ApprovalStage
+		if err := rows.Scan(&stage.AccessLevel, &stage.StageOrder, &stage.StageName, &stage.ApproverGroup); err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return stages, rows.Err()
+}
+
+// SetApprovalStages replaces the stages configured for an access level. Requests already in flight
+// continue from the first stage whose order is not lower than the stage they were waiting on.
+func SetApprovalStages(db *sql.DB, accessLevel string, stages []models.ApprovalStage) error {
+	if _, ok := accessLevelRoles[accessLevel]; !ok {
+		return fmt.Errorf("unknown access level %q", accessLevel)
+	}
+	if len(stages) == 0 {
+		return ErrNoStages
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec("DELETE FROM approval_stages WHERE access_level = $1", accessLevel); err != nil {
+		return err
+	}
+	for i, stage := range stages {
+		if stage.StageName == "" || stage.ApproverGroup == "" {
+			return fmt.Errorf("stage %d needs a name and an approver group", i+1)
+		}
+		_, err = tx.Exec(`INSERT INTO approval_stages (access_level, stage_order, stage_name, approver_group)
+			VALUES ($1, $2, $3, $4)`, accessLevel, i+1, stage.StageName, stage.ApproverGroup)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func AddApproverGroupMember(db *sql.DB, group, approver string) error {
+	_, err := db.Exec(`INSERT INTO approver_group_members (group_name, approver) VALUES ($1, $2)
+		ON CONFLICT (group_name, approver) DO NOTHING`, group, approver)
+	return err
+}
+
+func RemoveApproverGroupMember(db *sql.DB, group, approver string) error {
+	_, err := db.Exec("DELETE FROM approver_group_members WHERE group_name = $1 AND approver = $2", group, approver)
+	return err
+}
+
+func GetAccessRequestDecisions(db *sql.DB, requestID int) ([]models.AccessDecision, error) {
+	rows, err := db.Query("SELECT * FROM get_access_request_decisions($1)", requestID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	decisions := []models.AccessDecision{}
+	for rows.Next() {
+		var decision models.AccessDecision
+		var comment sql.NullString
+		var decidedAt pq.NullTime
+		if err := rows.Scan(&decision.StageOrder, &decision.StageName, &decision.ApproverGroup, &decision.Approver,
+			&decision.Decision, &comment, &decidedAt); err != nil {
+			return nil, err
+		}
+		decision.Comment = comment.String
+		decision.DecidedAt = formatTime(decidedAt)
+		decisions = append(decisions, decision)
+	}
+	return decisions, rows.Err()
+}
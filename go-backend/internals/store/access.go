@@ -0,0 +1,93 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"go-backend/internals/gateway"
+	"go-backend/internals/service"
+	"go-backend/models"
+
+	"github.com/lib/pq"
+)
+
+// CreateAccessRequest records an access request and returns its ID.
+func (s *Postgres) CreateAccessRequest(request models.AccessRequest) (int, error) {
+	var requestID int
+	err := s.DB.QueryRow("SELECT log_access_request($1, $2, $3, $4)", request.Username, request.Database, request.AccessLevel, request.Reason).Scan(&requestID)
+	return requestID, err
+}
+
+func (s *Postgres) AccessRequests() ([]models.AccessRequestRecord, error) {
+	rows, err := s.DB.Query("SELECT * FROM get_all_access_requests()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := []models.AccessRequestRecord{}
+	for rows.Next() {
+		var request models.AccessRequestRecord
+		var reason, reviewedBy, message sql.NullString
+		var requestTime, reviewTime pq.NullTime
+		if err := rows.Scan(&request.RequestID, &request.Username, &request.Database, &request.AccessLevel, &reason,
+			&request.RequestStatus, &reviewedBy, &message, &request.CurrentStage, &requestTime, &reviewTime); err != nil {
+			return nil, err
+		}
+		request.Reason = reason.String
+		request.ReviewedBy = reviewedBy.String
+		request.Message = message.String
+		request.RequestTime = formatTime(requestTime, "N/A")
+		request.ReviewTime = formatTime(reviewTime, "N/A")
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+func (s *Postgres) DecideAccessRequest(requestID int, approver, decision, comment string) (models.AccessDecisionResult, error) {
+	return service.DecideAccessRequest(s.DB, s.Catalog, s.Events, s.Config.MSSQL.Server, requestID, approver, decision, comment)
+}
+
+func (s *Postgres) AccessRequestDecisions(requestID int) ([]models.AccessDecision, error) {
+	return service.GetAccessRequestDecisions(s.DB, requestID)
+}
+
+func (s *Postgres) ApprovalStages() ([]models.ApprovalStage, error) {
+	return service.GetApprovalStages(s.DB)
+}
+
+func (s *Postgres) SetApprovalStages(accessLevel string, stages []models.ApprovalStage) error {
+	return service.SetApprovalStages(s.DB, accessLevel, stages)
+}
+
+func (s *Postgres) AddApproverGroupMember(group, approver string) error {
+	return service.AddApproverGroupMember(s.DB, group, approver)
+}
+
+func (s *Postgres) RemoveApproverGroupMember(group, approver string) error {
+	return service.RemoveApproverGroupMember(s.DB, group, approver)
+}
+
+func (s *Postgres) RequestTemporaryAccess(request models.TemporaryAccessRequest) (int, error) {
+	return service.RequestTemporaryAccess(s.DB, s.Events, s.Config.Grants, request)
+}
+
+func (s *Postgres) ApproveTemporaryAccess(requestID int, approver, comment string) (time.Time, error) {
+	return service.ApproveTemporaryAccess(s.DB, s.Events, gateway.New(s.DB, s.Catalog, s.Connector), s, s.Connector, s.Config.Grants, requestID, approver, comment)
+}
+
+func (s *Postgres) RejectTemporaryAccess(requestID int, approver, comment string) error {
+	return service.RejectTemporaryAccess(s.DB, s.Events, requestID, approver, comment)
+}
+
+func (s *Postgres) TemporaryAccessRequests() ([]models.TemporaryAccessRecord, error) {
+	return service.GetAllTemporaryAccessRequests(s.DB)
+}
+
+func (s *Postgres) TemporaryGrants() ([]models.TemporaryGrant, error) {
+	return service.GetAllTemporaryGrants(s.DB)
+}
+
+func (s *Postgres) ExpireTemporaryAccess(requestID int) error {
+	return service.ExpireTemporaryAccess(s.DB, requestID)
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"errors"
+
+	"go-backend/internals/pkg"
+	"go-backend/models"
+
+	"github.com/lib/pq"
+)
+
+func (s *Postgres) CheckAdminCredentials(username, password string) (bool, error) {
+	var isValidAdmin bool
+	err := s.DB.QueryRow("SELECT check_admin_credentials($1, $2)", username, password).Scan(&isValidAdmin)
+	return isValidAdmin, err
+}
+
+func (s *Postgres) ListAdmins() ([]models.Admin, error) {
+	rows, err := s.DB.Query("SELECT * FROM get_all_admins()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	admins := []models.Admin{}
+	for rows.Next() {
+		var admin models.Admin
+		var passwordLastUpdated, lastLogin, createdAt pq.NullTime
+		if err := rows.Scan(&admin.Username, &admin.Role, &admin.Disabled, &admin.MustChangePassword,
+			&passwordLastUpdated, &lastLogin, &createdAt); err != nil {
+			return nil, err
+		}
+		admin.PasswordLastUpdated = formatTime(passwordLastUpdated, "N/A")
+		admin.LastLogin = formatTime(lastLogin, "N/A")
+		admin.CreatedAt = formatTime(createdAt, "N/A")
+		admins = append(admins, admin)
+	}
+	return admins, rows.Err()
+}
+
+// CreateAdmin adds an admin with the given password and role, ErrAdminExists if the username is taken.
+func (s *Postgres) CreateAdmin(username, password, role string) error {
+	_, err := s.DB.Exec("SELECT insert_into_admin($1, $2, $3)", username, password, role)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrAdminExists
+	}
+	return err
+}
+
+func (s *Postgres) CreateFirstAdmin(token, username, password string) error {
+	return pkg.CreateFirstAdmin(s.DB, token, username, password)
+}
+
+func (s *Postgres) ChangeAdminPassword(username, password string) error {
+	_, err := s.DB.Exec("SELECT change_admin_password($1, $2)", username, password)
+	return err
+}
+
+// The functions below report false when the admin does not exist.
+
+func (s *Postgres) ForceAdminPasswordChange(username string) (bool, error) {
+	var updated bool
+	err := s.DB.QueryRow("SELECT force_admin_password_change($1)", username).Scan(&updated)
+	return updated, err
+}
+
+func (s *Postgres) SetAdminDisabled(username string, disabled bool) (bool, error) {
+	var updated bool
+	err := s.DB.QueryRow("SELECT set_admin_disabled($1, $2)", username, disabled).Scan(&updated)
+	return updated, err
+}
+
+func (s *Postgres) SetAdminRole(username, role string) (bool, error) {
+	var updated bool
+	err := s.DB.QueryRow("SELECT set_admin_role($1, $2)", username, role).Scan(&updated)
+	return updated, err
+}
+
+func (s *Postgres) DeleteAdmin(username string) (bool, error) {
+	var deleted bool
+	err := s.DB.QueryRow("SELECT delete_admin($1)", username).Scan(&deleted)
+	return deleted, err
+}
+
+// IsLastActiveSuperadmin reports whether the admin is the only superadmin that is not disabled.
+func (s *Postgres) IsLastActiveSuperadmin(username string) (bool, error) {
+	var isLast bool
+	err := s.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM admin WHERE username = $1 AND role = 'superadmin' AND NOT disabled)
+		AND (SELECT COUNT(*) FROM admin WHERE role = 'superadmin' AND NOT disabled) = 1`, username).Scan(&isLast)
+	return isLast, err
+}
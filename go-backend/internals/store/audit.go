@@ -0,0 +1,94 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"go-backend/internals/pkg"
+	"go-backend/models"
+
+	"github.com/lib/pq"
+)
+
+func (s *Postgres) LogPasswordUpdate(requestID, username, serverIP, requestType, requestStatus, message string) {
+	pkg.LogPasswordUpdate(s.DB, s.Events, requestID, username, serverIP, requestType, requestStatus, message)
+}
+
+func (s *Postgres) LogStatus(requestID, requestType, requestStatus, message string) {
+	pkg.LogStatus(s.DB, s.Events, requestID, requestType, requestStatus, message)
+}
+
+func (s *Postgres) LogServerAttempt(requestID string, attempt models.ServerAttempt) {
+	pkg.LogServerAttempt(s.DB, requestID, attempt.Server, attempt.IsPrimary, attempt.Operation, attempt.Attempt,
+		attempt.Status, attempt.Error, time.Duration(attempt.DurationMs)*time.Millisecond)
+}
+
+// ResetRequests returns every row of pass_reset_logs, nil if there are none.
+func (s *Postgres) ResetRequests() ([]models.ResetRequest, error) {
+	rows, err := s.DB.Query("SELECT * FROM get_all_logs()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.ResetRequest
+	for rows.Next() {
+		var request models.ResetRequest
+		var correlationID sql.NullString
+		var requestTime pq.NullTime
+		if err := rows.Scan(&request.RequestID, &request.Username, &request.ServerIP, &request.RequestType, &request.RequestStatus, &request.Message, &correlationID, &requestTime); err != nil {
+			return nil, err
+		}
+		request.CorrelationID = correlationID.String
+		request.RequestTime = formatTime(requestTime, "N/A")
+		requests = append(requests, request)
+	}
+	return requests, rows.Err()
+}
+
+// ServerAttempts returns the per-server breakdown of the password update with the correlation ID.
+func (s *Postgres) ServerAttempts(correlationID string) ([]models.ServerAttempt, error) {
+	rows, err := s.DB.Query("SELECT * FROM get_pass_reset_server_attempts($1)", correlationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []models.ServerAttempt{}
+	for rows.Next() {
+		var attempt models.ServerAttempt
+		var attemptError sql.NullString
+		var attemptedAt pq.NullTime
+		if err := rows.Scan(&attempt.Server, &attempt.IsPrimary, &attempt.Operation, &attempt.Attempt, &attempt.Status, &attemptError, &attempt.DurationMs, &attemptedAt); err != nil {
+			return nil, err
+		}
+		attempt.Error = attemptError.String
+		attempt.AttemptedAt = formatTime(attemptedAt, "N/A")
+		attempts = append(attempts, attempt)
+	}
+	return attempts, rows.Err()
+}
+
+// PartialResets lists password updates that left the login out of sync across the availability group.
+func (s *Postgres) PartialResets() ([]models.PartialReset, error) {
+	rows, err := s.DB.Query("SELECT * FROM get_partial_pass_resets()")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resets := []models.PartialReset{}
+	for rows.Next() {
+		var reset models.PartialReset
+		var message sql.NullString
+		var requestTime pq.NullTime
+		if err := rows.Scan(&reset.CorrelationID, &reset.Username, &reset.ServerIP, &reset.RequestStatus, &message,
+			&reset.Succeeded, &reset.Failed, &requestTime); err != nil {
+			return nil, err
+		}
+		reset.Message = message.String
+		reset.RequestTime = formatTime(requestTime, "N/A")
+		resets = append(resets, reset)
+	}
+	return resets, rows.Err()
+}
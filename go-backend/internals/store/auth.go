@@ -0,0 +1,91 @@
+package store
+
+import (
+	"time"
+
+	"go-backend/internals/pkg"
+)
+
+func (s *Postgres) CreateSession(username string) (pkg.Session, error) {
+	return pkg.CreateSession(s.DB, s.Config.Session, username)
+}
+
+func (s *Postgres) LookupSession(cookieValue string) (pkg.Session, error) {
+	return pkg.LookupSession(s.DB, s.Config.Session, cookieValue)
+}
+
+func (s *Postgres) RefreshSession(session pkg.Session) (pkg.Session, error) {
+	return pkg.RefreshSession(s.DB, s.Config.Session, session)
+}
+
+func (s *Postgres) RevokeSession(session pkg.Session) error {
+	return pkg.RevokeSession(s.DB, session)
+}
+
+func (s *Postgres) RevokeAllSessions(username string) error {
+	return pkg.RevokeAllSessions(s.DB, username)
+}
+
+func (s *Postgres) IsMFAEnabled(username string) (bool, error) {
+	return pkg.IsMFAEnabled(s.DB, username)
+}
+
+func (s *Postgres) StartMFAEnrollment(username string) (string, string, error) {
+	return pkg.StartMFAEnrollment(s.DB, username)
+}
+
+func (s *Postgres) ConfirmMFAEnrollment(username, code string) ([]string, error) {
+	return pkg.ConfirmMFAEnrollment(s.DB, username, code)
+}
+
+func (s *Postgres) DisableMFA(username string) error {
+	return pkg.DisableMFA(s.DB, username)
+}
+
+func (s *Postgres) VerifyMFACode(username, code string) (bool, error) {
+	return pkg.VerifyMFACode(s.DB, username, code)
+}
+
+func (s *Postgres) CreateMFAChallenge(username string) (string, error) {
+	return pkg.CreateMFAChallenge(s.DB, username)
+}
+
+func (s *Postgres) MFAChallengeUsername(token string) (string, error) {
+	return pkg.MFAChallengeUsername(s.DB, token)
+}
+
+func (s *Postgres) CompleteMFAChallenge(token, code, recoveryCode string) (string, error) {
+	return pkg.CompleteMFAChallenge(s.DB, token, code, recoveryCode)
+}
+
+func (s *Postgres) CheckLockout(scope, username, clientIP string) (time.Duration, error) {
+	return pkg.CheckLockout(s.DB, scope, username, clientIP)
+}
+
+func (s *Postgres) ClaimLoginAttempt(requestID, scope, username, clientIP, serverIP string) (time.Duration, error) {
+	return pkg.ClaimLoginAttempt(s.DB, s.Events, requestID, scope, username, clientIP, serverIP)
+}
+
+func (s *Postgres) ReleaseLoginAttempt(scope, username, clientIP string) {
+	pkg.ReleaseLoginAttempt(s.DB, scope, username, clientIP)
+}
+
+func (s *Postgres) RecordLoginFailure(requestID, scope, username, clientIP, serverIP string) {
+	pkg.RecordLoginFailure(s.DB, s.Events, requestID, scope, username, clientIP, serverIP)
+}
+
+func (s *Postgres) ClearLoginFailures(scope, username string) {
+	pkg.ClearLoginFailures(s.DB, scope, username)
+}
+
+func (s *Postgres) ClaimIdempotencyKey(scope, key, fingerprint string) (bool, pkg.IdempotentResult, error) {
+	return pkg.ClaimIdempotencyKey(s.DB, s.Config.Idempotency, scope, key, fingerprint)
+}
+
+func (s *Postgres) CompleteIdempotencyKey(scope, key string, statusCode int, body []byte, location string) error {
+	return pkg.CompleteIdempotencyKey(s.DB, s.Config.Idempotency, scope, key, statusCode, body, location)
+}
+
+func (s *Postgres) ReleaseIdempotencyKey(scope, key string) error {
+	return pkg.ReleaseIdempotencyKey(s.DB, scope, key)
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"go-backend/internals/service"
+	"go-backend/models"
+
+	"github.com/lib/pq"
+)
+
+func (s *Postgres) InsertPasswordJob(job service.QueuedPasswordJob, steps []service.JobStep) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	req := job.Request
+	_, err = tx.Exec(`INSERT INTO password_jobs (id, correlation_id, username, email, server_ip, database_name, client_ip, payload, job_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		job.ID, job.RequestID, req.Username, req.Email, req.ServerIP, req.Database, job.ClientIP, job.Payload, service.JobStatusQueued)
+	if err != nil {
+		return err
+	}
+	if err := insertJobSteps(tx, job.ID, steps); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Postgres) AddPasswordJobSteps(jobID string, steps []service.JobStep) error {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := insertJobSteps(tx, jobID, steps); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertJobSteps(tx *sql.Tx, jobID string, steps []service.JobStep) error {
+	for _, step := range steps {
+		_, err := tx.Exec(`INSERT INTO password_job_steps (job_id, step_order, step_name, step_status) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (job_id, step_name) DO NOTHING`, jobID, step.Order, step.Name, service.StepPending)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimPasswordJob takes the oldest queued job with SKIP LOCKED, so several backend instances can share the queue.
+func (s *Postgres) ClaimPasswordJob() (service.QueuedPasswordJob, bool, error) {
+	var job service.QueuedPasswordJob
+	var requestID, databaseName, clientIP sql.NullString
+	err := s.DB.QueryRow(`UPDATE password_jobs SET job_status = $1, started_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT id FROM password_jobs WHERE job_status = $2
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, correlation_id, username, email, server_ip, database_name, client_ip, payload`,
+		service.JobStatusRunning, service.JobStatusQueued).
+		Scan(&job.ID, &requestID, &job.Request.Username, &job.Request.Email, &job.Request.ServerIP, &databaseName, &clientIP, &job.Payload)
+	if err == sql.ErrNoRows {
+		return service.QueuedPasswordJob{}, false, nil
+	}
+	if err != nil {
+		return service.QueuedPasswordJob{}, false, err
+	}
+	job.RequestID = requestID.String
+	job.ClientIP = clientIP.String
+	job.Request.Database = databaseName.String
+	return job, true, nil
+}
+
+func (s *Postgres) FailStalePasswordJobs(startedBefore time.Time, message string) error {
+	_, err := s.DB.Exec(`UPDATE password_jobs SET job_status = $1, message = $2, payload = NULL, finished_at = CURRENT_TIMESTAMP
+		WHERE job_status = $3 AND started_at < $4`,
+		service.JobStatusFailed, message, service.JobStatusRunning, startedBefore)
+	return err
+}
+
+func (s *Postgres) SetPasswordJobStep(jobID, step, status, message string) error {
+	_, err := s.DB.Exec(`UPDATE password_job_steps SET step_status = $3, message = NULLIF($4, ''),
+		started_at = COALESCE(started_at, CURRENT_TIMESTAMP),
+		finished_at = CASE WHEN $3 IN ($5, $6) THEN NULL ELSE CURRENT_TIMESTAMP END
+		WHERE job_id = $1 AND step_name = $2`, jobID, step, status, message, service.StepPending, service.StepRunning)
+	return err
+}
+
+func (s *Postgres) SkipPendingPasswordJobSteps(jobID string) error {
+	_, err := s.DB.Exec("UPDATE password_job_steps SET step_status = $2 WHERE job_id = $1 AND step_status = $3", jobID, service.StepSkipped, service.StepPending)
+	return err
+}
+
+func (s *Postgres) FinishPasswordJob(jobID, status, message string) error {
+	_, err := s.DB.Exec(`UPDATE password_jobs SET job_status = $2, message = $3, payload = NULL, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1`, jobID, status, message)
+	return err
+}
+
+func (s *Postgres) GetPasswordJob(jobID string) (models.PasswordJob, error) {
+	rows, err := s.DB.Query("SELECT * FROM get_password_job($1)", jobID)
+	if err != nil {
+		return models.PasswordJob{}, err
+	}
+	defer rows.Close()
+
+	job := models.PasswordJob{Steps: []models.PasswordJobStep{}}
+	found := false
+	for rows.Next() {
+		var requestID, message, stepName, stepStatus, stepMessage sql.NullString
+		var createdAt, startedAt, finishedAt, stepStartedAt, stepFinishedAt pq.NullTime
+		if err := rows.Scan(&job.JobID, &requestID, &job.Status, &message, &createdAt, &startedAt, &finishedAt,
+			&stepName, &stepStatus, &stepMessage, &stepStartedAt, &stepFinishedAt); err != nil {
+			return models.PasswordJob{}, err
+		}
+		found = true
+		job.RequestID = requestID.String
+		job.Message = message.String
+		job.CreatedAt = formatTime(createdAt, "")
+		job.StartedAt = formatTime(startedAt, "")
+		job.FinishedAt = formatTime(finishedAt, "")
+		if stepName.Valid {
+			job.Steps = append(job.Steps, models.PasswordJobStep{
+				Name:       stepName.String,
+				Status:     stepStatus.String,
+				Message:    stepMessage.String,
+				StartedAt:  formatTime(stepStartedAt, ""),
+				FinishedAt: formatTime(stepFinishedAt, ""),
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return models.PasswordJob{}, err
+	}
+	if !found {
+		return models.PasswordJob{}, service.ErrJobNotFound
+	}
+	return job, nil
+}
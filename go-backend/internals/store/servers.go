@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+
+	"go-backend/internals/engine"
+	"go-backend/internals/pkg"
+	"go-backend/internals/procedures"
+	"go-backend/internals/topology"
+	"go-backend/models"
+)
+
+func (s *Postgres) ListServers() ([]models.DatabaseServer, error) {
+	return pkg.GetAllServers(s.DB)
+}
+
+func (s *Postgres) GetServer(id int) (models.DatabaseServer, error) {
+	return pkg.GetServer(s.DB, id)
+}
+
+func (s *Postgres) FindServer(server string) (models.DatabaseServer, error) {
+	return pkg.FindServer(s.DB, server)
+}
+
+func (s *Postgres) CreateServer(server models.DatabaseServer) (models.DatabaseServer, error) {
+	return pkg.CreateServer(s.DB, s.Connector.Secrets, server)
+}
+
+func (s *Postgres) UpdateServer(server models.DatabaseServer) (models.DatabaseServer, error) {
+	return pkg.UpdateServer(s.DB, s.Connector.Secrets, server)
+}
+
+func (s *Postgres) DeleteServer(id int) error {
+	return pkg.DeleteServer(s.DB, id)
+}
+
+func (s *Postgres) Replicas(ctx context.Context, eng engine.Engine, server engine.Server) (models.ServerTopology, error) {
+	return s.Topology.Replicas(ctx, s.DB, eng, server)
+}
+
+func (s *Postgres) RefreshReplicas(ctx context.Context, eng engine.Engine, server engine.Server) (models.ServerTopology, error) {
+	return s.Topology.Refresh(ctx, s.DB, eng, server)
+}
+
+func (s *Postgres) SetReplicaOverride(server engine.Server, override models.TopologyOverride) error {
+	return topology.SetOverride(s.DB, server, override)
+}
+
+func (s *Postgres) RemoveReplicaOverride(server engine.Server, replicaHost string) error {
+	return topology.RemoveOverride(s.DB, server, replicaHost)
+}
+
+func (s *Postgres) ServerProcedures(ctx context.Context, server engine.Server) (models.ProcedureReport, error) {
+	return procedures.Verify(ctx, s.DB, s.Connector, server)
+}
+
+func (s *Postgres) DeployServerProcedures(ctx context.Context, server engine.Server, deployedBy string) (models.ProcedureReport, error) {
+	return procedures.Deploy(ctx, s.DB, s.Connector, server, deployedBy)
+}
@@ -0,0 +1,42 @@
+// Package store keeps the state of the backend in PostgreSQL. Postgres implements the store interfaces
+// of the handlers and the password job runner, which is where the SQL of the handlers now lives.
+package store
+
+import (
+	"database/sql"
+	"errors"
+
+	"go-backend/internals/config"
+	"go-backend/internals/engine"
+	"go-backend/internals/events"
+	"go-backend/internals/topology"
+
+	"github.com/lib/pq"
+)
+
+// ErrAdminExists is returned when an admin with the same username already exists.
+var ErrAdminExists = errors.New("admin already exists")
+
+type Postgres struct {
+	DB *sql.DB
+	// Catalog is the central SQL Server, access requests and temporary grants are carried out there
+	Catalog *sql.DB
+	Config  *config.Config
+	// Events streams the audit rows to the admins
+	Events *events.Broker
+	// Connector reaches the servers of the inventory, for temporary grants and procedure deployments
+	Connector *engine.Connector
+	Topology  *topology.Cache
+}
+
+func New(db, catalog *sql.DB, cfg *config.Config, broker *events.Broker, connector *engine.Connector) *Postgres {
+	return &Postgres{DB: db, Catalog: catalog, Config: cfg, Events: broker, Connector: connector, Topology: topology.NewCache(cfg.Topology, broker)}
+}
+
+// formatTime renders a nullable timestamp, unset for NULL.
+func formatTime(t pq.NullTime, unset string) string {
+	if t.Valid {
+		return t.Time.Format("2006-01-02 15:04:05")
+	}
+	return unset
+}
@@ -0,0 +1,240 @@
+// Package topology tells which replicas a password update on a server has to touch. The replicas the
+// engine reports are cached for topology.cache_ttl, admins can pin extra replicas or exclude reported
+// ones. Overrides are read on every call so they apply at once.
+package topology
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go-backend/internals/config"
+	"go-backend/internals/engine"
+	"go-backend/internals/events"
+	"go-backend/models"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	SourceDiscovered = "discovered"
+	SourcePinned     = "pinned"
+)
+
+const (
+	ActionPin     = "pin"
+	ActionExclude = "exclude"
+)
+
+var (
+	ErrInvalidOverride  = errors.New("action must be pin or exclude and the replica must differ from the server")
+	ErrOverrideNotFound = errors.New("override not found")
+)
+
+type cacheEntry struct {
+	hosts     []string
+	fetchedAt time.Time
+}
+
+// invalidation is published on events.TopicTopology when the replicas of a server are refreshed
+type invalidation struct {
+	Server string `json:"server"`
+	Key    string `json:"key"`
+}
+
+// Cache keeps the replicas the engines report for the cache TTL of the topology settings. Every instance
+// has its own, a refresh is published through the broker so the other instances drop the server as well.
+type Cache struct {
+	ttl     time.Duration
+	broker  *events.Broker
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+// NewCache returns a cache that drops the servers refreshed on any instance the broker hears of, until the
+// broker is closed.
+func NewCache(c config.TopologyConfig, broker *events.Broker) *Cache {
+	cache := &Cache{ttl: c.CacheTTL, broker: broker, entries: map[string]cacheEntry{}}
+	stream, _ := broker.Subscribe(events.OnTopic(events.TopicTopology))
+	go cache.dropInvalidated(stream)
+	return cache
+}
+
+// Replicas returns the topology of the server, using the cached replicas while they are fresh.
+func (c *Cache) Replicas(ctx context.Context, db *sql.DB, eng engine.Engine, server engine.Server) (models.ServerTopology, error) {
+	key := cacheKey(server)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if !ok || time.Since(entry.fetchedAt) > c.ttl {
+		hosts, err := eng.FindReplicas(ctx, server)
+		if err != nil {
+			return models.ServerTopology{}, err
+		}
+		entry = cacheEntry{hosts: hosts, fetchedAt: time.Now()}
+		c.mu.Lock()
+		c.entries[key] = entry
+		c.mu.Unlock()
+	}
+
+	overrides, err := GetOverrides(db, server.ID)
+	if err != nil {
+		return models.ServerTopology{}, err
+	}
+	return build(server, entry, overrides), nil
+}
+
+// Refresh drops the cached replicas of the server on every instance and fetches them again.
+func (c *Cache) Refresh(ctx context.Context, db *sql.DB, eng engine.Engine, server engine.Server) (models.ServerTopology, error) {
+	log.Info().Msgf("Refreshing the replicas of %s", server.Name)
+	c.invalidate(server)
+	return c.Replicas(ctx, db, eng, server)
+}
+
+// invalidate drops the server here and publishes the refresh. The event also comes back to this instance
+// through the relay, which may drop the replicas fetched in the meantime and costs one more lookup.
+func (c *Cache) invalidate(server engine.Server) {
+	key := cacheKey(server)
+	c.drop(key)
+	c.broker.Publish(events.Event{Topic: events.TopicTopology, Type: "refresh", Data: invalidation{Server: server.Name, Key: key}})
+}
+
+func (c *Cache) drop(key string) {
+	c.mu.Lock()
+	delete(c.entries, key)
+	c.mu.Unlock()
+}
+
+// dropInvalidated drops the servers refreshed on any instance until the stream is closed.
+func (c *Cache) dropInvalidated(stream <-chan events.Event) {
+	for event := range stream {
+		// events of this process carry the invalidation itself, relayed ones its JSON
+		var refreshed invalidation
+		switch data := event.Data.(type) {
+		case invalidation:
+			refreshed = data
+		case json.RawMessage:
+			if err := json.Unmarshal(data, &refreshed); err != nil {
+				log.Error().Err(err).Msg("Dropping a topology event that is not an invalidation")
+				continue
+			}
+		}
+		if refreshed.Key != "" {
+			c.drop(refreshed.Key)
+		}
+	}
+}
+
+// Targets returns the hosts of the replicas that are not excluded.
+func Targets(topology models.ServerTopology) []string {
+	hosts := []string{}
+	for _, node := range topology.Replicas {
+		if !node.Excluded {
+			hosts = append(hosts, node.Host)
+		}
+	}
+	return hosts
+}
+
+func build(server engine.Server, entry cacheEntry, overrides []models.TopologyOverride) models.ServerTopology {
+	excluded := map[string]bool{}
+	var pinned []string
+	for _, override := range overrides {
+		switch override.Action {
+		case ActionExclude:
+			excluded[strings.ToLower(override.ReplicaHost)] = true
+		case ActionPin:
+			pinned = append(pinned, override.ReplicaHost)
+		}
+	}
+
+	topology := models.ServerTopology{
+		Server:    server.Name,
+		Host:      server.Host,
+		Engine:    server.Engine,
+		Replicas:  []models.TopologyNode{},
+		FetchedAt: entry.fetchedAt.Format("2006-01-02 15:04:05"),
+	}
+	seen := map[string]bool{strings.ToLower(server.Host): true}
+	add := func(host, source string) {
+		if seen[strings.ToLower(host)] {
+			return
+		}
+		seen[strings.ToLower(host)] = true
+		topology.Replicas = append(topology.Replicas, models.TopologyNode{
+			Host:     host,
+			Source:   source,
+			Excluded: excluded[strings.ToLower(host)],
+		})
+	}
+	for _, host := range entry.hosts {
+		add(host, SourceDiscovered)
+	}
+	for _, host := range pinned {
+		add(host, SourcePinned)
+	}
+	return topology
+}
+
+// cacheKey identifies the server by its inventory entry and where it is reached, so entries that share a
+// host on different ports get replicas of their own, and a changed entry is looked up again.
+func cacheKey(server engine.Server) string {
+	return fmt.Sprintf("%d/%s/%s:%d", server.ID, server.Engine, strings.ToLower(server.Host), server.Port)
+}
+
+// GetOverrides returns the overrides of the inventory entry with the id.
+func GetOverrides(db *sql.DB, serverID int) ([]models.TopologyOverride, error) {
+	rows, err := db.Query(`SELECT server_id, replica_host, action, created_by, created_at FROM topology_overrides
+		WHERE server_id = $1 ORDER BY replica_host`, serverID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	overrides := []models.TopologyOverride{}
+	for rows.Next() {
+		var override models.TopologyOverride
+		var createdAt pq.NullTime
+		if err := rows.Scan(&override.ServerID, &override.ReplicaHost, &override.Action, &override.CreatedBy, &createdAt); err != nil {
+			return nil, err
+		}
+		if createdAt.Valid {
+			override.CreatedAt = createdAt.Time.Format("2006-01-02 15:04:05")
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, rows.Err()
+}
+
+// SetOverride pins or excludes a replica of the server, replacing an earlier override of the same replica.
+func SetOverride(db *sql.DB, server engine.Server, override models.TopologyOverride) error {
+	override.ServerID = server.ID
+	override.ReplicaHost = strings.TrimSpace(override.ReplicaHost)
+	if (override.Action != ActionPin && override.Action != ActionExclude) || override.ReplicaHost == "" ||
+		strings.EqualFold(override.ReplicaHost, server.Host) {
+		return ErrInvalidOverride
+	}
+	_, err := db.Exec(`INSERT INTO topology_overrides (server_id, replica_host, action, created_by, created_at)
+		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP)
+		ON CONFLICT (server_id, replica_host) DO UPDATE SET action = EXCLUDED.action,
+			created_by = EXCLUDED.created_by, created_at = EXCLUDED.created_at`,
+		override.ServerID, override.ReplicaHost, override.Action, override.CreatedBy)
+	return err
+}
+
+func RemoveOverride(db *sql.DB, server engine.Server, replicaHost string) error {
+	result, err := db.Exec("DELETE FROM topology_overrides WHERE server_id = $1 AND replica_host = $2", server.ID, replicaHost)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrOverrideNotFound
+	}
+	return nil
+}
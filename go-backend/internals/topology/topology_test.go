@@ -0,0 +1,108 @@
+package topology
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-backend/internals/config"
+	"go-backend/internals/engine"
+	"go-backend/internals/events"
+	"go-backend/models"
+)
+
+func TestCacheKeySeparatesEntriesOfAHost(t *testing.T) {
+	first := engine.Server{ID: 1, Name: "sales-db-01", Host: "10.0.0.11", Port: 1433, Engine: engine.MSSQL}
+	second := engine.Server{ID: 2, Name: "sales-db-01-b", Host: "10.0.0.11", Port: 1434, Engine: engine.MSSQL}
+	if cacheKey(first) == cacheKey(second) {
+		t.Errorf("entries on different ports of a host share the cache key %q", cacheKey(first))
+	}
+	moved := first
+	moved.Port = 1435
+	if cacheKey(first) == cacheKey(moved) {
+		t.Errorf("an entry whose port changed keeps the cache key %q", cacheKey(first))
+	}
+	renamed := first
+	renamed.Name = "renamed"
+	if cacheKey(first) != cacheKey(renamed) {
+		t.Errorf("renaming an entry changed its cache key from %q to %q", cacheKey(first), cacheKey(renamed))
+	}
+}
+
+func TestBuildAppliesOverrides(t *testing.T) {
+	server := engine.Server{ID: 1, Name: "sales-db-01", Host: "10.0.0.11", Port: 1433, Engine: engine.MSSQL}
+	entry := cacheEntry{hosts: []string{"10.0.0.12", "10.0.0.13", "10.0.0.11"}, fetchedAt: time.Now()}
+	overrides := []models.TopologyOverride{
+		{ServerID: 1, ReplicaHost: "10.0.0.13", Action: ActionExclude},
+		{ServerID: 1, ReplicaHost: "10.0.0.14", Action: ActionPin},
+		{ServerID: 1, ReplicaHost: "10.0.0.12", Action: ActionPin},
+	}
+
+	got := build(server, entry, overrides)
+	want := []models.TopologyNode{
+		{Host: "10.0.0.12", Source: SourceDiscovered},
+		{Host: "10.0.0.13", Source: SourceDiscovered, Excluded: true},
+		{Host: "10.0.0.14", Source: SourcePinned},
+	}
+	if len(got.Replicas) != len(want) {
+		t.Fatalf("replicas = %+v, want %+v", got.Replicas, want)
+	}
+	for i := range want {
+		if got.Replicas[i] != want[i] {
+			t.Errorf("replica %d = %+v, want %+v", i, got.Replicas[i], want[i])
+		}
+	}
+	if targets := Targets(got); len(targets) != 2 || targets[0] != "10.0.0.12" || targets[1] != "10.0.0.14" {
+		t.Errorf("Targets = %v, want the replicas that are not excluded", targets)
+	}
+}
+
+func TestRefreshDropsTheServerOnEveryInstance(t *testing.T) {
+	// the instances share the broker as the relay connects the brokers of all of them
+	broker := events.NewBroker()
+	defer broker.Close()
+	first, second := NewCache(config.TopologyConfig{CacheTTL: time.Hour}, broker), NewCache(config.TopologyConfig{CacheTTL: time.Hour}, broker)
+	server := engine.Server{ID: 1, Name: "sales-db-01", Host: "10.0.0.11", Port: 1433, Engine: engine.MSSQL}
+	other := engine.Server{ID: 2, Name: "billing-db-01", Host: "10.0.0.21", Port: 1433, Engine: engine.MSSQL}
+	for _, cache := range []*Cache{first, second} {
+		for _, s := range []engine.Server{server, other} {
+			cache.entries[cacheKey(s)] = cacheEntry{hosts: []string{"10.0.0.12"}, fetchedAt: time.Now()}
+		}
+	}
+	cached := func(cache *Cache, s engine.Server) bool {
+		cache.mu.Lock()
+		defer cache.mu.Unlock()
+		_, ok := cache.entries[cacheKey(s)]
+		return ok
+	}
+
+	first.invalidate(server)
+	if cached(first, server) {
+		t.Error("the refreshing instance kept the replicas of the server")
+	}
+	for deadline := time.Now().Add(time.Second); cached(second, server); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("the other instance kept the replicas of the refreshed server")
+		}
+	}
+	if !cached(first, other) || !cached(second, other) {
+		t.Error("the replicas of another server were dropped")
+	}
+}
+
+func TestDropInvalidatedDecodesRelayedEvents(t *testing.T) {
+	cache := &Cache{entries: map[string]cacheEntry{}}
+	server := engine.Server{ID: 1, Name: "sales-db-01", Host: "10.0.0.11", Port: 1433, Engine: engine.MSSQL}
+	cache.entries[cacheKey(server)] = cacheEntry{hosts: []string{"10.0.0.12"}, fetchedAt: time.Now()}
+
+	// the relay hands the data of other instances over as the JSON of the notification
+	stream := make(chan events.Event, 2)
+	stream <- events.Event{Topic: events.TopicTopology, Type: "refresh", Data: json.RawMessage(`"not an invalidation"`)}
+	stream <- events.Event{Topic: events.TopicTopology, Type: "refresh", Data: json.RawMessage(`{"server":"sales-db-01","key":"` + cacheKey(server) + `"}`)}
+	close(stream)
+	cache.dropInvalidated(stream)
+
+	if _, ok := cache.entries[cacheKey(server)]; ok {
+		t.Error("a relayed invalidation did not drop the server")
+	}
+}
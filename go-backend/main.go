@@ -7,9 +7,18 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
+	"go-backend/internals/config"
 	"go-backend/internals/database"
+	"go-backend/internals/engine"
+	"go-backend/internals/events"
+	"go-backend/internals/gateway"
+	"go-backend/internals/handlers"
+	"go-backend/internals/middleware"
+	"go-backend/internals/pkg"
+	"go-backend/internals/secrets"
+	"go-backend/internals/service"
+	"go-backend/internals/store"
 	"go-backend/routes"
 	"github.com/rs/cors"
     "github.com/rs/zerolog"
@@ -39,9 +48,19 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 func main() {
 
     log.Logger = zerolog.New(os.Stdout).With().Timestamp().Logger()
+    // log.Ctx falls back to the global logger for contexts without a request logger
+    zerolog.DefaultContextLogger = &log.Logger
     log.Info().Msg("Starting server...")
 
-    db, err := database.ConnectPostgres()
+    // settings come from CONFIG_FILE (or config.yaml) and the environment, nothing else reads them
+    cfg, err := config.Load("")
+    if err != nil {
+        log.Fatal().Err(err).Msg("Failed to load configuration")
+    }
+    // credential references of the central SQL Server and of the inventory share the providers
+    connector := engine.NewConnector(cfg, secrets.NewResolver(cfg.Secrets))
+
+    db, err := database.ConnectPostgres(cfg.Postgres)
     if err != nil {
         log.Fatal().Err(err).Msg("Failed to connect to PostgreSQL database")
     }
@@ -51,7 +70,14 @@ func main() {
         }
     }()
     log.Info().Msg("Connected to PostgreSQL database successfully")
-    msdb, err := database.ConnectMSSQL()
+    // the schema is versioned, only migrations that have not run yet are applied
+    if err := database.MigratePostgres(context.Background(), db); err != nil {
+        log.Fatal().Err(err).Msg("Failed to migrate the PostgreSQL schema")
+    }
+    if err := pkg.BootstrapAdmin(db, cfg.Admin); err != nil {
+        log.Fatal().Err(err).Msg("Failed to bootstrap the first admin")
+    }
+    msdb, err := database.ConnectMSSQL(cfg.MSSQL, connector.Secrets)
     if err != nil {
         log.Fatal().Err(err).Msg("Failed to connect to MSSQL database")
     }
@@ -61,28 +87,61 @@ func main() {
         }
     }()
     log.Info().Msg("Connected to MSSQL database successfully")
-    router := routes.RegisterRoutes()
+    if err := database.MigrateMSSQL(context.Background(), msdb); err != nil {
+        log.Fatal().Err(err).Msg("Failed to migrate the MSSQL procedures")
+    }
+
+    // the handlers and the job workers only see the databases and the event broker through the app
+    broker := events.NewBroker()
+    app := handlers.NewApp(cfg, broker, store.New(db, msdb, cfg, broker, connector), gateway.New(db, msdb, connector), pkg.SMTPMailer{Config: cfg.SMTP}, service.SystemClock{})
+
+    // job progress and audit rows reach the event streams of every instance through PostgreSQL
+    relayCtx, stopRelay := context.WithCancel(context.Background())
+    defer stopRelay()
+    if err := broker.StartRelay(relayCtx, db, database.PostgresDSN(cfg.Postgres)); err != nil {
+        log.Error().Err(err).Msg("Failed to start the event relay, event streams only see this instance")
+    }
+
+    // idempotency keys and the responses stored under them are deleted once they expire
+    purgerCtx, stopPurger := context.WithCancel(context.Background())
+    defer stopPurger()
+    pkg.StartIdempotencyPurger(purgerCtx, db)
+
+    // revoke just-in-time grants once their window expires
+    revokerCtx, stopRevoker := context.WithCancel(context.Background())
+    defer stopRevoker()
+    service.StartGrantRevoker(revokerCtx, db, broker, connector, cfg.Grants)
+
+    // password updates are queued by the API and run by these workers
+    jobsCtx, stopJobWorkers := context.WithCancel(context.Background())
+    defer stopJobWorkers()
+    waitJobWorkers := app.PasswordJobs().Start(jobsCtx, cfg.Jobs.Workers, cfg.Jobs.PollInterval)
+
+    router := routes.RegisterRoutes(app)
     router.HandleFunc("/actuator/info", HealthCheck).Methods("GET")
 
     c := cors.New(cors.Options{
-        AllowedOrigins:   []string{"http://localhost:5173"},
+        AllowedOrigins:   cfg.Server.CORSOrigins,
         AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
-        AllowedHeaders:   []string{"Content-Type"},
+        AllowedHeaders:   []string{"Content-Type", "X-Request-ID", "Idempotency-Key"},
+        ExposedHeaders:   []string{"X-Request-ID", "X-Client-Request-ID", "Retry-After", "Idempotent-Replayed"},
         AllowCredentials: true,
     })
-    handler := c.Handler(router)
+    handler := middleware.RequestID(c.Handler(router))
 
     srv := &http.Server{
-        Addr:    ":8080",
+        Addr:    cfg.Server.Addr,
         Handler: handler,
     }
+    // event streams never finish on their own, they are ended when the shutdown starts
+    srv.RegisterOnShutdown(broker.Close)
 
     // Channel to listen for interrupt signals
     stop := make(chan os.Signal, 1)
     signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
 
     go func() {
-        log.Info().Msg("Server started on port 8080")
+        log.Info().Msgf("Server started on %s", cfg.Server.Addr)
         if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
         log.Fatal().Err(err).Msg("Failed to start server")
         }
@@ -91,13 +150,21 @@ func main() {
     <-stop // Wait for the signal
 
     log.Info().Msg("Shutting down server...")
+    stopRevoker()
     // Create a context with a timeout for graceful shutdown
-    ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+    ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
     defer cancel()
 
-    // Attempt to gracefully shut down the server
+    // Attempt to gracefully shut down the server, requests still running after the timeout are cut off
+    // and the shutdown carries on, so the job workers below still get to finish
     if err := srv.Shutdown(ctx); err != nil {
-        log.Fatal().Err(err).Msg("Failed to shut down server")
+        log.Error().Err(err).Msg("Failed to shut down server gracefully, closing the remaining connections")
+        if err := srv.Close(); err != nil {
+            log.Error().Err(err).Msg("Failed to close server")
+        }
     }
+    // jobs that already started are finished before the database connections are closed
+    stopJobWorkers()
+    waitJobWorkers()
     log.Info().Msg("Server shut down successfully")
 }
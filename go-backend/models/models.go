@@ -16,5 +16,218 @@ type ResetRequest struct {
 	RequestType   string `json:"requestType"`
 	RequestStatus string `json:"requestStatus"`
 	Message       string `json:"message"`
+	CorrelationID string `json:"correlationID"`
 	RequestTime   string `json:"requestTime"`
-}
\ No newline at end of file
+}
+
+type AccessRequest struct {
+	Username    string `json:"username"`
+	Database    string `json:"database"`
+	AccessLevel string `json:"accessLevel"`
+	Reason      string `json:"reason"`
+}
+
+type AccessRequestRecord struct {
+	RequestID     int    `json:"requestID"`
+	Username      string `json:"username"`
+	Database      string `json:"database"`
+	AccessLevel   string `json:"accessLevel"`
+	Reason        string `json:"reason"`
+	RequestStatus string `json:"requestStatus"`
+	ReviewedBy    string `json:"reviewedBy"`
+	Message       string `json:"message"`
+	CurrentStage  int    `json:"currentStage"`
+	RequestTime   string `json:"requestTime"`
+	ReviewTime    string `json:"reviewTime"`
+}
+
+type AccessReview struct {
+	Message string `json:"message"`
+}
+
+type ApprovalStage struct {
+	AccessLevel   string `json:"accessLevel"`
+	StageOrder    int    `json:"stageOrder"`
+	StageName     string `json:"stageName"`
+	ApproverGroup string `json:"approverGroup"`
+}
+
+type AccessDecision struct {
+	StageOrder    int    `json:"stageOrder"`
+	StageName     string `json:"stageName"`
+	ApproverGroup string `json:"approverGroup"`
+	Approver      string `json:"approver"`
+	Decision      string `json:"decision"`
+	Comment       string `json:"comment"`
+	DecidedAt     string `json:"decidedAt"`
+}
+
+type AccessDecisionResult struct {
+	RequestID     int    `json:"requestID"`
+	RequestStatus string `json:"requestStatus"`
+	StageName     string `json:"stageName"`
+	NextStage     string `json:"nextStage,omitempty"`
+	Message       string `json:"message"`
+}
+
+type TemporaryAccessRequest struct {
+	Username      string `json:"username"`
+	Email         string `json:"emailID"`
+	ServerIP      string `json:"serverIP"`
+	Database      string `json:"database"`
+	Role          string `json:"role"`
+	DurationHours int    `json:"durationHours"`
+}
+
+type TemporaryAccessRecord struct {
+	RequestID     int    `json:"requestID"`
+	Username      string `json:"username"`
+	ServerIP      string `json:"serverIP"`
+	Database      string `json:"database"`
+	Role          string `json:"role"`
+	DurationHours int    `json:"durationHours"`
+	RequestStatus string `json:"requestStatus"`
+	ReviewedBy    string `json:"reviewedBy"`
+	Message       string `json:"message"`
+	RequestTime   string `json:"requestTime"`
+	ReviewTime    string `json:"reviewTime"`
+	ExpiresAt     string `json:"expiresAt"`
+}
+
+type TemporaryGrant struct {
+	GrantID        int    `json:"grantID"`
+	RequestID      int    `json:"requestID"`
+	Username       string `json:"username"`
+	Database       string `json:"database"`
+	Role           string `json:"role"`
+	Server         string `json:"server"`
+	GrantStatus    string `json:"grantStatus"`
+	AlreadyMember  bool   `json:"alreadyMember"`
+	GrantedAt      string `json:"grantedAt"`
+	ExpiresAt      string `json:"expiresAt"`
+	RevokedAt      string `json:"revokedAt"`
+	RevokeAttempts int    `json:"revokeAttempts"`
+	LastError      string `json:"lastError"`
+}
+
+type Admin struct {
+	Username            string `json:"username"`
+	Role                string `json:"role"`
+	Disabled            bool   `json:"disabled"`
+	MustChangePassword  bool   `json:"mustChangePassword"`
+	PasswordLastUpdated string `json:"passwordLastUpdated"`
+	LastLogin           string `json:"lastLogin"`
+	CreatedAt           string `json:"createdAt"`
+}
+
+type NewAdminRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+// ServerAttempt is the outcome of a password update on one server of the availability group
+type ServerAttempt struct {
+	Server      string `json:"server"`
+	IsPrimary   bool   `json:"isPrimary"`
+	Operation   string `json:"operation"`
+	Attempt     int    `json:"attempt"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	DurationMs  int    `json:"durationMs"`
+	AttemptedAt string `json:"attemptedAt"`
+}
+
+// PartialReset is a password update that left the new password on some servers and the old one on others.
+// Succeeded counts the servers with the new password, Failed those still on the old one.
+type PartialReset struct {
+	CorrelationID string `json:"correlationID"`
+	Username      string `json:"username"`
+	ServerIP      string `json:"serverIP"`
+	RequestStatus string `json:"requestStatus"`
+	Message       string `json:"message"`
+	Succeeded     int    `json:"succeeded"`
+	Failed        int    `json:"failed"`
+	RequestTime   string `json:"requestTime"`
+}
+
+// PasswordJob is a queued password update and its progress
+type PasswordJob struct {
+	JobID      string            `json:"jobID"`
+	RequestID  string            `json:"requestID"`
+	Status     string            `json:"status"`
+	Message    string            `json:"message"`
+	CreatedAt  string            `json:"createdAt"`
+	StartedAt  string            `json:"startedAt"`
+	FinishedAt string            `json:"finishedAt"`
+	Steps      []PasswordJobStep `json:"steps"`
+}
+
+type PasswordJobStep struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Message    string `json:"message"`
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt"`
+}
+
+// DatabaseServer is an entry of the server inventory, users can only reset passwords on enabled servers
+type DatabaseServer struct {
+	ID                int    `json:"id"`
+	Name              string `json:"name"`
+	Host              string `json:"host"`
+	Port              int    `json:"port"`
+	Engine            string `json:"engine"`
+	Environment       string `json:"environment"`
+	AvailabilityGroup string `json:"availabilityGroup"`
+	CredentialRef     string `json:"credentialRef"`
+	Enabled           bool   `json:"enabled"`
+	CreatedAt         string `json:"createdAt"`
+	UpdatedAt         string `json:"updatedAt"`
+}
+
+// ServerTopology lists the replicas a password update on the server touches, excluded ones are listed but skipped
+type ServerTopology struct {
+	Server    string         `json:"server"`
+	Host      string         `json:"host"`
+	Engine    string         `json:"engine"`
+	Replicas  []TopologyNode `json:"replicas"`
+	FetchedAt string         `json:"fetchedAt"`
+}
+
+type TopologyNode struct {
+	Host     string `json:"host"`
+	Source   string `json:"source"`
+	Excluded bool   `json:"excluded"`
+}
+
+// TopologyOverride pins a replica to a server of the inventory or excludes it, whatever the availability
+// group reports
+type TopologyOverride struct {
+	ServerID    int    `json:"serverID"`
+	ReplicaHost string `json:"replicaHost"`
+	Action      string `json:"action"`
+	CreatedBy   string `json:"createdBy"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// ProcedureReport compares the stored procedures on a SQL Server with the set built into the backend.
+// Version identifies the built-in set, InstalledVersion the set last deployed to the server.
+type ProcedureReport struct {
+	Server           string            `json:"server"`
+	Host             string            `json:"host"`
+	Version          string            `json:"version"`
+	InstalledVersion string            `json:"installedVersion"`
+	Drift            bool              `json:"drift"`
+	Procedures       []ProcedureStatus `json:"procedures"`
+	CheckedAt        string            `json:"checkedAt"`
+}
+
+type ProcedureStatus struct {
+	Name              string `json:"name"`
+	Status            string `json:"status"`
+	Checksum          string `json:"checksum"`
+	InstalledChecksum string `json:"installedChecksum"`
+	DeployedBy        string `json:"deployedBy"`
+	DeployedAt        string `json:"deployedAt"`
+}
@@ -1,15 +1,83 @@
 package routes
 
 import (
+    "net/http"
+
     "github.com/gorilla/mux"
     "go-backend/internals/handlers"
+    "go-backend/internals/middleware"
 )
 
-func RegisterRoutes() *mux.Router {
+// protect wraps an admin handler with the permission its route needs
+func protect(perm middleware.Permission, handler http.HandlerFunc) http.Handler {
+    return middleware.RequirePermission(perm)(handler)
+}
+
+func RegisterRoutes(app *handlers.App) *mux.Router {
     r := mux.NewRouter()
 
-    r.HandleFunc("/update-password", handlers.UpdatePassword).Methods("PUT")
-    r.HandleFunc("/admin-login", handlers.AdminLogin).Methods("POST")
-    r.HandleFunc("/getAllResetReq", handlers.GetAllResetReq).Methods("GET")
+    r.Handle("/update-password", middleware.Idempotency(app.Idempotency)(http.HandlerFunc(app.UpdatePassword))).Methods("PUT")
+    r.HandleFunc("/jobs/{id}", app.GetJob).Methods("GET")
+    r.HandleFunc("/jobs/{id}/events", app.JobEvents).Methods("GET")
+    r.HandleFunc("/admin-login", app.AdminLogin).Methods("POST")
+    r.HandleFunc("/admin-login/mfa", app.AdminLoginMFA).Methods("POST")
+    r.HandleFunc("/admin-setup", app.AdminSetup).Methods("POST")
+    r.HandleFunc("/access-request", app.CreateAccessRequest).Methods("POST")
+    r.HandleFunc("/temporary-access", app.RequestTemporaryAccess).Methods("POST")
+    r.HandleFunc("/servers/{server}/replicas", app.GetServerReplicas).Methods("GET")
+
+    // everything below requires a logged in admin
+    admin := r.NewRoute().Subrouter()
+    admin.Use(middleware.RequireAdminSession(app.Sessions, app.Config))
+
+    admin.HandleFunc("/admin-logout", app.AdminLogout).Methods("POST")
+    admin.HandleFunc("/admin-refresh", app.AdminRefreshSession).Methods("POST")
+    admin.HandleFunc("/admin-session", app.GetAdminSession).Methods("GET")
+    admin.HandleFunc("/admin-change-password", app.ChangeAdminPassword).Methods("POST")
+    admin.HandleFunc("/admin-mfa/enroll", app.EnrollMFA).Methods("POST")
+    admin.HandleFunc("/admin-mfa/verify", app.VerifyMFAEnrollment).Methods("POST")
+    admin.HandleFunc("/admin-mfa/disable", app.DisableMFA).Methods("POST")
+
+    admin.Handle("/getAllResetReq", protect(middleware.PermViewLogs, app.GetAllResetReq)).Methods("GET")
+    admin.Handle("/getAllAccessReq", protect(middleware.PermViewLogs, app.GetAllAccessReq)).Methods("GET")
+    admin.Handle("/access-request/{id:[0-9]+}/decisions", protect(middleware.PermViewLogs, app.GetAccessRequestDecisions)).Methods("GET")
+    admin.Handle("/approval-stages", protect(middleware.PermViewLogs, app.GetApprovalStages)).Methods("GET")
+    admin.Handle("/getAllTemporaryAccessReq", protect(middleware.PermViewLogs, app.GetAllTemporaryAccessReq)).Methods("GET")
+    admin.Handle("/getAllTemporaryGrants", protect(middleware.PermViewLogs, app.GetAllTemporaryGrants)).Methods("GET")
+    admin.Handle("/admin/events", protect(middleware.PermViewLogs, app.AdminEvents)).Methods("GET")
+    admin.Handle("/password-updates/partial", protect(middleware.PermViewLogs, app.GetPartialResets)).Methods("GET")
+    admin.Handle("/password-updates/{correlationID}/servers", protect(middleware.PermViewLogs, app.GetPasswordUpdateServers)).Methods("GET")
+
+    admin.Handle("/access-request/{id:[0-9]+}/approve", protect(middleware.PermApproveAccess, app.ApproveAccessRequest)).Methods("PUT")
+    admin.Handle("/access-request/{id:[0-9]+}/reject", protect(middleware.PermApproveAccess, app.RejectAccessRequest)).Methods("PUT")
+
+    admin.Handle("/temporary-access/{id:[0-9]+}/approve", protect(middleware.PermApproveAccess, app.ApproveTemporaryAccess)).Methods("PUT")
+    admin.Handle("/temporary-access/{id:[0-9]+}/reject", protect(middleware.PermApproveAccess, app.RejectTemporaryAccess)).Methods("PUT")
+
+    admin.Handle("/temporary-access/{id:[0-9]+}", protect(middleware.PermManageGrants, app.RevokeTemporaryAccess)).Methods("DELETE")
+
+    admin.Handle("/approval-stages/{accessLevel}", protect(middleware.PermManageWorkflow, app.SetApprovalStages)).Methods("PUT")
+    admin.Handle("/approver-groups/{group}/members/{approver}", protect(middleware.PermManageWorkflow, app.AddApproverGroupMember)).Methods("PUT")
+    admin.Handle("/approver-groups/{group}/members/{approver}", protect(middleware.PermManageWorkflow, app.RemoveApproverGroupMember)).Methods("DELETE")
+
+    admin.Handle("/admins", protect(middleware.PermManageAdmins, app.GetAllAdmins)).Methods("GET")
+    admin.Handle("/admins", protect(middleware.PermManageAdmins, app.CreateAdmin)).Methods("POST")
+    admin.Handle("/admins/{username}", protect(middleware.PermManageAdmins, app.DeleteAdmin)).Methods("DELETE")
+    admin.Handle("/admins/{username}/role", protect(middleware.PermManageAdmins, app.SetAdminRole)).Methods("PUT")
+    admin.Handle("/admins/{username}/disable", protect(middleware.PermManageAdmins, app.DisableAdmin)).Methods("PUT")
+    admin.Handle("/admins/{username}/enable", protect(middleware.PermManageAdmins, app.EnableAdmin)).Methods("PUT")
+    admin.Handle("/admins/{username}/force-password-change", protect(middleware.PermManageAdmins, app.ForceAdminPasswordChange)).Methods("PUT")
+    admin.Handle("/admins/{username}/mfa", protect(middleware.PermManageAdmins, app.ResetAdminMFA)).Methods("DELETE")
+
+    admin.Handle("/servers", protect(middleware.PermViewLogs, app.GetAllServers)).Methods("GET")
+    admin.Handle("/servers/{id:[0-9]+}", protect(middleware.PermViewLogs, app.GetServer)).Methods("GET")
+    admin.Handle("/servers", protect(middleware.PermManageServers, app.CreateServer)).Methods("POST")
+    admin.Handle("/servers/{id:[0-9]+}", protect(middleware.PermManageServers, app.UpdateServer)).Methods("PUT")
+    admin.Handle("/servers/{id:[0-9]+}", protect(middleware.PermManageServers, app.DeleteServer)).Methods("DELETE")
+    admin.Handle("/servers/{server}/replicas/refresh", protect(middleware.PermManageServers, app.RefreshServerReplicas)).Methods("POST")
+    admin.Handle("/servers/{server}/replica-overrides/{replica}", protect(middleware.PermManageServers, app.SetReplicaOverride)).Methods("PUT")
+    admin.Handle("/servers/{server}/replica-overrides/{replica}", protect(middleware.PermManageServers, app.RemoveReplicaOverride)).Methods("DELETE")
+    admin.Handle("/servers/{server}/procedures", protect(middleware.PermViewLogs, app.GetServerProcedures)).Methods("GET")
+    admin.Handle("/servers/{server}/procedures/deploy", protect(middleware.PermManageServers, app.DeployServerProcedures)).Methods("POST")
     return r
 }